@@ -18,6 +18,7 @@ package v1beta1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 // KubernetesConfig will be the JSON struct for Basic Redis Config
@@ -34,6 +35,25 @@ type RedisConfig struct {
 	AdditionalRedisConfig *string `json:"additionalRedisConfig,omitempty"`
 }
 
+// ServiceConfig customizes the client service of Redis, the headless service is left as is
+type ServiceConfig struct {
+	// +kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer
+	// +kubebuilder:default=ClusterIP
+	Type string `json:"type,omitempty"`
+	// Annotations are added to the annotations copied from the custom resource, e.g. cloud load balancer settings
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Labels are added to the service only, the selector is kept
+	Labels                   map[string]string `json:"labels,omitempty"`
+	LoadBalancerSourceRanges []string          `json:"loadBalancerSourceRanges,omitempty"`
+	// ExternalTrafficPolicy applies to NodePort and LoadBalancer services
+	// +kubebuilder:validation:Enum=Cluster;Local
+	ExternalTrafficPolicy corev1.ServiceExternalTrafficPolicyType `json:"externalTrafficPolicy,omitempty"`
+	// +kubebuilder:validation:Enum=None;ClientIP
+	SessionAffinity corev1.ServiceAffinity `json:"sessionAffinity,omitempty"`
+	// AdditionalPorts are exposed next to the redis-client and redis-exporter ports
+	AdditionalPorts []corev1.ServicePort `json:"additionalPorts,omitempty"`
+}
+
 // ExistingPasswordSecret is the struct to access the existing secret
 type ExistingPasswordSecret struct {
 	Name *string `json:"name,omitempty"`
@@ -43,6 +63,77 @@ type ExistingPasswordSecret struct {
 // Storage is the inteface to add pvc and pv support in redis
 type Storage struct {
 	VolumeClaimTemplate corev1.PersistentVolumeClaim `json:"volumeClaimTemplate,omitempty"`
+	Ephemeral           *EphemeralStorage            `json:"ephemeral,omitempty"`
+}
+
+// EphemeralStorage mounts an emptyDir at /data instead of a PersistentVolumeClaim, RDB and AOF are disabled
+type EphemeralStorage struct {
+	// +kubebuilder:validation:Enum="";Memory
+	Medium corev1.StorageMedium `json:"medium,omitempty"`
+	// SizeLimit is required for the Memory medium as the volume is accounted to the container memory
+	SizeLimit *resource.Quantity `json:"sizeLimit,omitempty"`
+}
+
+// IsEphemeral returns true if the storage is backed by an emptyDir
+func (s *Storage) IsEphemeral() bool {
+	return s != nil && s.Ephemeral != nil
+}
+
+// Persistence defines the RDB and AOF configuration rendered by the operator into Redis
+type Persistence struct {
+	// CacheOnly disables RDB snapshots and AOF, Redis is used as a pure cache
+	CacheOnly bool            `json:"cacheOnly,omitempty"`
+	RDB       *RDBPersistence `json:"rdb,omitempty"`
+	AOF       *AOFPersistence `json:"aof,omitempty"`
+}
+
+// RDBPersistence defines the RDB snapshot schedule
+type RDBPersistence struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// SavePoints trigger a snapshot after the given seconds if at least the given number of keys changed
+	SavePoints []RDBSavePoint `json:"savePoints,omitempty"`
+}
+
+// RDBSavePoint is a single "save <seconds> <changes>" directive
+type RDBSavePoint struct {
+	// +kubebuilder:validation:Minimum=1
+	Seconds int32 `json:"seconds"`
+	// +kubebuilder:validation:Minimum=1
+	Changes int32 `json:"changes"`
+}
+
+// AOFPersistence defines the append only file configuration
+type AOFPersistence struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// +kubebuilder:validation:Enum=always;everysec;no
+	// +kubebuilder:default=everysec
+	AppendFsync string `json:"appendfsync,omitempty"`
+	// +kubebuilder:validation:Minimum=0
+	AutoRewritePercentage *int32 `json:"autoRewritePercentage,omitempty"`
+	// AutoRewriteMinSize is the minimal size of the AOF to be rewritten, e.g. 64mb
+	AutoRewriteMinSize string `json:"autoRewriteMinSize,omitempty"`
+}
+
+// PersistenceStatus exposes the result of the last RDB and AOF writes reported by INFO persistence
+type PersistenceStatus struct {
+	RDBLastBgsaveStatus string `json:"rdbLastBgsaveStatus,omitempty"`
+	AOFLastWriteStatus  string `json:"aofLastWriteStatus,omitempty"`
+}
+
+// StorageMigrationStatus tracks the move of a statefulset onto a new StorageClass
+type StorageMigrationStatus struct {
+	StatefulSet      string `json:"statefulSet"`
+	StorageClassName string `json:"storageClassName"`
+	// ClaimTemplateName is the volumeClaimTemplate provisioning the claims of the new StorageClass
+	ClaimTemplateName string `json:"claimTemplateName"`
+	// SourceClaimTemplateName is the volumeClaimTemplate of the previous claims, copied while their pod is stopped
+	SourceClaimTemplateName string `json:"sourceClaimTemplateName,omitempty"`
+	// Partition is the lowest pod ordinal already running on the new claims
+	Partition int32 `json:"partition"`
+	// +kubebuilder:validation:Enum=Migrating;AwaitingConfirmation;Completed
+	Phase string `json:"phase"`
+	// RetainedClaims are the previous claims, deleted once the migration is confirmed
+	RetainedClaims []string `json:"retainedClaims,omitempty"`
 }
 
 // RedisExporter interface will have the information for redis exporter related stuff
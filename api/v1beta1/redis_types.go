@@ -30,6 +30,7 @@ type RedisSpec struct {
 	RedisExporter     *RedisExporter             `json:"redisExporter,omitempty"`
 	RedisConfig       *RedisConfig               `json:"redisConfig,omitempty"`
 	Storage           *Storage                   `json:"storage,omitempty"`
+	Persistence       *Persistence               `json:"persistence,omitempty"`
 	NodeSelector      map[string]string          `json:"nodeSelector,omitempty"`
 	SecurityContext   *corev1.PodSecurityContext `json:"securityContext,omitempty"`
 	PriorityClassName string                     `json:"priorityClassName,omitempty"`
@@ -41,10 +42,14 @@ type RedisSpec struct {
 	// +kubebuilder:default:={initialDelaySeconds: 1, timeoutSeconds: 1, periodSeconds: 10, successThreshold: 1, failureThreshold:3}
 	LivenessProbe *Probe     `json:"livenessProbe,omitempty" protobuf:"bytes,11,opt,name=livenessProbe"`
 	Sidecars      *[]Sidecar `json:"sidecars,omitempty"`
+	// Service customizes the client service
+	Service *ServiceConfig `json:"service,omitempty"`
 }
 
 // RedisStatus defines the observed state of Redis
 type RedisStatus struct {
+	Persistence       *PersistenceStatus       `json:"persistence,omitempty"`
+	StorageMigrations []StorageMigrationStatus `json:"storageMigrations,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -29,9 +29,17 @@ type RedisClusterSpec struct {
 	// +kubebuilder:default:={livenessProbe:{initialDelaySeconds: 1, timeoutSeconds: 1, periodSeconds: 10, successThreshold: 1, failureThreshold:3}, readinessProbe:{initialDelaySeconds: 1, timeoutSeconds: 1, periodSeconds: 10, successThreshold: 1, failureThreshold:3}}
 	RedisLeader RedisLeader `json:"redisLeader,omitempty"`
 	// +kubebuilder:default:={livenessProbe:{initialDelaySeconds: 1, timeoutSeconds: 1, periodSeconds: 10, successThreshold: 1, failureThreshold:3}, readinessProbe:{initialDelaySeconds: 1, timeoutSeconds: 1, periodSeconds: 10, successThreshold: 1, failureThreshold:3}}
-	RedisFollower     RedisFollower                `json:"redisFollower,omitempty"`
+	RedisFollower RedisFollower `json:"redisFollower,omitempty"`
+	// ReplicasPerShard sets the follower count to this many replicas for each leader, overriding redisFollower.replicas
+	// +kubebuilder:validation:Minimum=0
+	ReplicasPerShard  *int32                       `json:"replicasPerShard,omitempty"`
 	RedisExporter     *RedisExporter               `json:"redisExporter,omitempty"`
 	Storage           *Storage                     `json:"storage,omitempty"`
+	Persistence       *Persistence                 `json:"persistence,omitempty"`
+	Snapshot          *Snapshot                    `json:"snapshot,omitempty"`
+	NodeLossRecovery  *NodeLossRecovery            `json:"nodeLossRecovery,omitempty"`
+	ReplicaPlacement  *ReplicaPlacement            `json:"replicaPlacement,omitempty"`
+	SlotHealing       *SlotHealing                 `json:"slotHealing,omitempty"`
 	NodeSelector      map[string]string            `json:"nodeSelector,omitempty"`
 	SecurityContext   *corev1.PodSecurityContext   `json:"securityContext,omitempty"`
 	PriorityClassName string                       `json:"priorityClassName,omitempty"`
@@ -39,10 +47,28 @@ type RedisClusterSpec struct {
 	Resources         *corev1.ResourceRequirements `json:"resources,omitempty"`
 	TLS               *TLSConfig                   `json:"TLS,omitempty"`
 	Sidecars          *[]Sidecar                   `json:"sidecars,omitempty"`
+	// RestoreLeaderRoles fails the masters served by follower pods back to a leader pod of their shard once the cluster is healthy
+	// +kubebuilder:default=true
+	RestoreLeaderRoles *bool `json:"restoreLeaderRoles,omitempty"`
+	// ExistingData decides how the cluster creation treats leaders holding keys or the identity of a previous cluster,
+	// Refuse blocks the creation, AdoptExisting keeps their data and slots, AllowDataLoss flushes and resets them
+	// +kubebuilder:validation:Enum=Refuse;AdoptExisting;AllowDataLoss
+	// +kubebuilder:default=Refuse
+	ExistingData string `json:"existingData,omitempty"`
+	// MaxReplicationLag is the replication offset in bytes a replica may trail its master by before failovers,
+	// master restarts and slot migrations of its shard wait for it to catch up
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:default=1048576
+	MaxReplicationLag *int64 `json:"maxReplicationLag,omitempty"`
+	// ExternalAccess exposes every redis pod through its own Service and makes the nodes announce its address
+	ExternalAccess *ExternalAccess `json:"externalAccess,omitempty"`
 }
 
 func (cr *RedisClusterSpec) GetReplicaCounts(t string) int32 {
 	replica := cr.Size
+	if t == "follower" && cr.ReplicasPerShard != nil {
+		return cr.GetReplicaCounts("leader") * *cr.ReplicasPerShard
+	}
 	if t == "leader" && cr.RedisLeader.Replicas != nil {
 		replica = cr.RedisLeader.Replicas
 	} else if t == "follower" && cr.RedisFollower.Replicas != nil {
@@ -60,6 +86,29 @@ type RedisLeader struct {
 	PodDisruptionBudget *RedisPodDisruptionBudget `json:"pdb,omitempty"`
 	ReadinessProbe      *Probe                    `json:"readinessProbe,omitempty" protobuf:"bytes,11,opt,name=readinessProbe"`
 	LivenessProbe       *Probe                    `json:"livenessProbe,omitempty" protobuf:"bytes,11,opt,name=livenessProbe"`
+	// ScaleInDryRun holds a scale-in and only reports the slot moves in status.scaleIn.plan
+	ScaleInDryRun bool `json:"scaleInDryRun,omitempty"`
+	// Shards sets the weight or the pinned slots of single leaders, the other leaders have weight 1
+	// +listType=map
+	// +listMapKey=ordinal
+	Shards []ShardSlots `json:"shards,omitempty"`
+	// MaxSlotsPerReconcile throttles the slot migrations, the rebalance resumes on the next reconcile
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=128
+	MaxSlotsPerReconcile *int32 `json:"maxSlotsPerReconcile,omitempty"`
+	// Service customizes the client service of the leaders
+	Service *ServiceConfig `json:"service,omitempty"`
+}
+
+// ShardSlots sets the share of the slots owned by the shard of the leader with the ordinal
+type ShardSlots struct {
+	// +kubebuilder:validation:Minimum=0
+	Ordinal int32 `json:"ordinal"`
+	// Weight of the shard against the other shards when the unpinned slots are spread
+	// +kubebuilder:validation:Minimum=1
+	Weight *int32 `json:"weight,omitempty"`
+	// Slots pins slot ranges like "0-999,5000" to the shard, e.g. to keep the layout of a legacy cluster
+	Slots string `json:"slots,omitempty"`
 }
 
 // RedisFollower interface will have the redis follower configuration
@@ -71,10 +120,149 @@ type RedisFollower struct {
 	PodDisruptionBudget *RedisPodDisruptionBudget `json:"pdb,omitempty"`
 	ReadinessProbe      *Probe                    `json:"readinessProbe,omitempty" protobuf:"bytes,11,opt,name=readinessProbe"`
 	LivenessProbe       *Probe                    `json:"livenessProbe,omitempty" protobuf:"bytes,11,opt,name=livenessProbe"`
+	// Service customizes the client service of the followers
+	Service *ServiceConfig `json:"service,omitempty"`
 }
 
 // RedisClusterStatus defines the observed state of RedisCluster
 type RedisClusterStatus struct {
+	Persistence       *PersistenceStatus       `json:"persistence,omitempty"`
+	Snapshot          *SnapshotStatus          `json:"snapshot,omitempty"`
+	StorageMigrations []StorageMigrationStatus `json:"storageMigrations,omitempty"`
+	// RecoveringPods lost their volume with their node and wait to rejoin the cluster as replica
+	RecoveringPods []RecoveringPod      `json:"recoveringPods,omitempty"`
+	Rebalance      *RebalanceStatus     `json:"rebalance,omitempty"`
+	ScaleIn        *ScaleInStatus       `json:"scaleIn,omitempty"`
+	RollingUpdate  *RollingUpdateStatus `json:"rollingUpdate,omitempty"`
+	SlotCoverage   *SlotCoverageStatus  `json:"slotCoverage,omitempty"`
+	// ReplicationLag lists the replicas of every shard and whether they caught up with their master
+	ReplicationLag []ShardReplicationLag `json:"replicationLag,omitempty"`
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// SlotHealing closes the slots left open by interrupted migrations and handles the slots no master owns, once enabled
+// it replaces the redis-cli --cluster fix the repair runs for the slots no healthy master serves
+type SlotHealing struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// UnassignedSlots is Report to only list the unassigned slots or Reassign to add them to the empty masters or the masters with the fewest slots
+	// +kubebuilder:validation:Enum=Report;Reassign
+	// +kubebuilder:default=Report
+	UnassignedSlots string `json:"unassignedSlots,omitempty"`
+}
+
+// SlotCoverageStatus lists the slot ranges which are open or owned by no master
+type SlotCoverageStatus struct {
+	OpenSlots       string `json:"openSlots,omitempty"`
+	UnassignedSlots string `json:"unassignedSlots,omitempty"`
+}
+
+// ExternalAccess lets clients outside Kubernetes reach the cluster, the MOVED redirects carry the addresses of the pod services
+type ExternalAccess struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Type of the pod services, LoadBalancer services are announced once they are assigned an IP,
+	// NodePort services with the external or else the internal IP of the node of the pod
+	// +kubebuilder:validation:Enum=LoadBalancer;NodePort
+	// +kubebuilder:default=LoadBalancer
+	Type string `json:"type,omitempty"`
+	// Annotations of the pod services, e.g. to request internal load balancers
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// RecoveringPod is a pod whose volume was lost with its node, it rejoins the shard the node it left behind belonged to
+type RecoveringPod struct {
+	Pod string `json:"pod"`
+	// NodeID is the failed node the pod served before, empty when it could not be told apart from other failed nodes
+	NodeID string `json:"nodeID,omitempty"`
+	// MasterID is the master of the shard the pod belonged to when its volume was lost
+	MasterID string `json:"masterID,omitempty"`
+}
+
+// ShardReplicationLag describes how far the replicas of a master trail its replication offset
+type ShardReplicationLag struct {
+	Master   string       `json:"master"`
+	Replicas []ReplicaLag `json:"replicas,omitempty"`
+}
+
+// ReplicaLag tells whether a replica caught up with its master, the lag in bytes changes with every write and is only
+// published as the redis_cluster_replication_lag_bytes metric
+type ReplicaLag struct {
+	Pod    string `json:"pod"`
+	InSync bool   `json:"inSync"`
+}
+
+// RollingUpdateStatus tracks the restart of the outdated pods by the operator
+type RollingUpdateStatus struct {
+	// +kubebuilder:validation:Enum=Updating;Completed
+	Phase string `json:"phase"`
+	// Pod is the pod being failed over or restarted
+	Pod         string `json:"pod,omitempty"`
+	PodsPending int32  `json:"podsPending"`
+}
+
+// ReplicaPlacement keeps masters and their replicas in different failure domains
+type ReplicaPlacement struct {
+	// TopologyKey is the node label defining the failure domains
+	// +kubebuilder:default=kubernetes.io/hostname
+	TopologyKey string `json:"topologyKey,omitempty"`
+	// AutoRepair swaps the masters of replicas sharing a failure domain with their master, e.g. after failovers
+	AutoRepair bool `json:"autoRepair,omitempty"`
+}
+
+// ScaleInStatus tracks the draining of the leaders removed by a scale-in
+type ScaleInStatus struct {
+	// +kubebuilder:validation:Enum=DryRun;Draining;Completed
+	Phase        string `json:"phase"`
+	FromReplicas int32  `json:"fromReplicas"`
+	ToReplicas   int32  `json:"toReplicas"`
+	// FromFollowerReplicas holds the followers at their count before the scale-in until the removed ones are forgotten
+	FromFollowerReplicas int32 `json:"fromFollowerReplicas,omitempty"`
+	// Plan lists the slots moving off the removed leaders
+	Plan []SlotMigration `json:"plan,omitempty"`
+}
+
+// SlotMigration describes slot ranges moving between two pods
+type SlotMigration struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Slots string `json:"slots"`
+}
+
+// RebalanceStatus tracks the migration of hash slots onto the leaders of the cluster
+type RebalanceStatus struct {
+	// +kubebuilder:validation:Enum=Rebalancing;Completed
+	Phase        string       `json:"phase"`
+	SlotsMoved   int32        `json:"slotsMoved"`
+	SlotsPending int32        `json:"slotsPending"`
+	StartTime    *metav1.Time `json:"startTime,omitempty"`
+}
+
+// NodeLossRecovery replaces the volume claim of a pod pinned to a lost node, meant for local PersistentVolumes
+type NodeLossRecovery struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// GracePeriodSeconds a pod stays unschedulable before its volume claim is deleted
+	// +kubebuilder:validation:Minimum=60
+	// +kubebuilder:default=600
+	GracePeriodSeconds int32 `json:"gracePeriodSeconds,omitempty"`
+}
+
+// Snapshot configures CSI VolumeSnapshot backups of the Redis PVCs
+type Snapshot struct {
+	VolumeSnapshotClassName *string `json:"volumeSnapshotClassName,omitempty"`
+	// RestoreFrom is the name of a snapshot group used as dataSource for the PVCs of a new cluster
+	RestoreFrom string `json:"restoreFrom,omitempty"`
+}
+
+// SnapshotStatus describes the last snapshot group taken of the cluster
+type SnapshotStatus struct {
+	Name string `json:"name,omitempty"`
+	// Phase is Saving while the nodes dump their dataset, Creating until every VolumeSnapshot is ready to use, then Ready
+	Phase           string       `json:"phase,omitempty"`
+	CreationTime    *metav1.Time `json:"creationTime,omitempty"`
+	VolumeSnapshots []string     `json:"volumeSnapshots,omitempty"`
+	// SaveStartTimes holds the server time in seconds BGSAVE was started at on every pod while the dumps run
+	SaveStartTimes map[string]int64 `json:"saveStartTimes,omitempty"`
 }
 
 // RedisPodDisruptionBudget configure a PodDisruptionBudget on the resource (leader/follower)
@@ -82,6 +270,9 @@ type RedisPodDisruptionBudget struct {
 	Enabled        bool   `json:"enabled,omitempty"`
 	MinAvailable   *int32 `json:"minAvailable,omitempty"`
 	MaxUnavailable *int32 `json:"maxUnavailable,omitempty"`
+	// ProtectMasters adds a budget keeping the masters of the cluster from eviction until their failover to a replica,
+	// the budgets of the roles then only cover their replicas and default to maxUnavailable 1
+	ProtectMasters bool `json:"protectMasters,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -18,13 +18,16 @@ package controllers
 
 import (
 	"context"
+	"reflect"
 	"time"
 
 	"redis-operator/k8sutils"
 
 	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -34,8 +37,9 @@ import (
 // RedisReconciler reconciles a Redis object
 type RedisReconciler struct {
 	client.Client
-	Log    logr.Logger
-	Scheme *runtime.Scheme
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
 }
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims
@@ -52,7 +56,7 @@ func (r *RedisReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 		return ctrl.Result{}, err
 	}
 
-	if err := k8sutils.HandleRedisFinalizer(instance, r.Client); err != nil {
+	if err := k8sutils.HandleRedisFinalizer(instance, r.Client, r.Recorder); err != nil {
 		return ctrl.Result{}, err
 	}
 
@@ -60,6 +64,29 @@ func (r *RedisReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 		return ctrl.Result{}, err
 	}
 
+	if err := k8sutils.ValidateStorage(instance.Spec.Storage, instance.Spec.KubernetesConfig.Resources); err != nil {
+		reqLogger.Error(err, "Invalid storage configuration")
+		r.Recorder.Event(instance, corev1.EventTypeWarning, "InvalidStorage", err.Error())
+		return ctrl.Result{}, err
+	}
+
+	storageMigrations, err := k8sutils.ReconcileRedisStorageMigration(instance)
+	if err != nil {
+		reqLogger.Error(err, "Failed to migrate storage")
+		r.Recorder.Event(instance, corev1.EventTypeWarning, "StorageMigrationFailed", err.Error())
+	}
+	if !reflect.DeepEqual(instance.Status.StorageMigrations, storageMigrations) {
+		instance.Status.StorageMigrations = storageMigrations
+		if err := r.Client.Status().Update(context.TODO(), instance); err != nil {
+			return ctrl.Result{}, err
+		}
+		for _, migration := range storageMigrations {
+			if migration.Phase == "AwaitingConfirmation" {
+				r.Recorder.Eventf(instance, corev1.EventTypeNormal, "StorageMigrated", "%s runs on StorageClass %s, set annotation %s=%s to delete the previous claims", migration.StatefulSet, migration.StorageClassName, k8sutils.StorageMigrationConfirmAnnotation, migration.StorageClassName)
+			}
+		}
+	}
+
 	err = k8sutils.CreateStandaloneRedis(instance)
 	if err != nil {
 		return ctrl.Result{}, err
@@ -69,11 +96,26 @@ func (r *RedisReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 		return ctrl.Result{}, err
 	}
 
+	if err := k8sutils.ValidatePersistence(instance.Spec.Persistence, instance.Spec.Storage); err != nil {
+		reqLogger.Error(err, "Invalid persistence configuration")
+		r.Recorder.Event(instance, corev1.EventTypeWarning, "InvalidPersistence", err.Error())
+	} else if redisInfo, err := k8sutils.GetStatefulSet(instance.Namespace, instance.ObjectMeta.Name); err == nil && redisInfo.Status.ReadyReplicas == 1 {
+		persistenceStatus, err := k8sutils.ReconcileRedisPersistence(instance)
+		if err != nil {
+			reqLogger.Error(err, "Failed to reconcile persistence")
+		} else if !reflect.DeepEqual(instance.Status.Persistence, persistenceStatus) {
+			instance.Status.Persistence = persistenceStatus
+			if err := r.Client.Status().Update(context.TODO(), instance); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+	}
+
 	if instance.Spec.RedisExporter != nil && instance.Spec.RedisExporter.Enabled {
-		if err := k8sutils.CreateServiceMonitor(instance.Namespace, instance.Annotations["creator"], instance.Name, false); err != nil {
+		if err := k8sutils.CreateServiceMonitor(instance.Namespace, instance.Annotations["creator"], instance.Name, false, k8sutils.RedisAsOwner(instance)); err != nil {
 			reqLogger.Error(err, "Failed to create ServiceMonitor")
 		}
-		if err := k8sutils.CreateGrafanaDashBoard(instance.Namespace, instance.Annotations["creator"], instance.Name, false); err != nil {
+		if err := k8sutils.CreateGrafanaDashBoard(instance.Namespace, instance.Annotations["creator"], instance.Name, false, k8sutils.RedisAsOwner(instance)); err != nil {
 			reqLogger.Error(err, "Failed to create GrafanaDashboard")
 		}
 	}
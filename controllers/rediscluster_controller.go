@@ -18,16 +18,25 @@ package controllers
 
 import (
 	"context"
+	"reflect"
 	"strconv"
+	"strings"
 	"time"
 
 	"redis-operator/k8sutils"
 
 	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	redisv1beta1 "redis-operator/api/v1beta1"
 )
@@ -35,8 +44,9 @@ import (
 // RedisClusterReconciler reconciles a RedisCluster object
 type RedisClusterReconciler struct {
 	client.Client
-	Log    logr.Logger
-	Scheme *runtime.Scheme
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
 }
 
 // Reconcile is part of the main kubernetes reconciliation loop
@@ -57,7 +67,7 @@ func (r *RedisClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request
 	followerReplicas := instance.Spec.GetReplicaCounts("follower")
 	totalReplicas := leaderReplicas + followerReplicas
 
-	if err := k8sutils.HandleRedisClusterFinalizer(instance, r.Client); err != nil {
+	if err := k8sutils.HandleRedisClusterFinalizer(instance, r.Client, r.Recorder); err != nil {
 		return ctrl.Result{}, err
 	}
 
@@ -65,6 +75,116 @@ func (r *RedisClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		return ctrl.Result{}, err
 	}
 
+	if err := k8sutils.ValidateStorage(instance.Spec.Storage, instance.Spec.KubernetesConfig.Resources); err != nil {
+		reqLogger.Error(err, "Invalid storage configuration")
+		r.Recorder.Event(instance, corev1.EventTypeWarning, "InvalidStorage", err.Error())
+		return ctrl.Result{}, err
+	}
+
+	if err := k8sutils.RestoreRedisClusterPVCs(r.Client, instance); err != nil {
+		reqLogger.Error(err, "Failed to restore PVCs from snapshot")
+		r.Recorder.Event(instance, corev1.EventTypeWarning, "RestoreFailed", err.Error())
+		return ctrl.Result{}, err
+	}
+
+	storageMigrations, err := k8sutils.ReconcileRedisClusterStorageMigration(instance)
+	if err != nil {
+		reqLogger.Error(err, "Failed to migrate storage")
+		r.Recorder.Event(instance, corev1.EventTypeWarning, "StorageMigrationFailed", err.Error())
+	}
+	if !reflect.DeepEqual(instance.Status.StorageMigrations, storageMigrations) {
+		instance.Status.StorageMigrations = storageMigrations
+		if err := r.Client.Status().Update(context.TODO(), instance); err != nil {
+			return ctrl.Result{}, err
+		}
+		for _, migration := range storageMigrations {
+			if migration.Phase == "AwaitingConfirmation" {
+				r.Recorder.Eventf(instance, corev1.EventTypeNormal, "StorageMigrated", "%s runs on StorageClass %s, set annotation %s=%s to delete the previous claims", migration.StatefulSet, migration.StorageClassName, k8sutils.StorageMigrationConfirmAnnotation, migration.StorageClassName)
+			}
+		}
+	}
+
+	// The cluster view is read once and shared by every decision of this reconcile,
+	// steps changing the topology requeue so the next decisions work on a fresh view
+	topology := k8sutils.GetClusterTopology(instance)
+
+	resolve := instance.GetAnnotations()[k8sutils.ResolveTopologyAnnotation] == "true"
+	degraded, resolveActions, err := k8sutils.ReconcileRedisClusterConsistency(instance, topology, resolve)
+	for _, action := range resolveActions {
+		r.Recorder.Event(instance, corev1.EventTypeNormal, "TopologyResolved", action)
+	}
+	if err != nil {
+		reqLogger.Error(err, "Failed to resolve the redis cluster topology conflicts")
+		r.Recorder.Event(instance, corev1.EventTypeWarning, "TopologyResolveFailed", err.Error())
+	}
+	if current := meta.FindStatusCondition(instance.Status.Conditions, degraded.Type); current == nil || current.Status != degraded.Status || current.Message != degraded.Message {
+		if degraded.Status == metav1.ConditionTrue {
+			r.Recorder.Event(instance, corev1.EventTypeWarning, degraded.Reason, degraded.Message)
+		}
+		meta.SetStatusCondition(&instance.Status.Conditions, degraded)
+		if err := r.Client.Status().Update(context.TODO(), instance); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	// Scaling, failovers and slot moves would act on a topology the nodes disagree about, the statefulsets,
+	// services and disruption budgets keep following the spec meanwhile
+	topologyPaused := degraded.Status == metav1.ConditionTrue
+	if topologyPaused {
+		reqLogger.Info("Redis cluster topology is inconsistent, automated topology changes are paused", "Conflicts", degraded.Message)
+	} else if resolve {
+		delete(instance.Annotations, k8sutils.ResolveTopologyAnnotation)
+		if err := r.Client.Update(context.TODO(), instance); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Failovers, master restarts and slot migrations below wait for the replicas measured here to catch up
+	replicationLag := k8sutils.GetRedisClusterReplicationLag(instance, topology)
+	lagChanged := k8sutils.ReplicationLagChanged(instance.Status.ReplicationLag, replicationLag)
+	instance.Status.ReplicationLag = replicationLag
+	if lagChanged {
+		if err := r.Client.Status().Update(context.TODO(), instance); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// The disruption budget of the masters selects this label, a master pod is evicted once it failed over
+	if err := k8sutils.ReconcileRedisClusterRoleLabels(instance, topology); err != nil {
+		reqLogger.Error(err, "Failed to label the redis cluster pods with their role")
+	}
+
+	if !topologyPaused {
+		drainActions, blocked, err := k8sutils.ReconcileRedisClusterDrain(instance, topology)
+		for _, action := range drainActions {
+			r.Recorder.Event(instance, corev1.EventTypeNormal, "DrainFailover", action)
+		}
+		for _, podName := range blocked {
+			r.Recorder.Eventf(instance, corev1.EventTypeWarning, "DrainFailoverBlocked", "Master %s is draining without a caught up replica on another node", podName)
+		}
+		if err != nil {
+			reqLogger.Error(err, "Failed to fail over the masters of draining pods")
+			r.Recorder.Event(instance, corev1.EventTypeWarning, "DrainFailoverFailed", err.Error())
+		}
+		if len(drainActions) > 0 {
+			return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+		}
+	}
+
+	scaleInStatus, err := k8sutils.ReconcileRedisClusterScaleIn(instance, topology, topologyPaused)
+	if err != nil {
+		reqLogger.Error(err, "Failed to drain leaders for scale-in")
+		r.Recorder.Event(instance, corev1.EventTypeWarning, "ScaleInFailed", err.Error())
+	}
+	if !reflect.DeepEqual(instance.Status.ScaleIn, scaleInStatus) {
+		instance.Status.ScaleIn = scaleInStatus
+		if err := r.Client.Status().Update(context.TODO(), instance); err != nil {
+			return ctrl.Result{}, err
+		}
+		if scaleInStatus != nil && scaleInStatus.Phase == "Completed" {
+			return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+		}
+	}
+
 	err = k8sutils.CreateRedisLeader(instance)
 	if err != nil {
 		return ctrl.Result{}, err
@@ -96,12 +216,52 @@ func (r *RedisClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request
 	if err != nil {
 		return ctrl.Result{}, err
 	}
+	err = k8sutils.ReconcileRedisMasterPodDisruptionBudget(instance)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	externalServicesDeleted, err := k8sutils.ReconcileRedisClusterExternalServices(instance)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	err = k8sutils.PruneRedisClusterResources(instance, r.Recorder)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	recoveringPods, err := k8sutils.ReconcileNodeLossRecovery(instance, topology)
+	if err != nil {
+		reqLogger.Error(err, "Failed to recover pods of lost nodes")
+		r.Recorder.Event(instance, corev1.EventTypeWarning, "NodeLossRecoveryFailed", err.Error())
+	}
+	if !reflect.DeepEqual(instance.Status.RecoveringPods, recoveringPods) {
+		recorded := map[string]bool{}
+		for _, pod := range instance.Status.RecoveringPods {
+			recorded[pod.Pod] = true
+		}
+		for _, pod := range recoveringPods {
+			if !recorded[pod.Pod] {
+				r.Recorder.Eventf(instance, corev1.EventTypeWarning, "NodeLost", "Deleted the volume claim of %s which was pinned to a lost node", pod.Pod)
+			}
+		}
+		instance.Status.RecoveringPods = recoveringPods
+		if err := r.Client.Status().Update(context.TODO(), instance); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+	}
+	if len(recoveringPods) > 0 {
+		reqLogger.Info("Waiting for recovered pods to rejoin the cluster", "Pods", recoveringPods)
+		return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+	}
 
 	if instance.Spec.RedisExporter != nil && instance.Spec.RedisExporter.Enabled {
-		if err := k8sutils.CreateServiceMonitor(instance.Namespace, instance.Annotations["creator"], instance.Name, true); err != nil {
+		if err := k8sutils.CreateServiceMonitor(instance.Namespace, instance.Annotations["creator"], instance.Name, true, k8sutils.RedisClusterAsOwner(instance)); err != nil {
 			reqLogger.Error(err, "Failed to create ServiceMonitor")
 		}
-		if err := k8sutils.CreateGrafanaDashBoard(instance.Namespace, instance.Annotations["creator"], instance.Name, true); err != nil {
+		if err := k8sutils.CreateGrafanaDashBoard(instance.Namespace, instance.Annotations["creator"], instance.Name, true, k8sutils.RedisClusterAsOwner(instance)); err != nil {
 			reqLogger.Error(err, "Failed to create GrafanaDashboard")
 		}
 	}
@@ -124,38 +284,288 @@ func (r *RedisClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		reqLogger.Info("Redis leader and follower nodes are not ready yet", "Ready.Replicas", strconv.Itoa(int(redisLeaderInfo.Status.ReadyReplicas)), "Expected.Replicas", leaderReplicas)
 		return ctrl.Result{RequeueAfter: time.Second * 120}, nil
 	}
+	governing, err := k8sutils.CheckRedisClusterGoverningServices(instance)
+	if err != nil {
+		reqLogger.Error(err, "Failed to check the governing services of the statefulsets")
+	} else if current := meta.FindStatusCondition(instance.Status.Conditions, governing.Type); current == nil || current.Status != governing.Status || current.Message != governing.Message {
+		if governing.Status == metav1.ConditionFalse {
+			r.Recorder.Event(instance, corev1.EventTypeWarning, governing.Reason, governing.Message)
+		}
+		meta.SetStatusCondition(&instance.Status.Conditions, governing)
+		if err := r.Client.Status().Update(context.TODO(), instance); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if err := k8sutils.ConfigureRedisClusterHostnames(instance); err != nil {
+		reqLogger.Error(err, "Failed to configure redis cluster hostnames")
+		r.Recorder.Event(instance, corev1.EventTypeWarning, "HostnameConfigFailed", err.Error())
+	}
+	if err := k8sutils.ConfigureRedisClusterExternalAccess(instance, externalServicesDeleted); err != nil {
+		reqLogger.Error(err, "Failed to configure the announced external addresses")
+		r.Recorder.Event(instance, corev1.EventTypeWarning, "ExternalAccessConfigFailed", err.Error())
+	}
+	if err := k8sutils.ValidatePersistence(instance.Spec.Persistence, instance.Spec.Storage); err != nil {
+		reqLogger.Error(err, "Invalid persistence configuration")
+		r.Recorder.Event(instance, corev1.EventTypeWarning, "InvalidPersistence", err.Error())
+	} else {
+		persistenceStatus, err := k8sutils.ReconcileRedisClusterPersistence(instance)
+		if err != nil {
+			reqLogger.Error(err, "Failed to reconcile persistence")
+		} else if !reflect.DeepEqual(instance.Status.Persistence, persistenceStatus) {
+			instance.Status.Persistence = persistenceStatus
+			if err := r.Client.Status().Update(context.TODO(), instance); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+	}
+
+	if topologyPaused {
+		return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+	}
+
+	if instance.Status.ScaleIn != nil && instance.Status.ScaleIn.Phase != "Completed" {
+		reqLogger.Info("Redis leaders are scaling in", "Phase", instance.Status.ScaleIn.Phase, "From.Replicas", instance.Status.ScaleIn.FromReplicas, "To.Replicas", instance.Status.ScaleIn.ToReplicas)
+		return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+	}
+
+	ghostActions, err := k8sutils.ReconcileRedisClusterGhostNodes(instance, topology)
+	for _, action := range ghostActions {
+		r.Recorder.Event(instance, corev1.EventTypeNormal, "GhostNodeRemoved", action)
+	}
+	if err != nil {
+		reqLogger.Error(err, "Failed to clean up ghost nodes")
+		r.Recorder.Event(instance, corev1.EventTypeWarning, "GhostNodeCleanupFailed", err.Error())
+	}
+	if len(ghostActions) > 0 {
+		return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+	}
+
+	rollingUpdateStatus, err := k8sutils.ReconcileRedisClusterRollingUpdate(instance, topology)
+	if err != nil {
+		reqLogger.Error(err, "Failed to roll out the redis cluster pods")
+		r.Recorder.Event(instance, corev1.EventTypeWarning, "RollingUpdateFailed", err.Error())
+	}
+	if !reflect.DeepEqual(instance.Status.RollingUpdate, rollingUpdateStatus) {
+		instance.Status.RollingUpdate = rollingUpdateStatus
+		if err := r.Client.Status().Update(context.TODO(), instance); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if rollingUpdateStatus != nil && rollingUpdateStatus.Phase == "Updating" {
+		reqLogger.Info("Redis cluster pods are rolling out", "Pod", rollingUpdateStatus.Pod, "Pods.Pending", rollingUpdateStatus.PodsPending)
+		return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+	}
+
+	rebalanceStatus, err := k8sutils.ReconcileRedisClusterScaleOut(instance, topology)
+	if err != nil {
+		reqLogger.Error(err, "Failed to rebalance redis cluster slots")
+		r.Recorder.Event(instance, corev1.EventTypeWarning, "RebalanceFailed", err.Error())
+	}
+	if !reflect.DeepEqual(instance.Status.Rebalance, rebalanceStatus) {
+		instance.Status.Rebalance = rebalanceStatus
+		if err := r.Client.Status().Update(context.TODO(), instance); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+	}
+	if rebalanceStatus != nil && rebalanceStatus.Phase == "Rebalancing" {
+		reqLogger.Info("Redis cluster slots are rebalancing", "Slots.Moved", rebalanceStatus.SlotsMoved, "Slots.Pending", rebalanceStatus.SlotsPending)
+		return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+	}
+
+	if instance.GetAnnotations()[k8sutils.RebuildAnnotation] == "true" {
+		reqLogger.Info("Rebuild requested, resetting and flushing every redis node")
+		if err := k8sutils.ExecuteFailoverOperation(instance); err != nil {
+			return ctrl.Result{RequeueAfter: time.Second * 10}, err
+		}
+		delete(instance.Annotations, k8sutils.RebuildAnnotation)
+		if err := r.Client.Update(context.TODO(), instance); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.Recorder.Event(instance, corev1.EventTypeWarning, "ClusterRebuilt", "Every redis node was reset and flushed")
+		return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+	}
+
 	reqLogger.Info("Creating redis cluster by executing cluster creation commands", "Leaders.Ready", strconv.Itoa(int(redisLeaderInfo.Status.ReadyReplicas)), "Followers.Ready", strconv.Itoa(int(redisFollowerInfo.Status.ReadyReplicas)))
-	if k8sutils.CheckRedisNodeCount(instance, "") != totalReplicas {
-		leaderCount := k8sutils.CheckRedisNodeCount(instance, "leader")
-		if leaderCount != leaderReplicas {
+	if k8sutils.CheckRedisNodeCount(instance, topology, "") != totalReplicas {
+		leaderCount := k8sutils.CheckRedisNodeCount(instance, topology, "leader")
+		if leaderCount < leaderReplicas {
 			reqLogger.Info("Not all leader are part of the cluster...", "Leaders.Count", leaderCount, "Instance.Size", leaderReplicas)
-			k8sutils.ExecuteRedisClusterCommand(instance)
+			condition, err := k8sutils.CheckRedisClusterPreflight(instance, topology)
+			if err != nil {
+				reqLogger.Error(err, "Could not inspect the leaders before the cluster creation")
+				return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+			}
+			if current := meta.FindStatusCondition(instance.Status.Conditions, condition.Type); current == nil || current.Status != condition.Status || current.Message != condition.Message {
+				// Refused, adopted and discarded data all deserve the attention of the user
+				if strings.HasPrefix(condition.Reason, "ExistingData") {
+					r.Recorder.Event(instance, corev1.EventTypeWarning, condition.Reason, condition.Message)
+				}
+				meta.SetStatusCondition(&instance.Status.Conditions, condition)
+				if err := r.Client.Status().Update(context.TODO(), instance); err != nil {
+					return ctrl.Result{}, err
+				}
+			}
+			if condition.Status == metav1.ConditionFalse {
+				reqLogger.Info("Cluster creation blocked by existing data on the leaders", "Message", condition.Message)
+				return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+			}
+			k8sutils.ExecuteRedisClusterCommand(instance, topology)
 		} else {
 			if followerReplicas > 0 {
 				reqLogger.Info("All leader are part of the cluster, adding follower/replicas", "Leaders.Count", leaderCount, "Instance.Size", leaderReplicas, "Follower.Replicas", followerReplicas)
-				k8sutils.ExecuteRedisReplicationCommand(instance)
+				k8sutils.ExecuteRedisReplicationCommand(instance, topology)
 			} else {
 				reqLogger.Info("no follower/replicas configured, skipping replication configuration", "Leaders.Count", leaderCount, "Leader.Size", leaderReplicas, "Follower.Replicas", followerReplicas)
 			}
 		}
 	} else {
 		reqLogger.Info("Redis leader count is desired")
-		if k8sutils.CheckRedisClusterState(instance) >= int(totalReplicas)-1 {
-			reqLogger.Info("Redis leader is not desired, executing failover operation")
-			err = k8sutils.ExecuteFailoverOperation(instance)
+		if failed := k8sutils.CheckRedisClusterState(instance, topology); failed > 0 {
+			reqLogger.Info("Redis cluster has failed nodes, executing repair operations", "Failed.Nodes", failed)
+			actions, err := k8sutils.RepairRedisCluster(instance, topology)
+			for _, action := range actions {
+				r.Recorder.Event(instance, corev1.EventTypeNormal, "ClusterRepaired", action)
+			}
 			if err != nil {
-				return ctrl.Result{RequeueAfter: time.Second * 10}, err
+				reqLogger.Error(err, "Failed to repair redis cluster")
+				r.Recorder.Event(instance, corev1.EventTypeWarning, "RepairFailed", err.Error())
+				return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+			}
+			if len(actions) > 0 {
+				return ctrl.Result{RequeueAfter: time.Second * 10}, nil
 			}
 		}
+
+		slotCoverage, err := k8sutils.ReconcileRedisClusterSlotCoverage(instance, topology)
+		if err != nil {
+			reqLogger.Error(err, "Failed to heal redis cluster slots")
+			r.Recorder.Event(instance, corev1.EventTypeWarning, "SlotHealingFailed", err.Error())
+		}
+		if !reflect.DeepEqual(instance.Status.SlotCoverage, slotCoverage) {
+			if slotCoverage != nil {
+				r.Recorder.Eventf(instance, corev1.EventTypeWarning, "SlotsNotCovered", "Open slots: %q, unassigned slots: %q", slotCoverage.OpenSlots, slotCoverage.UnassignedSlots)
+			}
+			instance.Status.SlotCoverage = slotCoverage
+			if err := r.Client.Status().Update(context.TODO(), instance); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+
+		moves, err := k8sutils.ReconcileRedisClusterReplicas(instance, topology)
+		if err != nil {
+			reqLogger.Error(err, "Failed to balance redis cluster replicas")
+			r.Recorder.Event(instance, corev1.EventTypeWarning, "ReplicaBalanceFailed", err.Error())
+		} else if moves > 0 {
+			r.Recorder.Eventf(instance, corev1.EventTypeNormal, "ReplicasBalanced", "Moved %d replicas between masters", moves)
+			return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+		}
+
+		placement, err := k8sutils.ReconcileRedisClusterPlacement(instance, topology)
+		if err != nil {
+			reqLogger.Error(err, "Failed to check redis cluster replica placement")
+			r.Recorder.Event(instance, corev1.EventTypeWarning, "PlacementFailed", err.Error())
+		} else if current := meta.FindStatusCondition(instance.Status.Conditions, placement.Type); current == nil || current.Status != placement.Status || current.Message != placement.Message {
+			if placement.Status == metav1.ConditionFalse {
+				r.Recorder.Event(instance, corev1.EventTypeWarning, placement.Reason, placement.Message)
+			}
+			meta.SetStatusCondition(&instance.Status.Conditions, placement)
+			if err := r.Client.Status().Update(context.TODO(), instance); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+
+		roles, promoted, err := k8sutils.ReconcileRedisClusterRoles(instance, topology)
+		if err != nil {
+			reqLogger.Error(err, "Failed to restore the leader roles")
+			r.Recorder.Event(instance, corev1.EventTypeWarning, "LeaderRoleRestoreFailed", err.Error())
+		} else if current := meta.FindStatusCondition(instance.Status.Conditions, roles.Type); current == nil || current.Status != roles.Status || current.Message != roles.Message {
+			if roles.Status == metav1.ConditionFalse {
+				r.Recorder.Event(instance, corev1.EventTypeWarning, roles.Reason, roles.Message)
+			}
+			meta.SetStatusCondition(&instance.Status.Conditions, roles)
+			if err := r.Client.Status().Update(context.TODO(), instance); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		if promoted != "" {
+			r.Recorder.Eventf(instance, corev1.EventTypeNormal, "LeaderRoleRestored", "Failed the master of the shard over to %s", promoted)
+			return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+		}
+
+		snapshotStatus, err := k8sutils.ReconcileRedisClusterSnapshot(r.Client, instance)
+		if err != nil {
+			reqLogger.Error(err, "Failed to snapshot redis cluster")
+			r.Recorder.Event(instance, corev1.EventTypeWarning, "SnapshotFailed", err.Error())
+		} else if !reflect.DeepEqual(instance.Status.Snapshot, snapshotStatus) {
+			instance.Status.Snapshot = snapshotStatus
+			if err := r.Client.Status().Update(context.TODO(), instance); err != nil {
+				return ctrl.Result{}, err
+			}
+			if snapshotStatus.Phase == "Ready" {
+				r.Recorder.Eventf(instance, corev1.EventTypeNormal, "SnapshotCreated", "Created VolumeSnapshot group %s", snapshotStatus.Name)
+			}
+		}
+		if snapshotStatus != nil && snapshotStatus.Phase != "" && snapshotStatus.Phase != "Ready" {
+			reqLogger.Info("Redis cluster snapshot is in progress", "Snapshot", snapshotStatus.Name, "Phase", snapshotStatus.Phase)
+			return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+		}
 		return ctrl.Result{RequeueAfter: time.Second * 120}, nil
 	}
 	reqLogger.Info("Will reconcile redis cluster operator in again 10 seconds")
 	return ctrl.Result{RequeueAfter: time.Second * 10}, nil
 }
 
+// podNodeNameField indexes the cached pods by the node they are scheduled on
+const podNodeNameField = "spec.nodeName"
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *RedisClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &corev1.Pod{}, podNodeNameField, func(obj client.Object) []string {
+		pod, ok := obj.(*corev1.Pod)
+		if !ok || pod.Spec.NodeName == "" {
+			return nil
+		}
+		return []string{pod.Spec.NodeName}
+	})
+	if err != nil {
+		return err
+	}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&redisv1beta1.RedisCluster{}).
+		Watches(&source.Kind{Type: &corev1.Node{}}, handler.EnqueueRequestsFromMapFunc(r.clustersOnCordonedNode)).
+		Watches(&source.Kind{Type: &corev1.Pod{}}, handler.EnqueueRequestsFromMapFunc(r.clusterOfDrainingPod)).
 		Complete(r)
 }
+
+// clustersOnCordonedNode enqueues the clusters with pods on a cordoned node so their masters fail over before the drain
+func (r *RedisClusterReconciler) clustersOnCordonedNode(obj client.Object) []reconcile.Request {
+	node, ok := obj.(*corev1.Node)
+	if !ok || !node.Spec.Unschedulable {
+		return nil
+	}
+	var pods corev1.PodList
+	err := r.Client.List(context.TODO(), &pods, client.MatchingLabels{"redis_setup_type": "cluster"}, client.MatchingFields{podNodeNameField: node.Name})
+	if err != nil {
+		r.Log.Error(err, "Could not list the redis cluster pods of the cordoned node", "Node", node.Name)
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, cluster := range k8sutils.GetPodsRedisClusters(pods.Items) {
+		requests = append(requests, reconcile.Request{NamespacedName: cluster})
+	}
+	return requests
+}
+
+// clusterOfDrainingPod enqueues the cluster of a pod which is terminating or marked for eviction
+func (r *RedisClusterReconciler) clusterOfDrainingPod(obj client.Object) []reconcile.Request {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || !k8sutils.IsPodDraining(pod, nil) {
+		return nil
+	}
+	cluster := k8sutils.GetPodRedisCluster(pod)
+	if cluster.Name == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: cluster}}
+}
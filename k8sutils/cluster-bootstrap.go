@@ -0,0 +1,140 @@
+package k8sutils
+
+import (
+	"fmt"
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// replicaAttachRetries is the number of seconds a new replica waits to learn its master through gossip
+const replicaAttachRetries = 10
+
+// createRedisClusterNative will assign the slots to the leaders and make them meet without redis-cli
+func createRedisClusterNative(cr *redisv1beta1.RedisCluster, topology *ClusterTopology) error {
+	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
+	if isClusterFormedByPods(topology) {
+		logger.Info("Cluster already owns slots, new leaders join through the rebalance", "Seed", topology.SeedPod)
+		return nil
+	}
+
+	replicas := int(cr.Spec.GetReplicaCounts("leader"))
+	ranges := splitSlotRanges(replicas)
+	// Leaders adopted with their data keep their slots, the planned ranges skip those slots
+	owned := make([][]int, replicas)
+	taken := map[int]bool{}
+	for podCount := 0; podCount < replicas; podCount++ {
+		client := configureRedisClient(cr, cr.ObjectMeta.Name+"-leader-"+strconv.Itoa(podCount))
+		nodes, err := getClusterNodes(client)
+		client.Close()
+		if err != nil {
+			return err
+		}
+		if myself := findClusterNode(nodes, "myself"); myself != nil {
+			owned[podCount] = myself.SlotList()
+			for _, slot := range owned[podCount] {
+				taken[slot] = true
+			}
+		}
+	}
+
+	var ips []string
+	for podCount := 0; podCount < replicas; podCount++ {
+		pod := RedisDetails{PodName: cr.ObjectMeta.Name + "-leader-" + strconv.Itoa(podCount), Namespace: cr.Namespace}
+		ip := strings.Trim(getRedisServerIP(pod), "[]")
+		if ip == "" {
+			return fmt.Errorf("no IP found for %s", pod.PodName)
+		}
+		ips = append(ips, ip)
+		if len(owned[podCount]) > 0 {
+			logger.Info("Leader keeps the slots it owns", "Pod", pod.PodName, "Slots", formatSlotRanges(owned[podCount]))
+			continue
+		}
+
+		var slots []int
+		for slot := ranges[podCount][0]; slot <= ranges[podCount][1]; slot++ {
+			if !taken[slot] {
+				slots = append(slots, slot)
+			}
+		}
+		client := configureRedisClient(cr, pod.PodName)
+		// Distinct config epochs let the leaders agree on the slot owners right away
+		err := client.Do("cluster", "set-config-epoch", podCount+1).Err()
+		if isConfigEpochKept(err) {
+			err = nil
+		}
+		if err == nil && len(slots) > 0 {
+			err = client.ClusterAddSlots(slots...).Err()
+		}
+		client.Close()
+		if err != nil {
+			return err
+		}
+		logger.Info("Slots added to leader", "Pod", pod.PodName, "Slots", formatSlotRanges(slots))
+	}
+
+	client := configureRedisClient(cr, cr.ObjectMeta.Name+"-leader-0")
+	defer client.Close()
+	for _, ip := range ips[1:] {
+		if err := client.ClusterMeet(ip, "6379").Err(); err != nil {
+			return err
+		}
+	}
+	logger.Info("Redis cluster created with native commands", "Leaders", replicas)
+	return nil
+}
+
+// isConfigEpochKept checks if CLUSTER SET-CONFIG-EPOCH was refused because the node already has an epoch or knows
+// other nodes, such a node keeps its epoch and redis resolves epoch collisions
+func isConfigEpochKept(err error) bool {
+	return err != nil && (strings.Contains(err.Error(), "already non-zero") || strings.Contains(err.Error(), "does not know any other node"))
+}
+
+// leadersOwnSlots checks if any leader owns slots, redis-cli refuses to create a cluster on such nodes
+func leadersOwnSlots(cr *redisv1beta1.RedisCluster) (bool, error) {
+	for podCount := 0; podCount < int(cr.Spec.GetReplicaCounts("leader")); podCount++ {
+		client := configureRedisClient(cr, cr.ObjectMeta.Name+"-leader-"+strconv.Itoa(podCount))
+		nodes, err := getClusterNodes(client)
+		client.Close()
+		if err != nil {
+			return false, err
+		}
+		if myself := findClusterNode(nodes, "myself"); myself != nil && len(myself.Slots) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// attachRedisReplicaNative will make the pod meet the cluster and replicate the master without redis-cli
+func attachRedisReplicaNative(cr *redisv1beta1.RedisCluster, podName, masterID, masterIP string) error {
+	client := configureRedisClient(cr, podName)
+	defer client.Close()
+	if err := client.ClusterMeet(masterIP, "6379").Err(); err != nil {
+		return err
+	}
+	for retry := 0; retry < replicaAttachRetries; retry++ {
+		nodes, err := getClusterNodes(client)
+		if err == nil && findClusterNodeByID(nodes, masterID) != nil {
+			return client.ClusterReplicate(masterID).Err()
+		}
+		time.Sleep(time.Second)
+	}
+	return fmt.Errorf("master %s was not gossiped to %s", masterID, podName)
+}
+
+// splitSlotRanges will split the hash slots into contiguous ranges of even size
+func splitSlotRanges(count int) [][2]int {
+	var ranges [][2]int
+	start := 0
+	for i := 0; i < count; i++ {
+		size := totalHashSlots / count
+		if i < totalHashSlots%count {
+			size++
+		}
+		ranges = append(ranges, [2]int{start, start + size - 1})
+		start += size
+	}
+	return ranges
+}
@@ -0,0 +1,42 @@
+package k8sutils
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestSplitSlotRanges(t *testing.T) {
+	var tests = []struct {
+		count int
+		want  [][2]int
+	}{
+		{1, [][2]int{{0, 16383}}},
+		{3, [][2]int{{0, 5461}, {5462, 10922}, {10923, 16383}}},
+		{4, [][2]int{{0, 4095}, {4096, 8191}, {8192, 12287}, {12288, 16383}}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.count), func(t *testing.T) {
+			if ranges := splitSlotRanges(tt.count); !reflect.DeepEqual(ranges, tt.want) {
+				t.Errorf("got ranges %v, want %v", ranges, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsConfigEpochKept(t *testing.T) {
+	var tests = []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{fmt.Errorf("ERR Node config epoch is already non-zero"), true},
+		{fmt.Errorf("ERR The user can assign a config epoch only when the node does not know any other node."), true},
+		{fmt.Errorf("NOAUTH Authentication required."), false},
+	}
+	for _, tt := range tests {
+		if got := isConfigEpochKept(tt.err); got != tt.want {
+			t.Errorf("isConfigEpochKept(%v) = %t, want %t", tt.err, got, tt.want)
+		}
+	}
+}
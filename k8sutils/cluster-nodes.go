@@ -0,0 +1,331 @@
+package k8sutils
+
+import (
+	"context"
+	"fmt"
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterNode is a node parsed from a CLUSTER NODES line
+type ClusterNode struct {
+	ID      string
+	IP      string
+	Port    int
+	BusPort int
+	// Hostname is announced by Redis 7+ nodes configured with cluster-announce-hostname
+	Hostname    string
+	Flags       []string
+	MasterID    string
+	PingSent    int64
+	PongRecv    int64
+	ConfigEpoch int64
+	LinkState   string
+	Slots       []SlotRange
+	// Migrating and Importing map the open slots of the node to the node ID on the other side
+	Migrating map[int]string
+	Importing map[int]string
+}
+
+// SlotRange is an inclusive range of hash slots
+type SlotRange struct {
+	Start int
+	End   int
+}
+
+// ParseClusterNodes will parse the output of CLUSTER NODES of Redis 5, 6 and 7
+func ParseClusterNodes(output string) ([]ClusterNode, error) {
+	var nodes []ClusterNode
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		node, err := parseClusterNode(line)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// parseClusterNode will parse a single CLUSTER NODES line
+func parseClusterNode(line string) (ClusterNode, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 8 {
+		return ClusterNode{}, fmt.Errorf("malformed cluster node line %q", line)
+	}
+	node := ClusterNode{
+		ID:        fields[0],
+		Flags:     strings.Split(fields[2], ","),
+		LinkState: fields[7],
+		Migrating: map[int]string{},
+		Importing: map[int]string{},
+	}
+	if fields[3] != "-" {
+		node.MasterID = fields[3]
+	}
+
+	// ip:port@cport[,hostname[,aux=value...]], the bus port is missing before Redis 4
+	addr := strings.Split(fields[1], ",")
+	if len(addr) > 1 && !strings.Contains(addr[1], "=") {
+		node.Hostname = addr[1]
+	}
+	hostPort := addr[0]
+	if index := strings.Index(hostPort, "@"); index >= 0 {
+		busPort, err := strconv.Atoi(hostPort[index+1:])
+		if err != nil {
+			return node, fmt.Errorf("malformed bus port in %q", fields[1])
+		}
+		node.BusPort = busPort
+		hostPort = hostPort[:index]
+	}
+	if index := strings.LastIndex(hostPort, ":"); index >= 0 {
+		port, err := strconv.Atoi(hostPort[index+1:])
+		if err != nil {
+			return node, fmt.Errorf("malformed port in %q", fields[1])
+		}
+		node.Port = port
+		node.IP = strings.Trim(hostPort[:index], "[]")
+	}
+
+	var err error
+	if node.PingSent, err = strconv.ParseInt(fields[4], 10, 64); err != nil {
+		return node, fmt.Errorf("malformed ping-sent in %q", line)
+	}
+	if node.PongRecv, err = strconv.ParseInt(fields[5], 10, 64); err != nil {
+		return node, fmt.Errorf("malformed pong-recv in %q", line)
+	}
+	if node.ConfigEpoch, err = strconv.ParseInt(fields[6], 10, 64); err != nil {
+		return node, fmt.Errorf("malformed config-epoch in %q", line)
+	}
+
+	for _, field := range fields[8:] {
+		if strings.HasPrefix(field, "[") {
+			// [slot->-target] is migrating, [slot-<-source] is importing
+			marker := strings.Trim(field, "[]")
+			if parts := strings.SplitN(marker, "->-", 2); len(parts) == 2 {
+				if slot, err := strconv.Atoi(parts[0]); err == nil {
+					node.Migrating[slot] = parts[1]
+				}
+			} else if parts := strings.SplitN(marker, "-<-", 2); len(parts) == 2 {
+				if slot, err := strconv.Atoi(parts[0]); err == nil {
+					node.Importing[slot] = parts[1]
+				}
+			}
+			continue
+		}
+		bounds := strings.SplitN(field, "-", 2)
+		start, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return node, fmt.Errorf("malformed slot range %q", field)
+		}
+		end := start
+		if len(bounds) == 2 {
+			if end, err = strconv.Atoi(bounds[1]); err != nil {
+				return node, fmt.Errorf("malformed slot range %q", field)
+			}
+		}
+		node.Slots = append(node.Slots, SlotRange{Start: start, End: end})
+	}
+	return node, nil
+}
+
+// HasFlag checks if the node carries the flag
+func (n ClusterNode) HasFlag(flag string) bool {
+	for _, f := range n.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// IsMaster checks if the node is a master
+func (n ClusterNode) IsMaster() bool {
+	return n.HasFlag("master")
+}
+
+// IsReplica checks if the node is a replica
+func (n ClusterNode) IsReplica() bool {
+	return n.HasFlag("slave")
+}
+
+// IsFailed checks if the node is flagged as failed by the cluster
+func (n ClusterNode) IsFailed() bool {
+	return n.HasFlag("fail") || n.HasFlag("noaddr")
+}
+
+// IsConnected checks if the cluster bus link to the node is up
+func (n ClusterNode) IsConnected() bool {
+	return n.LinkState == "connected"
+}
+
+// SlotCount returns the number of slots the node owns
+func (n ClusterNode) SlotCount() int {
+	count := 0
+	for _, slots := range n.Slots {
+		count += slots.End - slots.Start + 1
+	}
+	return count
+}
+
+// SlotList returns the slots the node owns
+func (n ClusterNode) SlotList() []int {
+	var slots []int
+	for _, slotRange := range n.Slots {
+		for slot := slotRange.Start; slot <= slotRange.End; slot++ {
+			slots = append(slots, slot)
+		}
+	}
+	return slots
+}
+
+// ClusterTopology is the CLUSTER NODES view of the cluster taken once per reconcile and shared by its decisions
+type ClusterTopology struct {
+	// SeedPod is the leader pod the view was read from, empty when no leader answered
+	SeedPod string
+	Nodes   []ClusterNode
+	// PodsByIP indexes the names of the running cluster pods by pod IP
+	PodsByIP map[string]string
+	// Announced maps the external addresses announced by the nodes to their pod IPs, the views hold pod IPs only
+	Announced map[string]string
+}
+
+// GetClusterTopology will read the view of the first leader owning slots or else of the first leader answering
+func GetClusterTopology(cr *redisv1beta1.RedisCluster) *ClusterTopology {
+	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
+	topology := &ClusterTopology{PodsByIP: map[string]string{}}
+	selector := fmt.Sprintf("redis_setup_type=cluster,app in (%s-leader,%s-follower)", cr.ObjectMeta.Name, cr.ObjectMeta.Name)
+	pods, err := generateK8sClient().CoreV1().Pods(cr.Namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		logger.Error(err, "Could not list the cluster pods")
+		return topology
+	}
+	var leaders []string
+	for _, pod := range pods.Items {
+		if pod.Status.PodIP == "" {
+			continue
+		}
+		topology.PodsByIP[pod.Status.PodIP] = pod.Name
+		if strings.HasPrefix(pod.Name, cr.ObjectMeta.Name+"-leader-") && isPodReady(&pod) {
+			leaders = append(leaders, pod.Name)
+		}
+	}
+	sort.Slice(leaders, func(i, j int) bool {
+		return podOrdinal(leaders[i]) < podOrdinal(leaders[j])
+	})
+	if isExternalAccessEnabled(cr) {
+		if topology.Announced, err = getAnnouncedPodIPs(cr, topology.PodsByIP); err != nil {
+			logger.Error(err, "Could not read the external addresses of the cluster pods")
+		}
+	}
+
+	for _, podName := range leaders {
+		client := configureRedisClient(cr, podName)
+		nodes, err := getClusterNodes(client)
+		client.Close()
+		if err != nil {
+			logger.Error(err, "Could not read the cluster nodes", "Pod", podName)
+			continue
+		}
+		nodes = translateAnnouncedAddresses(nodes, topology.Announced)
+		if topology.SeedPod == "" {
+			topology.SeedPod, topology.Nodes = podName, nodes
+		}
+		for _, node := range nodes {
+			if len(node.Slots) > 0 {
+				topology.SeedPod, topology.Nodes = podName, nodes
+				return topology
+			}
+		}
+	}
+	return topology
+}
+
+// Formed checks if the nodes of the view already own slots
+func (t *ClusterTopology) Formed() bool {
+	for _, node := range t.Nodes {
+		if len(node.Slots) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// NodeByID returns the node with the ID or nil
+func (t *ClusterTopology) NodeByID(nodeID string) *ClusterNode {
+	return findClusterNodeByID(t.Nodes, nodeID)
+}
+
+// PodName returns the pod serving the node or an empty string
+func (t *ClusterTopology) PodName(node ClusterNode) string {
+	return t.PodsByIP[node.IP]
+}
+
+// PodNameByID returns the pod serving the node with the ID or an empty string
+func (t *ClusterTopology) PodNameByID(nodeID string) string {
+	node := t.NodeByID(nodeID)
+	if node == nil {
+		return ""
+	}
+	return t.PodsByIP[node.IP]
+}
+
+// PodIP returns the IP of the pod or an empty string
+func (t *ClusterTopology) PodIP(podName string) string {
+	return getPodIP(t.PodsByIP, podName)
+}
+
+// getClusterNodes will return the parsed CLUSTER NODES output of a node
+func getClusterNodes(client *redis.Client) ([]ClusterNode, error) {
+	output, err := client.ClusterNodes().Result()
+	if err != nil {
+		return nil, err
+	}
+	return ParseClusterNodes(output)
+}
+
+// findClusterNode returns the first node carrying the flag or nil
+func findClusterNode(nodes []ClusterNode, flag string) *ClusterNode {
+	for i := range nodes {
+		if nodes[i].HasFlag(flag) {
+			return &nodes[i]
+		}
+	}
+	return nil
+}
+
+// findClusterNodeByID returns the node with the ID or nil
+func findClusterNodeByID(nodes []ClusterNode, nodeID string) *ClusterNode {
+	for i := range nodes {
+		if nodes[i].ID == nodeID {
+			return &nodes[i]
+		}
+	}
+	return nil
+}
+
+// findClusterNodeByIP returns the node at the IP or nil
+func findClusterNodeByIP(nodes []ClusterNode, ip string) *ClusterNode {
+	for i := range nodes {
+		if ip != "" && nodes[i].IP == ip {
+			return &nodes[i]
+		}
+	}
+	return nil
+}
+
+// podOrdinal returns the statefulset ordinal of a pod name
+func podOrdinal(podName string) int {
+	ordinal, err := strconv.Atoi(podName[strings.LastIndex(podName, "-")+1:])
+	if err != nil {
+		return -1
+	}
+	return ordinal
+}
@@ -0,0 +1,163 @@
+package k8sutils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func clusterNodesFromOutput(output string) []ClusterNode {
+	nodes, err := ParseClusterNodes(output)
+	if err != nil {
+		panic(err)
+	}
+	return nodes
+}
+
+func TestParseClusterNode(t *testing.T) {
+	var tests = []struct {
+		name string
+		line string
+		want ClusterNode
+	}{
+		{
+			"redis 5 master",
+			"e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca 127.0.0.1:30001@31001 myself,master - 0 0 1 connected 0-5460",
+			ClusterNode{ID: "e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca", IP: "127.0.0.1", Port: 30001, BusPort: 31001, Flags: []string{"myself", "master"}, ConfigEpoch: 1, LinkState: "connected",
+				Slots: []SlotRange{{0, 5460}}, Migrating: map[int]string{}, Importing: map[int]string{}},
+		},
+		{
+			"redis 5 replica",
+			"07c37dfeb235213a872192d90877d0cd55635b91 127.0.0.1:30004@31004 slave e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca 0 1426238317239 4 connected",
+			ClusterNode{ID: "07c37dfeb235213a872192d90877d0cd55635b91", IP: "127.0.0.1", Port: 30004, BusPort: 31004, Flags: []string{"slave"}, MasterID: "e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca", PongRecv: 1426238317239, ConfigEpoch: 4, LinkState: "connected",
+				Migrating: map[int]string{}, Importing: map[int]string{}},
+		},
+		{
+			"redis 6 failed node without address",
+			"faa21623054227826e93dd71314cce3706491dac :0@0 slave,fail,noaddr d54557b21bc5a5aa947ce58b7dbadc5d39bdd551 1654197340000 1654197339000 2 disconnected",
+			ClusterNode{ID: "faa21623054227826e93dd71314cce3706491dac", Flags: []string{"slave", "fail", "noaddr"}, MasterID: "d54557b21bc5a5aa947ce58b7dbadc5d39bdd551", PingSent: 1654197340000, PongRecv: 1654197339000, ConfigEpoch: 2, LinkState: "disconnected",
+				Migrating: map[int]string{}, Importing: map[int]string{}},
+		},
+		{
+			"redis 6 open slots",
+			"d54557b21bc5a5aa947ce58b7dbadc5d39bdd551 172.17.0.29:6379@16379 myself,master - 0 1654197347000 2 connected 0-2 7 [93->-c9fa05269c4e662295bf34eb93f1315f962493ba] [77-<-b65312dcf5537b8826c344783f078096fdb7f27c]",
+			ClusterNode{ID: "d54557b21bc5a5aa947ce58b7dbadc5d39bdd551", IP: "172.17.0.29", Port: 6379, BusPort: 16379, Flags: []string{"myself", "master"}, PongRecv: 1654197347000, ConfigEpoch: 2, LinkState: "connected",
+				Slots:     []SlotRange{{0, 2}, {7, 7}},
+				Migrating: map[int]string{93: "c9fa05269c4e662295bf34eb93f1315f962493ba"},
+				Importing: map[int]string{77: "b65312dcf5537b8826c344783f078096fdb7f27c"}},
+		},
+		{
+			"redis 7.0 without hostname",
+			"b65312dcf5537b8826c344783f078096fdb7f27c 10.244.0.6:6379@16379, master - 0 1680000000000 3 connected 10923-16383",
+			ClusterNode{ID: "b65312dcf5537b8826c344783f078096fdb7f27c", IP: "10.244.0.6", Port: 6379, BusPort: 16379, Flags: []string{"master"}, PongRecv: 1680000000000, ConfigEpoch: 3, LinkState: "connected",
+				Slots: []SlotRange{{10923, 16383}}, Migrating: map[int]string{}, Importing: map[int]string{}},
+		},
+		{
+			"redis 7.0 hostname",
+			"c9fa05269c4e662295bf34eb93f1315f962493ba 10.244.0.5:6379@16379,redis-leader-0.redis-leader-headless.default.svc myself,master - 0 1680000000000 1 connected 0-5460",
+			ClusterNode{ID: "c9fa05269c4e662295bf34eb93f1315f962493ba", IP: "10.244.0.5", Port: 6379, BusPort: 16379, Hostname: "redis-leader-0.redis-leader-headless.default.svc", Flags: []string{"myself", "master"}, PongRecv: 1680000000000, ConfigEpoch: 1, LinkState: "connected",
+				Slots: []SlotRange{{0, 5460}}, Migrating: map[int]string{}, Importing: map[int]string{}},
+		},
+		{
+			"redis 7.2 hostname and aux fields",
+			"205dd1780dda981f9320c9d47d069b3c0ceaa358 10.244.0.7:6379@16379,redis-follower-0.redis-follower-headless.default.svc,tls-port=0,shard-id=69bc080733d1355567173199cff4a6a039a2f024 slave c9fa05269c4e662295bf34eb93f1315f962493ba 0 1700000000000 1 connected",
+			ClusterNode{ID: "205dd1780dda981f9320c9d47d069b3c0ceaa358", IP: "10.244.0.7", Port: 6379, BusPort: 16379, Hostname: "redis-follower-0.redis-follower-headless.default.svc", Flags: []string{"slave"}, MasterID: "c9fa05269c4e662295bf34eb93f1315f962493ba", PongRecv: 1700000000000, ConfigEpoch: 1, LinkState: "connected",
+				Migrating: map[int]string{}, Importing: map[int]string{}},
+		},
+		{
+			"redis 7.2 aux fields without hostname",
+			"205dd1780dda981f9320c9d47d069b3c0ceaa358 fd00::7:6379@16379,,tls-port=0,shard-id=69bc080733d1355567173199cff4a6a039a2f024 master,fail? - 1700000000000 1699999990000 4 connected",
+			ClusterNode{ID: "205dd1780dda981f9320c9d47d069b3c0ceaa358", IP: "fd00::7", Port: 6379, BusPort: 16379, Flags: []string{"master", "fail?"}, PingSent: 1700000000000, PongRecv: 1699999990000, ConfigEpoch: 4, LinkState: "connected",
+				Migrating: map[int]string{}, Importing: map[int]string{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := parseClusterNode(tt.line)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(node, tt.want) {
+				t.Errorf("got %+v, want %+v", node, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseClusterNodes(t *testing.T) {
+	nodes, err := ParseClusterNodes(`
+07c37dfeb235213a872192d90877d0cd55635b91 127.0.0.1:30004@31004 slave e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca 0 1426238317239 4 connected
+67ed2db8d677e59ec4a4cefb06858cf2a1a89fa1 127.0.0.1:30002@31002 master - 0 1426238316232 2 connected 5461-10922
+292f8b365bb7edb5e285caf0b7e6ddc7265d2f4f 127.0.0.1:30003@31003 master - 0 1426238318243 3 connected 10923-16383
+6ec23923021cf3ffec47632106199cb7f496ce01 127.0.0.1:30005@31005 slave 67ed2db8d677e59ec4a4cefb06858cf2a1a89fa1 0 1426238316232 5 connected
+824fe116063bc5fcf9f4ffd895bc17aee7731ac3 127.0.0.1:30006@31006 slave 292f8b365bb7edb5e285caf0b7e6ddc7265d2f4f 0 1426238317741 6 connected
+e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca 127.0.0.1:30001@31001 myself,master - 0 0 1 connected 0-5460
+`)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	masters, replicas, slots := 0, 0, 0
+	for _, node := range nodes {
+		if node.IsMaster() {
+			masters++
+		}
+		if node.IsReplica() {
+			replicas++
+		}
+		slots += node.SlotCount()
+	}
+	if len(nodes) != 6 || masters != 3 || replicas != 3 || slots != totalHashSlots {
+		t.Errorf("got %d nodes, %d masters, %d replicas, %d slots", len(nodes), masters, replicas, slots)
+	}
+	if myself := findClusterNode(nodes, "myself"); myself == nil || myself.ID != "e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca" {
+		t.Errorf("myself not found")
+	}
+
+	for _, output := range []string{"a 10.0.0.1:6379@16379 master", "a 10.0.0.1:6379@x master - 0 0 1 connected", "a 10.0.0.1:6379@16379 master - 0 0 1 connected 5-x"} {
+		if _, err := ParseClusterNodes(output); err == nil {
+			t.Errorf("no error for malformed output %q", output)
+		}
+	}
+}
+
+func TestClusterTopology(t *testing.T) {
+	topology := &ClusterTopology{
+		SeedPod: "redis-leader-0",
+		Nodes: clusterNodesFromOutput(`
+a 10.0.0.1:6379@16379 myself,master - 0 0 1 connected 0-16383
+b 10.0.0.2:6379@16379 slave a 0 0 1 connected`),
+		PodsByIP: map[string]string{"10.0.0.1": "redis-leader-0", "10.0.0.2": "redis-follower-0"},
+	}
+	if !topology.Formed() {
+		t.Errorf("cluster owning slots is not formed")
+	}
+	if name := topology.PodNameByID("b"); name != "redis-follower-0" {
+		t.Errorf("got pod %s for node b", name)
+	}
+	if name := topology.PodNameByID("x"); name != "" {
+		t.Errorf("got pod %s for unknown node", name)
+	}
+	if ip := topology.PodIP("redis-leader-0"); ip != "10.0.0.1" {
+		t.Errorf("got ip %s for redis-leader-0", ip)
+	}
+	if (&ClusterTopology{}).Formed() {
+		t.Errorf("empty view is formed")
+	}
+}
+
+func TestPodOrdinal(t *testing.T) {
+	var tests = []struct {
+		podName string
+		want    int
+	}{
+		{"redis-leader-0", 0},
+		{"redis-leader-12", 12},
+		{"redis", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.podName, func(t *testing.T) {
+			if ans := podOrdinal(tt.podName); ans != tt.want {
+				t.Errorf("got %d, want %d", ans, tt.want)
+			}
+		})
+	}
+}
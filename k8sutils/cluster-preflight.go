@@ -0,0 +1,132 @@
+package k8sutils
+
+import (
+	"fmt"
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ClusterCreationCondition reports whether the leaders may form a new cluster
+	ClusterCreationCondition = "ClusterCreation"
+
+	existingDataAdoptExisting = "AdoptExisting"
+	existingDataAllowDataLoss = "AllowDataLoss"
+)
+
+// CheckRedisClusterPreflight will inspect the leaders before the cluster is created, leaders holding keys or the identity
+// of a previous cluster block the creation unless their data is adopted or may be lost
+func CheckRedisClusterPreflight(cr *redisv1beta1.RedisCluster, topology *ClusterTopology) (metav1.Condition, error) {
+	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
+	condition := metav1.Condition{Type: ClusterCreationCondition, Status: metav1.ConditionTrue, Reason: "LeadersEmpty", Message: "The leaders hold no data and no previous cluster identity"}
+	if isClusterFormedByPods(topology) {
+		// New leaders of a formed cluster join through the rebalance, nothing is created
+		condition.Reason = "ClusterFormed"
+		condition.Message = "The leaders already form a cluster"
+		return condition, nil
+	}
+	replicas := int(cr.Spec.GetReplicaCounts("leader"))
+	ranges := splitSlotRanges(replicas)
+	var findings, dirty []string
+	replicaPods := map[string]bool{}
+	for podCount := 0; podCount < replicas; podCount++ {
+		podName := cr.ObjectMeta.Name + "-leader-" + strconv.Itoa(podCount)
+		client := configureRedisClient(cr, podName)
+		keys, err := client.DBSize().Result()
+		var nodes []ClusterNode
+		if err == nil {
+			nodes, err = getClusterNodes(client)
+		}
+		client.Close()
+		if err != nil {
+			return condition, err
+		}
+		planned := SlotRange{Start: ranges[podCount][0], End: ranges[podCount][1]}
+		nodes = translateAnnouncedAddresses(nodes, topology.Announced)
+		if found := getLeaderFindings(podName, keys, nodes, topology.PodsByIP, planned); len(found) > 0 {
+			findings = append(findings, found...)
+			dirty = append(dirty, podName)
+			if myself := findClusterNode(nodes, "myself"); myself != nil && myself.IsReplica() {
+				replicaPods[podName] = true
+			}
+		}
+	}
+	if len(findings) == 0 {
+		return condition, nil
+	}
+
+	switch cr.Spec.ExistingData {
+	case existingDataAdoptExisting:
+		condition.Reason = "ExistingDataAdopted"
+		condition.Message = fmt.Sprintf("Creating the cluster on top of the existing data: %s", strings.Join(findings, "; "))
+	case existingDataAllowDataLoss:
+		// Replicas refuse FLUSHALL, the reset turns them into empty masters first and flushes them, masters refuse
+		// the reset while they hold keys
+		for _, replica := range []bool{true, false} {
+			for _, podName := range dirty {
+				if replicaPods[podName] != replica {
+					continue
+				}
+				client := configureRedisClient(cr, podName)
+				var err error
+				if !replica {
+					err = client.FlushAll().Err()
+				}
+				if err == nil {
+					err = client.ClusterResetHard().Err()
+				}
+				client.Close()
+				if err != nil {
+					return condition, err
+				}
+				logger.Info("Leader flushed and reset before the cluster creation", "Pod", podName)
+			}
+		}
+		condition.Reason = "ExistingDataDiscarded"
+		condition.Message = fmt.Sprintf("Flushed and reset %s before the cluster creation", strings.Join(dirty, ","))
+	default:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "ExistingDataFound"
+		condition.Message = fmt.Sprintf("%s, set existingData to AdoptExisting or AllowDataLoss to create the cluster", strings.Join(findings, "; "))
+	}
+	return condition, nil
+}
+
+// isClusterFormedByPods checks that the view owns slots and that the slot owners and known nodes are current pods, a
+// leader carrying the identity of a previous cluster knows nodes no pod serves, nodes the cluster flagged as failed
+// are left to the node recovery and the ghost cleanup
+func isClusterFormedByPods(topology *ClusterTopology) bool {
+	if !topology.Formed() {
+		return false
+	}
+	for _, node := range topology.Nodes {
+		if !node.IsFailed() && topology.PodName(node) == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// getLeaderFindings returns the keys and the previous cluster identity found on a leader, the slots planned for the leader
+// are no finding as an interrupted creation leaves them behind
+func getLeaderFindings(podName string, keys int64, nodes []ClusterNode, podsByIP map[string]string, planned SlotRange) []string {
+	var findings []string
+	if keys > 0 {
+		findings = append(findings, fmt.Sprintf("%s holds %d keys", podName, keys))
+	}
+	for _, node := range nodes {
+		if !node.HasFlag("myself") && podsByIP[node.IP] == "" {
+			findings = append(findings, fmt.Sprintf("%s knows nodes of a previous cluster", podName))
+			break
+		}
+	}
+	if myself := findClusterNode(nodes, "myself"); myself != nil && len(myself.Slots) > 0 {
+		if len(myself.Slots) != 1 || myself.Slots[0] != planned {
+			findings = append(findings, fmt.Sprintf("%s owns slots %s of a previous cluster", podName, formatSlotRanges(myself.SlotList())))
+		}
+	}
+	return findings
+}
@@ -0,0 +1,98 @@
+package k8sutils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetLeaderFindings(t *testing.T) {
+	podsByIP := map[string]string{"10.0.0.1": "redis-leader-0", "10.0.0.2": "redis-leader-1"}
+	planned := SlotRange{Start: 0, End: 5460}
+	tests := []struct {
+		name   string
+		keys   int64
+		output string
+		want   []string
+	}{
+		{
+			name:   "fresh leader",
+			output: "a 10.0.0.1:6379@16379 myself,master - 0 0 0 connected",
+		},
+		{
+			name:   "interrupted creation keeps the planned slots",
+			output: "a 10.0.0.1:6379@16379 myself,master - 0 0 1 connected 0-5460",
+		},
+		{
+			name:   "keys on the leader",
+			keys:   42,
+			output: "a 10.0.0.1:6379@16379 myself,master - 0 0 0 connected",
+			want:   []string{"redis-leader-0 holds 42 keys"},
+		},
+		{
+			name: "identity of a previous cluster",
+			keys: 7,
+			output: `
+a 10.0.0.1:6379@16379 myself,master - 0 0 3 connected 10923-16383
+b 10.0.0.2:6379@16379 master - 0 0 1 connected 0-5460
+c 10.0.9.9:6379@16379 master,fail - 0 0 2 disconnected 5461-10922`,
+			want: []string{
+				"redis-leader-0 holds 7 keys",
+				"redis-leader-0 knows nodes of a previous cluster",
+				"redis-leader-0 owns slots 10923-16383 of a previous cluster",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := getLeaderFindings("redis-leader-0", tt.keys, clusterNodesFromOutput(tt.output), podsByIP, planned)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsClusterFormedByPods(t *testing.T) {
+	podsByIP := map[string]string{"10.0.0.1": "redis-leader-0", "10.0.0.2": "redis-leader-1", "10.0.0.3": "redis-leader-2"}
+	tests := []struct {
+		name   string
+		output string
+		want   bool
+	}{
+		{
+			name:   "fresh leaders",
+			output: "a 10.0.0.1:6379@16379 myself,master - 0 0 0 connected",
+		},
+		{
+			name: "cluster of the current pods",
+			output: `
+a 10.0.0.1:6379@16379 myself,master - 0 0 1 connected 0-5460
+b 10.0.0.2:6379@16379 master - 0 0 2 connected 5461-10922
+c 10.0.0.3:6379@16379 master - 0 0 3 connected 10923-16383`,
+			want: true,
+		},
+		{
+			name: "ghost of a lost pod",
+			output: `
+a 10.0.0.1:6379@16379 myself,master - 0 0 1 connected 0-8191
+b 10.0.0.2:6379@16379 master - 0 0 2 connected 8192-16383
+d :0@0 slave,fail,noaddr a 0 0 1 disconnected`,
+			want: true,
+		},
+		{
+			name: "seed leader carrying a foreign identity",
+			output: `
+a 10.0.0.1:6379@16379 myself,master - 0 0 3 connected 10923-16383
+x 10.0.9.1:6379@16379 master,fail? - 0 0 1 disconnected 0-5460
+y 10.0.9.2:6379@16379 master,fail? - 0 0 2 disconnected 5461-10922`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topology := &ClusterTopology{SeedPod: "redis-leader-0", Nodes: clusterNodesFromOutput(tt.output), PodsByIP: podsByIP}
+			if got := isClusterFormedByPods(topology); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package k8sutils
+
+import (
+	"context"
+	"fmt"
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"strconv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RebuildAnnotation set to "true" resets and flushes every node to recreate the cluster, all data is lost
+const RebuildAnnotation = "redis.opstreelabs.in/rebuild"
+
+// clusterPod is a ready pod of the cluster with the node ID it serves
+type clusterPod struct {
+	PodName string
+	IP      string
+	NodeID  string
+}
+
+// RepairRedisCluster will diagnose failed cluster nodes and fix them with targeted commands, it returns the actions taken
+func RepairRedisCluster(cr *redisv1beta1.RedisCluster, topology *ClusterTopology) ([]string, error) {
+	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
+	if !topology.Formed() {
+		return nil, nil
+	}
+	pods := getReadyClusterPods(cr)
+	nodes := topology.Nodes
+	seedClient := configureRedisClient(cr, topology.SeedPod)
+	defer seedClient.Close()
+
+	// Pods which changed their IP or were never gossiped are introduced again
+	var actions []string
+	for _, pod := range pods {
+		node := topology.NodeByID(pod.NodeID)
+		if node != nil && node.IP == pod.IP && !node.IsFailed() {
+			continue
+		}
+		if err := seedClient.ClusterMeet(pod.IP, "6379").Err(); err != nil {
+			return actions, err
+		}
+		actions = append(actions, fmt.Sprintf("Introduced %s at %s to the cluster", pod.PodName, pod.IP))
+	}
+	if len(actions) > 0 {
+		logger.Info("Waiting for the cluster to gossip the new addresses", "Actions", actions)
+		return actions, nil
+	}
+
+	podsByIP := map[string]string{}
+	for _, pod := range pods {
+		podsByIP[pod.IP] = pod.PodName
+	}
+	// Failed masters which still own slots are replaced by one of their healthy replicas
+	for _, node := range nodes {
+		if !node.IsMaster() || !node.IsFailed() || len(node.Slots) == 0 {
+			continue
+		}
+		replica := getHealthyReplicaPod(nodes, node.ID, podsByIP)
+		if replica == "" {
+			logger.Info("Failed master has no healthy replica", "Node", node.ID)
+			continue
+		}
+		client := configureRedisClient(cr, replica)
+		err := client.Do("cluster", "failover", "force").Err()
+		client.Close()
+		if err != nil {
+			return actions, err
+		}
+		actions = append(actions, fmt.Sprintf("Promoted %s in place of failed master %s", replica, node.ID))
+	}
+	// Failed nodes no pod answers for anymore are forgotten by the ghost node cleanup
+
+	// Slots no healthy master serves are assigned again by redis-cli unless the slot healing handles them
+	healing := cr.Spec.SlotHealing
+	if len(actions) == 0 && (healing == nil || !healing.Enabled) {
+		if covered := countCoveredSlots(nodes); covered < totalHashSlots {
+			cmd := []string{"redis-cli", "--cluster", "fix", topology.PodIP(topology.SeedPod) + ":6379", "--cluster-yes"}
+			if password := getRedisClusterPassword(cr); password != "" {
+				cmd = append(cmd, "-a", password)
+			}
+			cmd = append(cmd, getRedisTLSArgs(cr.Spec.TLS, topology.SeedPod)...)
+			executeCommand(cr, cmd, cr.ObjectMeta.Name+"-leader-0")
+			actions = append(actions, fmt.Sprintf("Fixed %d uncovered slots", totalHashSlots-covered))
+		}
+	}
+	return actions, nil
+}
+
+// countCoveredSlots returns the number of slots served by healthy masters
+func countCoveredSlots(nodes []ClusterNode) int {
+	covered := 0
+	for _, node := range nodes {
+		if node.IsMaster() && !node.IsFailed() {
+			covered += len(node.SlotList())
+		}
+	}
+	return covered
+}
+
+// getReadyClusterPods returns the ready pods of the cluster with their node ID
+func getReadyClusterPods(cr *redisv1beta1.RedisCluster) []clusterPod {
+	var pods []clusterPod
+	for _, role := range []string{"leader", "follower"} {
+		for podCount := 0; podCount < int(cr.Spec.GetReplicaCounts(role)); podCount++ {
+			podName := cr.ObjectMeta.Name + "-" + role + "-" + strconv.Itoa(podCount)
+			pod, err := generateK8sClient().CoreV1().Pods(cr.Namespace).Get(context.TODO(), podName, metav1.GetOptions{})
+			if err != nil || !isPodReady(pod) {
+				continue
+			}
+			client := configureRedisClient(cr, podName)
+			nodeID, err := client.Do("cluster", "myid").String()
+			client.Close()
+			if err != nil {
+				continue
+			}
+			pods = append(pods, clusterPod{PodName: podName, IP: pod.Status.PodIP, NodeID: nodeID})
+		}
+	}
+	return pods
+}
+
+// getHealthyReplicaPod returns the pod of a connected replica of the master
+func getHealthyReplicaPod(nodes []ClusterNode, masterID string, podsByIP map[string]string) string {
+	for _, node := range nodes {
+		if node.MasterID != masterID || !node.IsReplica() || node.IsFailed() || !node.IsConnected() {
+			continue
+		}
+		if podName := podsByIP[node.IP]; podName != "" {
+			return podName
+		}
+	}
+	return ""
+}
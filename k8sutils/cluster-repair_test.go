@@ -0,0 +1,41 @@
+package k8sutils
+
+import (
+	"testing"
+)
+
+func TestGetHealthyReplicaPod(t *testing.T) {
+	nodes := clusterNodesFromOutput(`
+a 10.0.0.1:6379@16379 master,fail - 0 0 1 disconnected 0-8191
+b 10.0.0.2:6379@16379 myself,master - 0 0 2 connected 8192-16383
+c 10.0.0.3:6379@16379 slave,fail a 0 0 1 disconnected
+d 10.0.0.4:6379@16379 slave a 0 0 1 connected
+e 10.0.0.5:6379@16379 slave b 0 0 2 connected`)
+	podsByIP := map[string]string{"10.0.0.2": "redis-leader-1", "10.0.0.3": "redis-follower-0", "10.0.0.4": "redis-follower-1", "10.0.0.5": "redis-follower-2"}
+
+	var tests = []struct {
+		master string
+		want   string
+	}{
+		{"a", "redis-follower-1"},
+		{"b", "redis-follower-2"},
+		{"x", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.master, func(t *testing.T) {
+			if ans := getHealthyReplicaPod(nodes, tt.master, podsByIP); ans != tt.want {
+				t.Errorf("got %s, want %s", ans, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountCoveredSlots(t *testing.T) {
+	nodes := clusterNodesFromOutput(`
+a 10.0.0.1:6379@16379 master,fail - 0 0 1 disconnected 0-8191
+b 10.0.0.2:6379@16379 myself,master - 0 0 2 connected 8192-16383
+c 10.0.0.3:6379@16379 slave b 0 0 2 connected`)
+	if covered := countCoveredSlots(nodes); covered != 8192 {
+		t.Errorf("got %d covered slots, want the 8192 slots of the healthy master", covered)
+	}
+}
@@ -17,7 +17,7 @@ func dashboardLogger(namespace, name string) logr.Logger {
 	return reqLogger
 }
 
-func CreateGrafanaDashBoard(namespace, userName, redisName string, isCluster bool) error {
+func CreateGrafanaDashBoard(namespace, userName, redisName string, isCluster bool, ownerDef metav1.OwnerReference) error {
 	logger := dashboardLogger(namespace, redisName)
 
 	var dsb grafanav1alpha1.GrafanaDashboard
@@ -25,9 +25,9 @@ func CreateGrafanaDashBoard(namespace, userName, redisName string, isCluster boo
 	var err error
 
 	if isCluster {
-		dsb = generateGrafanaDashboard(namespace, userName, redisName, true)
+		dsb = generateGrafanaDashboard(namespace, userName, redisName, true, ownerDef)
 	} else {
-		dsb = generateGrafanaDashboard(namespace, userName, redisName, false)
+		dsb = generateGrafanaDashboard(namespace, userName, redisName, false, ownerDef)
 	}
 
 	body, err = json.Marshal(dsb)
@@ -63,7 +63,7 @@ func CreateGrafanaDashBoard(namespace, userName, redisName string, isCluster boo
 	return nil
 }
 
-func CreateServiceMonitor(namespace, userName, redisName string, isCluster bool) error {
+func CreateServiceMonitor(namespace, userName, redisName string, isCluster bool, ownerDef metav1.OwnerReference) error {
 	logger := dashboardLogger(namespace, redisName)
 	var body []byte
 	var err error
@@ -73,7 +73,7 @@ func CreateServiceMonitor(namespace, userName, redisName string, isCluster bool)
 		if err != nil && !errors.IsNotFound(err) {
 			return err
 		} else if errors.IsNotFound(err) {
-			sm_leader := generateServiceMontiorObject(namespace, userName, redisName, true, "leader")
+			sm_leader := generateServiceMontiorObject(namespace, userName, redisName, true, "leader", ownerDef)
 			body, _ = json.Marshal(sm_leader)
 			if _, err := generateK8sClient().RESTClient().Post().AbsPath("/apis/monitoring.coreos.com/v1/namespaces/" + namespace + "/servicemonitors").Body(body).DoRaw(context.TODO()); err != nil {
 				logger.Error(err, "Failed to create ServiceMonitor")
@@ -86,7 +86,7 @@ func CreateServiceMonitor(namespace, userName, redisName string, isCluster bool)
 		if err != nil && !errors.IsNotFound(err) {
 			return err
 		} else if errors.IsNotFound(err) {
-			sm_follower := generateServiceMontiorObject(namespace, userName, redisName, true, "follower")
+			sm_follower := generateServiceMontiorObject(namespace, userName, redisName, true, "follower", ownerDef)
 			body, _ = json.Marshal(sm_follower)
 			if _, err := generateK8sClient().RESTClient().Post().AbsPath("/apis/monitoring.coreos.com/v1/namespaces/" + namespace + "/servicemonitors").Body(body).DoRaw(context.TODO()); err != nil {
 				logger.Error(err, "Failed to create ServiceMonitor")
@@ -99,7 +99,7 @@ func CreateServiceMonitor(namespace, userName, redisName string, isCluster bool)
 		if err != nil && !errors.IsNotFound(err) {
 			return err
 		} else if errors.IsNotFound(err) {
-			sm := generateServiceMontiorObject(namespace, userName, redisName, false, "")
+			sm := generateServiceMontiorObject(namespace, userName, redisName, false, "", ownerDef)
 			body, _ = json.Marshal(sm)
 			if _, err = generateK8sClient().RESTClient().Post().AbsPath("/apis/monitoring.coreos.com/v1/namespaces/" + namespace + "/servicemonitors").Body(body).DoRaw(context.TODO()); err != nil {
 				logger.Error(err, "Failed to create ServiceMonitor")
@@ -113,7 +113,7 @@ func CreateServiceMonitor(namespace, userName, redisName string, isCluster bool)
 	return nil
 }
 
-func generateGrafanaDashboard(namespace, userName, redisName string, isCluster bool) grafanav1alpha1.GrafanaDashboard {
+func generateGrafanaDashboard(namespace, userName, redisName string, isCluster bool, ownerDef metav1.OwnerReference) grafanav1alpha1.GrafanaDashboard {
 	name := redisName
 	if isCluster {
 		name += "-cluster"
@@ -145,11 +145,12 @@ func generateGrafanaDashboard(namespace, userName, redisName string, isCluster b
 			Json: generateGrafanaDashboardJson(redisName, isCluster, namespace),
 		},
 	}
+	AddOwnerRefToObject(&dsb, ownerDef)
 
 	return dsb
 }
 
-func generateServiceMontiorObject(namespace, userName, redisName string, isCluster bool, role string) prometheusv1.ServiceMonitor {
+func generateServiceMontiorObject(namespace, userName, redisName string, isCluster bool, role string, ownerDef metav1.OwnerReference) prometheusv1.ServiceMonitor {
 
 	var matchlabel, setupType string
 	if isCluster { // leader or follower
@@ -199,6 +200,7 @@ func generateServiceMontiorObject(namespace, userName, redisName string, isClust
 			},
 		},
 	}
+	AddOwnerRefToObject(&sm, ownerDef)
 
 	return sm
 }
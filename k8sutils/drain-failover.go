@@ -0,0 +1,223 @@
+package k8sutils
+
+import (
+	"context"
+	"fmt"
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// disruptionTargetCondition is set on pods about to be evicted or preempted
+	disruptionTargetCondition = "DisruptionTarget"
+	// RedisRoleLabel follows the redis role of the cluster pods, the disruption budget of the masters selects it
+	RedisRoleLabel = "redis-role"
+)
+
+// drainFailover is the failover of a master served by a draining pod to a replica
+type drainFailover struct {
+	Master  string
+	Replica string
+}
+
+// ReconcileRedisClusterDrain will fail the masters served by pods about to be evicted over to a caught up replica on another
+// node, so evictions only remove replicas, it returns the failovers and the masters left without such a replica
+func ReconcileRedisClusterDrain(cr *redisv1beta1.RedisCluster, topology *ClusterTopology) ([]string, []string, error) {
+	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
+	if !topology.Formed() {
+		return nil, nil, nil
+	}
+	draining, nodeOf, err := getClusterPodDrainStates(cr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(draining) == 0 {
+		return nil, nil, nil
+	}
+	failovers, blocked := planDrainFailovers(cr, topology, draining, nodeOf)
+	var actions []string
+	for _, failover := range failovers {
+		client := configureRedisClient(cr, failover.Replica)
+		err := client.Do("cluster", "failover").Err()
+		client.Close()
+		if err != nil {
+			return actions, blocked, err
+		}
+		logger.Info("Failing the master of a draining pod over to its replica", "Pod", failover.Master, "Replica", failover.Replica)
+		actions = append(actions, fmt.Sprintf("Failed %s over to %s before its eviction", failover.Master, failover.Replica))
+	}
+	return actions, blocked, nil
+}
+
+// ReconcileRedisClusterRoleLabels will label the cluster pods with their redis role, masters owning slots are kept
+// from eviction by their disruption budget until they failed over to a replica
+func ReconcileRedisClusterRoleLabels(cr *redisv1beta1.RedisCluster, topology *ClusterTopology) error {
+	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
+	if !topology.Formed() {
+		return nil
+	}
+	roles := getPodRoleLabels(topology)
+	client := generateK8sClient()
+	selector := fmt.Sprintf("redis_setup_type=cluster,app in (%s-leader,%s-follower)", cr.ObjectMeta.Name, cr.ObjectMeta.Name)
+	pods, err := client.CoreV1().Pods(cr.Namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return err
+	}
+	for _, pod := range pods.Items {
+		role, ok := roles[pod.Name]
+		if !ok || pod.Labels[RedisRoleLabel] == role {
+			continue
+		}
+		patch := fmt.Sprintf(`{"metadata":{"labels":{%q:%q}}}`, RedisRoleLabel, role)
+		_, err := client.CoreV1().Pods(cr.Namespace).Patch(context.TODO(), pod.Name, types.MergePatchType, []byte(patch), metav1.PatchOptions{})
+		if err != nil {
+			return err
+		}
+		logger.Info("Redis role label of the pod is updated", "Pod", pod.Name, "Role", role)
+	}
+	return nil
+}
+
+// getPodRoleLabels returns the role label of the pods serving a node, the pods of failed nodes keep their label
+func getPodRoleLabels(topology *ClusterTopology) map[string]string {
+	roles := map[string]string{}
+	for _, node := range topology.Nodes {
+		podName := topology.PodName(node)
+		if podName == "" || node.IsFailed() {
+			continue
+		}
+		if node.IsMaster() && len(node.Slots) > 0 {
+			roles[podName] = "master"
+		} else {
+			roles[podName] = "slave"
+		}
+	}
+	return roles
+}
+
+// planDrainFailovers returns a failover for every master served by a draining pod to a caught up replica on a node
+// which is not drained, leader pods first, and the masters without such a replica
+func planDrainFailovers(cr *redisv1beta1.RedisCluster, topology *ClusterTopology, draining map[string]bool, nodeOf map[string]string) ([]drainFailover, []string) {
+	leaderPrefix := cr.ObjectMeta.Name + "-leader-"
+	var failovers []drainFailover
+	var blocked []string
+	for _, node := range topology.Nodes {
+		masterPod := topology.PodName(node)
+		if !node.IsMaster() || node.IsFailed() || len(node.Slots) == 0 || !draining[masterPod] {
+			continue
+		}
+		var candidates []string
+		for _, replica := range topology.Nodes {
+			replicaPod := topology.PodName(replica)
+			if !replica.IsReplica() || replica.MasterID != node.ID || replica.IsFailed() || !replica.IsConnected() || replicaPod == "" {
+				continue
+			}
+			if draining[replicaPod] || nodeOf[replicaPod] == nodeOf[masterPod] || !isReplicaCaughtUp(cr, replicaPod) {
+				continue
+			}
+			candidates = append(candidates, replicaPod)
+		}
+		if len(candidates) == 0 {
+			blocked = append(blocked, masterPod)
+			continue
+		}
+		sort.Slice(candidates, func(i, j int) bool {
+			iLeader, jLeader := strings.HasPrefix(candidates[i], leaderPrefix), strings.HasPrefix(candidates[j], leaderPrefix)
+			if iLeader != jLeader {
+				return iLeader
+			}
+			return podOrdinal(candidates[i]) < podOrdinal(candidates[j])
+		})
+		failovers = append(failovers, drainFailover{Master: masterPod, Replica: candidates[0]})
+	}
+	sort.Slice(failovers, func(i, j int) bool {
+		return failovers[i].Master < failovers[j].Master
+	})
+	sort.Strings(blocked)
+	return failovers, blocked
+}
+
+// getClusterPodDrainStates returns the draining pods of the cluster and the node of every pod
+func getClusterPodDrainStates(cr *redisv1beta1.RedisCluster) (map[string]bool, map[string]string, error) {
+	client := generateK8sClient()
+	selector := fmt.Sprintf("redis_setup_type=cluster,app in (%s-leader,%s-follower)", cr.ObjectMeta.Name, cr.ObjectMeta.Name)
+	pods, err := client.CoreV1().Pods(cr.Namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, nil, err
+	}
+	nodes := map[string]*corev1.Node{}
+	draining := map[string]bool{}
+	nodeOf := map[string]string{}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Spec.NodeName == "" {
+			continue
+		}
+		nodeOf[pod.Name] = pod.Spec.NodeName
+		node, ok := nodes[pod.Spec.NodeName]
+		if !ok {
+			// A node which cannot be read is not considered cordoned
+			node, _ = client.CoreV1().Nodes().Get(context.TODO(), pod.Spec.NodeName, metav1.GetOptions{})
+			nodes[pod.Spec.NodeName] = node
+		}
+		if IsPodDraining(pod, node) {
+			draining[pod.Name] = true
+		}
+	}
+	return draining, nodeOf, nil
+}
+
+// IsPodDraining checks if the pod is terminating, marked for eviction or runs on a cordoned node
+func IsPodDraining(pod *corev1.Pod, node *corev1.Node) bool {
+	if pod.DeletionTimestamp != nil || (node != nil && node.Spec.Unschedulable) {
+		return true
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == disruptionTargetCondition && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPodRedisCluster returns the cluster of a redis cluster pod or an empty name
+func GetPodRedisCluster(pod *corev1.Pod) types.NamespacedName {
+	if pod.Labels["redis_setup_type"] != "cluster" {
+		return types.NamespacedName{}
+	}
+	app := pod.Labels["app"]
+	for _, suffix := range []string{"-leader", "-follower"} {
+		if strings.HasSuffix(app, suffix) {
+			return types.NamespacedName{Namespace: pod.Namespace, Name: strings.TrimSuffix(app, suffix)}
+		}
+	}
+	return types.NamespacedName{}
+}
+
+// GetPodsRedisClusters returns the clusters the pods belong to
+func GetPodsRedisClusters(pods []corev1.Pod) []types.NamespacedName {
+	var clusters []types.NamespacedName
+	for i := range pods {
+		cluster := GetPodRedisCluster(&pods[i])
+		if cluster.Name == "" || containsNamespacedName(clusters, cluster) {
+			continue
+		}
+		clusters = append(clusters, cluster)
+	}
+	return clusters
+}
+
+// containsNamespacedName checks if the name is in the list
+func containsNamespacedName(names []types.NamespacedName, name types.NamespacedName) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package k8sutils
+
+import (
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPlanDrainFailovers(t *testing.T) {
+	topology := &ClusterTopology{
+		Nodes: clusterNodesFromOutput(`
+a 10.0.0.1:6379@16379 myself,master - 0 0 1 connected 0-5460
+b 10.0.0.2:6379@16379 master - 0 0 2 connected 5461-10922
+c 10.0.0.3:6379@16379 master - 0 0 3 connected 10923-16383
+d 10.0.0.4:6379@16379 slave a 0 0 1 connected
+e 10.0.0.5:6379@16379 slave b 0 0 2 connected
+f 10.0.0.6:6379@16379 slave c 0 0 3 connected
+g 10.0.0.7:6379@16379 slave a 0 0 1 connected`),
+		PodsByIP: map[string]string{
+			"10.0.0.1": "redis-leader-0", "10.0.0.2": "redis-leader-1", "10.0.0.3": "redis-leader-2",
+			"10.0.0.4": "redis-follower-0", "10.0.0.5": "redis-follower-1", "10.0.0.6": "redis-follower-2",
+			"10.0.0.7": "redis-leader-3",
+		},
+	}
+	cr := &redisv1beta1.RedisCluster{ObjectMeta: metav1.ObjectMeta{Name: "redis"}}
+	cr.Status.ReplicationLag = []redisv1beta1.ShardReplicationLag{
+		{Master: "redis-leader-0", Replicas: []redisv1beta1.ReplicaLag{{Pod: "redis-follower-0", InSync: true}, {Pod: "redis-leader-3", InSync: true}}},
+		{Master: "redis-leader-1", Replicas: []redisv1beta1.ReplicaLag{{Pod: "redis-follower-1"}}},
+		{Master: "redis-leader-2", Replicas: []redisv1beta1.ReplicaLag{{Pod: "redis-follower-2", InSync: true}}},
+	}
+	// node-1 is cordoned, leader-2 and its replica share node-3
+	draining := map[string]bool{"redis-leader-0": true, "redis-leader-1": true, "redis-leader-2": true, "redis-follower-0": true}
+	nodeOf := map[string]string{
+		"redis-leader-0": "node-1", "redis-leader-1": "node-2", "redis-leader-2": "node-3",
+		"redis-follower-0": "node-1", "redis-follower-1": "node-1", "redis-follower-2": "node-3", "redis-leader-3": "node-2",
+	}
+	failovers, blocked := planDrainFailovers(cr, topology, draining, nodeOf)
+	if want := []drainFailover{{Master: "redis-leader-0", Replica: "redis-leader-3"}}; !reflect.DeepEqual(failovers, want) {
+		t.Errorf("got failovers %v, want %v", failovers, want)
+	}
+	if want := []string{"redis-leader-1", "redis-leader-2"}; !reflect.DeepEqual(blocked, want) {
+		t.Errorf("got blocked %v, want %v", blocked, want)
+	}
+}
+
+func TestIsPodDraining(t *testing.T) {
+	now := metav1.Now()
+	tests := []struct {
+		name string
+		pod  corev1.Pod
+		node *corev1.Node
+		want bool
+	}{
+		{name: "running pod", pod: corev1.Pod{}, node: &corev1.Node{}},
+		{name: "cordoned node", pod: corev1.Pod{}, node: &corev1.Node{Spec: corev1.NodeSpec{Unschedulable: true}}, want: true},
+		{name: "terminating pod", pod: corev1.Pod{ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &now}}, want: true},
+		{
+			name: "eviction target",
+			pod:  corev1.Pod{Status: corev1.PodStatus{Conditions: []corev1.PodCondition{{Type: "DisruptionTarget", Status: corev1.ConditionTrue}}}},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsPodDraining(&tt.pod, tt.node); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetPodRoleLabels(t *testing.T) {
+	topology := &ClusterTopology{
+		Nodes: clusterNodesFromOutput(`
+a 10.0.0.1:6379@16379 myself,master - 0 0 1 connected 0-16383
+b 10.0.0.2:6379@16379 slave a 0 0 1 connected
+c 10.0.0.3:6379@16379 master - 0 0 2 connected
+d 10.0.0.4:6379@16379 master,fail - 0 0 3 connected
+e 10.0.0.5:6379@16379 slave a 0 0 1 connected`),
+		PodsByIP: map[string]string{
+			"10.0.0.1": "redis-follower-0", "10.0.0.2": "redis-leader-0", "10.0.0.3": "redis-leader-1", "10.0.0.4": "redis-leader-2",
+		},
+	}
+	// A master without slots may be evicted, the pod of a failed node and an unknown node keep their label
+	want := map[string]string{"redis-follower-0": "master", "redis-leader-0": "slave", "redis-leader-1": "slave"}
+	if got := getPodRoleLabels(topology); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,268 @@
+package k8sutils
+
+import (
+	"context"
+	"fmt"
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	redisBusPort = 16379
+	// podNameLabel is set by the statefulset controller on every pod
+	podNameLabel = "statefulset.kubernetes.io/pod-name"
+)
+
+// externalAddress is the address a pod is reached at from outside Kubernetes
+type externalAddress struct {
+	IP      string
+	Port    int
+	BusPort int
+}
+
+// ReconcileRedisClusterExternalServices will create a LoadBalancer or NodePort service for every pod of the cluster
+// when external access is enabled and delete the pod services no pod needs, it returns the number of services deleted
+func ReconcileRedisClusterExternalServices(cr *redisv1beta1.RedisCluster) (int, error) {
+	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
+	expected := map[string]bool{}
+	if isExternalAccessEnabled(cr) {
+		for _, role := range []string{"leader", "follower"} {
+			stsName := cr.ObjectMeta.Name + "-" + role
+			replicas := RedisClusterSTS{RedisStateFulType: role}.getReplicaCount(cr)
+			for podCount := 0; podCount < int(replicas); podCount++ {
+				podName := stsName + "-" + strconv.Itoa(podCount)
+				if err := createOrPatchService(cr.Namespace, generateExternalServiceDef(cr, stsName, role, podName)); err != nil {
+					return 0, err
+				}
+				expected[podName+"-external"] = true
+			}
+		}
+	}
+
+	services, err := generateK8sClient().CoreV1().Services(cr.Namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: getExternalServiceSelector(cr)})
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, service := range services.Items {
+		if expected[service.Name] {
+			continue
+		}
+		if err := generateK8sClient().CoreV1().Services(cr.Namespace).Delete(context.TODO(), service.Name, metav1.DeleteOptions{}); err != nil {
+			return deleted, err
+		}
+		logger.Info("Pod service no longer needed is deleted", "Service", service.Name)
+		deleted++
+	}
+	return deleted, nil
+}
+
+// generateExternalServiceDef generates the service exposing the client and the bus port of a single pod
+func generateExternalServiceDef(cr *redisv1beta1.RedisCluster, stsName, role, podName string) *corev1.Service {
+	labels := getRedisLabels(stsName, "cluster", role, cr.ObjectMeta.Labels)
+	labels[podNameLabel] = podName
+	annotations := generateServiceAnots(cr.ObjectMeta)
+	for key, value := range cr.Spec.ExternalAccess.Annotations {
+		annotations[key] = value
+	}
+	service := generateServiceDef(generateObjectMetaInformation(podName+"-external", cr.Namespace, labels, annotations), false, RedisClusterAsOwner(cr), false)
+	service.Spec.Type = generateServiceType(cr.Spec.ExternalAccess.Type)
+	if service.Spec.Type == corev1.ServiceTypeClusterIP {
+		service.Spec.Type = corev1.ServiceTypeLoadBalancer
+	}
+	// Clients and the other nodes have to reach this very pod, not any ready pod
+	service.Spec.PublishNotReadyAddresses = true
+	service.Spec.Ports = append(service.Spec.Ports, corev1.ServicePort{
+		Name:       "redis-bus",
+		Port:       redisBusPort,
+		TargetPort: intstr.FromInt(redisBusPort),
+		Protocol:   corev1.ProtocolTCP,
+	})
+	return service
+}
+
+// ConfigureRedisClusterExternalAccess will make every node announce the address of its pod service, the announced
+// addresses are reset once the pod services are deleted
+func ConfigureRedisClusterExternalAccess(cr *redisv1beta1.RedisCluster, servicesDeleted int) error {
+	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
+	if !isExternalAccessEnabled(cr) && servicesDeleted == 0 {
+		return nil
+	}
+	addresses, err := getExternalAddresses(cr)
+	if err != nil {
+		return err
+	}
+	for _, role := range []string{"leader", "follower"} {
+		for podCount := 0; podCount < int(cr.Spec.GetReplicaCounts(role)); podCount++ {
+			podName := cr.ObjectMeta.Name + "-" + role + "-" + strconv.Itoa(podCount)
+			address, ok := addresses[podName]
+			if isExternalAccessEnabled(cr) && !ok {
+				logger.Info("Waiting for the pod service to be assigned an address", "Pod", podName)
+				continue
+			}
+			client := configureRedisClient(cr, podName)
+			err := applyRedisConfig(client, generateAnnounceConfig(address))
+			if err == nil && ok {
+				// Redis 7 nodes announcing hostnames would redirect external clients to the headless service
+				if current, getErr := client.ConfigGet("cluster-preferred-endpoint-type").Result(); getErr == nil && len(current) == 2 && current[1] != "ip" {
+					err = client.ConfigSet("cluster-preferred-endpoint-type", "ip").Err()
+				}
+			}
+			client.Close()
+			if err != nil {
+				logger.Error(err, "Could not configure the announced address", "Pod", podName)
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// generateAnnounceConfigData will render the announced address or Redis 7+ hostname of every pod of the statefulset into
+// the config read at its startup, so a restarted pod does not announce its pod IP until the next reconcile
+func generateAnnounceConfigData(cr *redisv1beta1.RedisCluster, stsName string, replicas *int32) (map[string]string, error) {
+	if !isExternalAccessEnabled(cr) {
+		if useRedisClusterHostnames(cr) {
+			return renderHostnameConfigs(cr.Namespace, stsName, *replicas), nil
+		}
+		return nil, nil
+	}
+	addresses, err := getExternalAddresses(cr)
+	if err != nil {
+		return nil, err
+	}
+	return renderAnnounceConfigs(addresses, stsName, *replicas), nil
+}
+
+// renderAnnounceConfigs returns the announce config of every pod of the statefulset by pod name, a pod whose service
+// has no address yet gets an empty config as the mount of a missing key would fail its start
+func renderAnnounceConfigs(addresses map[string]externalAddress, stsName string, replicas int32) map[string]string {
+	configs := map[string]string{}
+	for podCount := 0; podCount < int(replicas); podCount++ {
+		podName := stsName + "-" + strconv.Itoa(podCount)
+		configs[podName] = ""
+		if address, ok := addresses[podName]; ok {
+			configs[podName] = renderRedisConfig(generateAnnounceConfig(address))
+		}
+	}
+	return configs
+}
+
+// generateAnnounceConfig will render the announced address into redis config directives, an empty address resets them
+func generateAnnounceConfig(address externalAddress) []redisConfigParam {
+	return []redisConfigParam{
+		{Name: "cluster-announce-ip", Value: address.IP},
+		{Name: "cluster-announce-port", Value: strconv.Itoa(address.Port)},
+		{Name: "cluster-announce-bus-port", Value: strconv.Itoa(address.BusPort)},
+	}
+}
+
+// getExternalAddresses returns the external address of every pod by pod name, pods whose service has no address yet are left out
+func getExternalAddresses(cr *redisv1beta1.RedisCluster) (map[string]externalAddress, error) {
+	addresses := map[string]externalAddress{}
+	if !isExternalAccessEnabled(cr) {
+		return addresses, nil
+	}
+	client := generateK8sClient()
+	services, err := client.CoreV1().Services(cr.Namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: getExternalServiceSelector(cr)})
+	if err != nil {
+		return nil, err
+	}
+	nodes := map[string]*corev1.Node{}
+	for i := range services.Items {
+		service := &services.Items[i]
+		podName := service.Labels[podNameLabel]
+		var node *corev1.Node
+		if service.Spec.Type == corev1.ServiceTypeNodePort {
+			pod, err := client.CoreV1().Pods(cr.Namespace).Get(context.TODO(), podName, metav1.GetOptions{})
+			if err != nil || pod.Spec.NodeName == "" {
+				continue
+			}
+			var ok bool
+			if node, ok = nodes[pod.Spec.NodeName]; !ok {
+				if node, err = client.CoreV1().Nodes().Get(context.TODO(), pod.Spec.NodeName, metav1.GetOptions{}); err != nil {
+					return nil, err
+				}
+				nodes[pod.Spec.NodeName] = node
+			}
+		}
+		if address, ok := getServiceExternalAddress(service, node); ok {
+			addresses[podName] = address
+		}
+	}
+	return addresses, nil
+}
+
+// getServiceExternalAddress returns the address of a pod service, the load balancer IP or the node IP with the node ports
+func getServiceExternalAddress(service *corev1.Service, node *corev1.Node) (externalAddress, bool) {
+	address := externalAddress{}
+	switch service.Spec.Type {
+	case corev1.ServiceTypeLoadBalancer:
+		for _, ingress := range service.Status.LoadBalancer.Ingress {
+			if ingress.IP != "" {
+				address.IP = ingress.IP
+				break
+			}
+		}
+		address.Port, address.BusPort = redisPort, redisBusPort
+	case corev1.ServiceTypeNodePort:
+		if node == nil {
+			return address, false
+		}
+		for _, addressType := range []corev1.NodeAddressType{corev1.NodeExternalIP, corev1.NodeInternalIP} {
+			for _, nodeAddress := range node.Status.Addresses {
+				if address.IP == "" && nodeAddress.Type == addressType {
+					address.IP = nodeAddress.Address
+				}
+			}
+		}
+		for _, port := range service.Spec.Ports {
+			switch port.Port {
+			case redisPort:
+				address.Port = int(port.NodePort)
+			case redisBusPort:
+				address.BusPort = int(port.NodePort)
+			}
+		}
+	}
+	return address, address.IP != "" && address.Port != 0 && address.BusPort != 0
+}
+
+// getAnnouncedPodIPs returns the pod IPs by the external address their nodes announce
+func getAnnouncedPodIPs(cr *redisv1beta1.RedisCluster, podsByIP map[string]string) (map[string]string, error) {
+	addresses, err := getExternalAddresses(cr)
+	if err != nil {
+		return nil, err
+	}
+	announced := map[string]string{}
+	for podIP, podName := range podsByIP {
+		if address, ok := addresses[podName]; ok {
+			announced[fmt.Sprintf("%s:%d", address.IP, address.Port)] = podIP
+		}
+	}
+	return announced, nil
+}
+
+// translateAnnouncedAddresses will replace the external addresses announced by the nodes with the addresses of their pods
+func translateAnnouncedAddresses(nodes []ClusterNode, announced map[string]string) []ClusterNode {
+	for i := range nodes {
+		if podIP, ok := announced[fmt.Sprintf("%s:%d", nodes[i].IP, nodes[i].Port)]; ok {
+			nodes[i].IP, nodes[i].Port, nodes[i].BusPort = podIP, redisPort, redisBusPort
+		}
+	}
+	return nodes
+}
+
+// getExternalServiceSelector returns the label selector of the pod services of the cluster
+func getExternalServiceSelector(cr *redisv1beta1.RedisCluster) string {
+	return fmt.Sprintf("redis_setup_type=cluster,app in (%s-leader,%s-follower),%s", cr.ObjectMeta.Name, cr.ObjectMeta.Name, podNameLabel)
+}
+
+// isExternalAccessEnabled checks if the pods of the cluster are exposed through their own service
+func isExternalAccessEnabled(cr *redisv1beta1.RedisCluster) bool {
+	return cr.Spec.ExternalAccess != nil && cr.Spec.ExternalAccess.Enabled
+}
@@ -0,0 +1,92 @@
+package k8sutils
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestGetServiceExternalAddress(t *testing.T) {
+	nodePorts := []corev1.ServicePort{{Name: "redis-client", Port: 6379, NodePort: 31001}, {Name: "redis-bus", Port: 16379, NodePort: 31002}}
+	tests := []struct {
+		name    string
+		service corev1.Service
+		node    *corev1.Node
+		want    externalAddress
+		wantOK  bool
+	}{
+		{
+			name:    "pending load balancer",
+			service: corev1.Service{Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer}},
+			want:    externalAddress{Port: 6379, BusPort: 16379},
+		},
+		{
+			name: "load balancer",
+			service: corev1.Service{
+				Spec:   corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer},
+				Status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{Ingress: []corev1.LoadBalancerIngress{{IP: "203.0.113.10"}}}},
+			},
+			want:   externalAddress{IP: "203.0.113.10", Port: 6379, BusPort: 16379},
+			wantOK: true,
+		},
+		{
+			name:    "node port on a node with external IP",
+			service: corev1.Service{Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeNodePort, Ports: nodePorts}},
+			node: &corev1.Node{Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalIP, Address: "10.1.0.5"},
+				{Type: corev1.NodeExternalIP, Address: "198.51.100.5"},
+			}}},
+			want:   externalAddress{IP: "198.51.100.5", Port: 31001, BusPort: 31002},
+			wantOK: true,
+		},
+		{
+			name:    "node port on a private node",
+			service: corev1.Service{Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeNodePort, Ports: nodePorts}},
+			node:    &corev1.Node{Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "10.1.0.5"}}}},
+			want:    externalAddress{IP: "10.1.0.5", Port: 31001, BusPort: 31002},
+			wantOK:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := getServiceExternalAddress(&tt.service, tt.node)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("got %+v %v, want %+v %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestTranslateAnnouncedAddresses(t *testing.T) {
+	// Two pods share a node and are told apart by their node ports
+	nodes := translateAnnouncedAddresses(clusterNodesFromOutput(`
+a 10.1.0.5:31001@31002 myself,master - 0 0 1 connected 0-8191
+b 10.1.0.5:31003@31004 master - 0 0 2 connected 8192-16383
+c 10.244.0.7:6379@16379 slave a 0 0 1 connected`), map[string]string{"10.1.0.5:31001": "10.244.0.5", "10.1.0.5:31003": "10.244.0.6"})
+	var got []string
+	for _, node := range nodes {
+		got = append(got, node.IP)
+		if node.Port != 6379 || node.BusPort != 16379 {
+			t.Errorf("node %s kept the announced ports %d and %d", node.ID, node.Port, node.BusPort)
+		}
+	}
+	if want := []string{"10.244.0.5", "10.244.0.6", "10.244.0.7"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestRenderAnnounceConfigs(t *testing.T) {
+	addresses := map[string]externalAddress{
+		"redis-leader-0":   {IP: "203.0.113.10", Port: 6379, BusPort: 16379},
+		"redis-follower-0": {IP: "203.0.113.20", Port: 6379, BusPort: 16379},
+	}
+	// The pod without an address still gets a key, its mount would fail otherwise
+	want := map[string]string{
+		"redis-leader-0": "cluster-announce-ip 203.0.113.10\ncluster-announce-port 6379\ncluster-announce-bus-port 16379\n",
+		"redis-leader-1": "",
+	}
+	if got := renderAnnounceConfigs(addresses, "redis-leader", 2); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -2,12 +2,20 @@ package k8sutils
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	redisv1beta1 "redis-operator/api/v1beta1"
 	"strconv"
+	"strings"
 
 	"github.com/go-logr/logr"
+	grafanav1alpha1 "github.com/grafana-operator/grafana-operator/v4/api/integreatly/v1alpha1"
+	prometheusv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
@@ -17,6 +25,20 @@ const (
 	RedisClusterFinalizer string = "redisClusterFinalizer"
 )
 
+// ownedResources describes the objects belonging to a single Redis or RedisCluster instance
+type ownedResources struct {
+	namespace string
+	uid       types.UID
+	setupType string
+	// statefulSets are the statefulset names whose volume claims belong to the instance
+	statefulSets []string
+	// storageMigrations name the claim templates the statefulsets were migrated to
+	storageMigrations []redisv1beta1.StorageMigrationStatus
+	// legacyDashboards and legacyServiceMonitors are matched by name when they were created without owner references
+	legacyDashboards      []string
+	legacyServiceMonitors []string
+}
+
 // finalizeLogger will generate logging interface
 func finalizerLogger(namespace string, name string) logr.Logger {
 	reqLogger := log.WithValues("Request.Service.Namespace", namespace, "Request.Finalizer.Name", name)
@@ -24,22 +46,15 @@ func finalizerLogger(namespace string, name string) logr.Logger {
 }
 
 // HandleRedisFinalizer finalize resource if instance is marked to be deleted
-func HandleRedisFinalizer(cr *redisv1beta1.Redis, cl client.Client) error {
+func HandleRedisFinalizer(cr *redisv1beta1.Redis, cl client.Client, recorder record.EventRecorder) error {
 	logger := finalizerLogger(cr.Namespace, RedisFinalizer)
 	if cr.GetDeletionTimestamp() != nil {
 		if controllerutil.ContainsFinalizer(cr, RedisFinalizer) {
-			if err := finalizeRedisServices(cr); err != nil {
-				return err
-			}
-			if err := finalizeRedisPVC(cr); err != nil {
-				return err
-			}
-			if err := finalizeGrafanaDahsboard(cr.Namespace, cr.Name, false); err != nil {
-				return err
-			}
-			if err := finalizeServiceMonitor(cr.Namespace, cr.Name, false); err != nil {
+			removed, err := finalizeOwnedResources(redisOwnedResources(cr))
+			if err != nil {
 				return err
 			}
+			recordRemovedResources(recorder, cr, "Finalized", removed)
 			controllerutil.RemoveFinalizer(cr, RedisFinalizer)
 			if err := cl.Update(context.TODO(), cr); err != nil {
 				logger.Error(err, "Could not remove finalizer "+RedisFinalizer)
@@ -51,22 +66,15 @@ func HandleRedisFinalizer(cr *redisv1beta1.Redis, cl client.Client) error {
 }
 
 // HandleRedisClusterFinalizer finalize resource if instance is marked to be deleted
-func HandleRedisClusterFinalizer(cr *redisv1beta1.RedisCluster, cl client.Client) error {
+func HandleRedisClusterFinalizer(cr *redisv1beta1.RedisCluster, cl client.Client, recorder record.EventRecorder) error {
 	logger := finalizerLogger(cr.Namespace, RedisClusterFinalizer)
 	if cr.GetDeletionTimestamp() != nil {
 		if controllerutil.ContainsFinalizer(cr, RedisClusterFinalizer) {
-			if err := finalizeRedisClusterServices(cr); err != nil {
-				return err
-			}
-			if err := finalizeRedisClusterPVC(cr); err != nil {
-				return err
-			}
-			if err := finalizeGrafanaDahsboard(cr.Namespace, cr.Name, true); err != nil {
-				return err
-			}
-			if err := finalizeServiceMonitor(cr.Namespace, cr.Name, true); err != nil {
+			removed, err := finalizeOwnedResources(redisClusterOwnedResources(cr))
+			if err != nil {
 				return err
 			}
+			recordRemovedResources(recorder, cr, "Finalized", removed)
 			controllerutil.RemoveFinalizer(cr, RedisClusterFinalizer)
 			if err := cl.Update(context.TODO(), cr); err != nil {
 				logger.Error(err, "Could not remove finalizer "+RedisClusterFinalizer)
@@ -95,128 +103,312 @@ func AddRedisClusterFinalizer(cr *redisv1beta1.RedisCluster, cl client.Client) e
 	return nil
 }
 
-// finalizeRedisServices delete Services
-func finalizeRedisServices(cr *redisv1beta1.Redis) error {
-	logger := finalizerLogger(cr.Namespace, RedisFinalizer)
-	serviceName, headlessServiceName := cr.Name, cr.Name+"-headless"
-	for _, svc := range []string{serviceName, headlessServiceName} {
-		err := generateK8sClient().CoreV1().Services(cr.Namespace).Delete(context.TODO(), svc, metav1.DeleteOptions{})
-		if err != nil && !errors.IsNotFound(err) {
-			logger.Error(err, "Could not delete service "+svc)
-			return err
+// PruneRedisClusterResources removes the volume claims and services left behind by a scale-down
+func PruneRedisClusterResources(cr *redisv1beta1.RedisCluster, recorder record.EventRecorder) error {
+	logger := finalizerLogger(cr.Namespace, cr.ObjectMeta.Name)
+	pvcs, err := listOwnedPVCs(redisClusterOwnedResources(cr))
+	if err != nil {
+		return err
+	}
+	var removed []string
+	for _, role := range []string{"leader", "follower"} {
+		stsName := cr.ObjectMeta.Name + "-" + role
+		replicas := int(cr.Spec.GetReplicaCounts(role))
+		for _, pvc := range pvcs {
+			ordinal, ok := claimOrdinal(pvc.Name, stsName, cr.Status.StorageMigrations)
+			if !ok || ordinal < replicas {
+				continue
+			}
+			podName := stsName + "-" + strconv.Itoa(ordinal)
+			_, err := generateK8sClient().CoreV1().Pods(cr.Namespace).Get(context.TODO(), podName, metav1.GetOptions{})
+			if err == nil {
+				logger.Info("Pod of scaled down volume claim is still running, skipping prune", "Pod", podName, "PVC", pvc.Name)
+				continue
+			}
+			if !errors.IsNotFound(err) {
+				return err
+			}
+			if err := deletePVC(cr.Namespace, pvc.Name); err != nil {
+				return err
+			}
+			removed = append(removed, "PersistentVolumeClaim/"+pvc.Name)
+		}
+		if replicas == 0 {
+			for _, svc := range []string{stsName, stsName + "-headless"} {
+				err := generateK8sClient().CoreV1().Services(cr.Namespace).Delete(context.TODO(), svc, metav1.DeleteOptions{})
+				if err != nil && !errors.IsNotFound(err) {
+					logger.Error(err, "Could not delete service "+svc)
+					return err
+				} else if err == nil {
+					removed = append(removed, "Service/"+svc)
+				}
+			}
 		}
 	}
+	recordRemovedResources(recorder, cr, "Pruned", removed)
 	return nil
 }
 
-// finalizeRedisClusterServices delete Services
-func finalizeRedisClusterServices(cr *redisv1beta1.RedisCluster) error {
-	logger := finalizerLogger(cr.Namespace, RedisClusterFinalizer)
-	serviceName, headlessServiceName := cr.Name, cr.Name+"-headless"
-	for _, svc := range []string{serviceName, headlessServiceName} {
-		err := generateK8sClient().CoreV1().Services(cr.Namespace).Delete(context.TODO(), svc, metav1.DeleteOptions{})
+// redisOwnedResources describes the resources created for a standalone Redis
+func redisOwnedResources(cr *redisv1beta1.Redis) ownedResources {
+	return ownedResources{
+		namespace:             cr.Namespace,
+		uid:                   cr.UID,
+		setupType:             "standalone",
+		statefulSets:          []string{cr.ObjectMeta.Name},
+		storageMigrations:     cr.Status.StorageMigrations,
+		legacyDashboards:      []string{cr.ObjectMeta.Name + "-standalone"},
+		legacyServiceMonitors: []string{cr.ObjectMeta.Name + "-standalone"},
+	}
+}
+
+// redisClusterOwnedResources describes the resources created for a RedisCluster
+func redisClusterOwnedResources(cr *redisv1beta1.RedisCluster) ownedResources {
+	return ownedResources{
+		namespace:             cr.Namespace,
+		uid:                   cr.UID,
+		setupType:             "cluster",
+		statefulSets:          []string{cr.ObjectMeta.Name + "-leader", cr.ObjectMeta.Name + "-follower"},
+		storageMigrations:     cr.Status.StorageMigrations,
+		legacyDashboards:      []string{cr.ObjectMeta.Name + "-cluster"},
+		legacyServiceMonitors: []string{cr.ObjectMeta.Name + "-leader", cr.ObjectMeta.Name + "-follower"},
+	}
+}
+
+// finalizeOwnedResources deletes every Service, PVC, PDB, ServiceMonitor and GrafanaDashboard of the instance
+func finalizeOwnedResources(owned ownedResources) ([]string, error) {
+	var removed []string
+	for _, finalize := range []func(ownedResources) ([]string, error){
+		finalizeServices,
+		finalizePVCs,
+		finalizePodDisruptionBudgets,
+		finalizeServiceMonitors,
+		finalizeGrafanaDashboards,
+	} {
+		objects, err := finalize(owned)
+		removed = append(removed, objects...)
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// finalizeServices delete Services
+func finalizeServices(owned ownedResources) ([]string, error) {
+	logger := finalizerLogger(owned.namespace, "Service")
+	services, err := generateK8sClient().CoreV1().Services(owned.namespace).List(context.TODO(), owned.listOptions())
+	if err != nil {
+		logger.Error(err, "Could not list services")
+		return nil, err
+	}
+	var removed []string
+	for _, svc := range services.Items {
+		if !isOwnedBy(&svc, owned.uid) {
+			continue
+		}
+		err := generateK8sClient().CoreV1().Services(owned.namespace).Delete(context.TODO(), svc.Name, metav1.DeleteOptions{})
 		if err != nil && !errors.IsNotFound(err) {
-			logger.Error(err, "Could not delete service "+svc)
-			return err
+			logger.Error(err, "Could not delete service "+svc.Name)
+			return removed, err
 		}
+		removed = append(removed, "Service/"+svc.Name)
 	}
-	return nil
+	return removed, nil
 }
 
-// finalizeRedisPVC delete PVC
-func finalizeRedisPVC(cr *redisv1beta1.Redis) error {
-	logger := finalizerLogger(cr.Namespace, RedisFinalizer)
-	PVCName := cr.Name + "-" + cr.Name + "-0"
-	err := generateK8sClient().CoreV1().PersistentVolumeClaims(cr.Namespace).Delete(context.TODO(), PVCName, metav1.DeleteOptions{})
-	if err != nil && !errors.IsNotFound(err) {
-		logger.Error(err, "Could not delete Persistent Volume Claim "+PVCName)
-		return err
+// finalizePVCs delete PVCs
+func finalizePVCs(owned ownedResources) ([]string, error) {
+	pvcs, err := listOwnedPVCs(owned)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	var removed []string
+	for _, pvc := range pvcs {
+		if err := deletePVC(owned.namespace, pvc.Name); err != nil {
+			return removed, err
+		}
+		removed = append(removed, "PersistentVolumeClaim/"+pvc.Name)
+	}
+	return removed, nil
 }
 
-// finalizeRedisClusterPVC delete PVCs
-func finalizeRedisClusterPVC(cr *redisv1beta1.RedisCluster) error {
-	logger := finalizerLogger(cr.Namespace, RedisClusterFinalizer)
-	for _, role := range []string{"leader", "follower"} {
-		for i := 0; i < int(cr.Spec.GetReplicaCounts(role)); i++ {
-			PVCName := cr.Name + "-" + role + "-" + cr.Name + "-" + role + "-" + strconv.Itoa(i)
-			err := generateK8sClient().CoreV1().PersistentVolumeClaims(cr.Namespace).Delete(context.TODO(), PVCName, metav1.DeleteOptions{})
-			if err != nil && !errors.IsNotFound(err) {
-				logger.Error(err, "Could not delete Persistent Volume Claim "+PVCName)
-				return err
-			}
+// finalizePodDisruptionBudgets delete PodDisruptionBudgets
+func finalizePodDisruptionBudgets(owned ownedResources) ([]string, error) {
+	logger := finalizerLogger(owned.namespace, "PodDisruptionBudget")
+	pdbs, err := generateK8sClient().PolicyV1().PodDisruptionBudgets(owned.namespace).List(context.TODO(), owned.listOptions())
+	if err != nil {
+		logger.Error(err, "Could not list PodDisruptionBudgets")
+		return nil, err
+	}
+	var removed []string
+	for _, pdb := range pdbs.Items {
+		if !isOwnedBy(&pdb, owned.uid) {
+			continue
 		}
+		if err := deletePodDisruptionBudget(owned.namespace, pdb.Name); err != nil && !errors.IsNotFound(err) {
+			return removed, err
+		}
+		removed = append(removed, "PodDisruptionBudget/"+pdb.Name)
 	}
-	return nil
+	return removed, nil
 }
 
-// finalizeGrafanaDahsboard delete GrafanaDashboard
-func finalizeGrafanaDahsboard(namespace, redisName string, isCluster bool) error {
-	logger := finalizerLogger(namespace, redisName)
-	if isCluster {
-		_, err := getGrafanaDashboard(namespace, redisName, true)
-		if err != nil && !errors.IsNotFound(err) {
-			return err
-		} else if err == nil {
-			if _, err := generateK8sClient().RESTClient().Delete().AbsPath("/apis/integreatly.org/v1alpha1/namespaces/" + namespace + "/grafanadashboards").Name(redisName + "-cluster").DoRaw(context.TODO()); err != nil {
-				logger.Error(err, "Failed to delete GrafanaDahsboard")
-				return err
-			}
+// finalizeServiceMonitors delete ServiceMonitors
+func finalizeServiceMonitors(owned ownedResources) ([]string, error) {
+	logger := finalizerLogger(owned.namespace, "ServiceMonitor")
+	path := "/apis/monitoring.coreos.com/v1/namespaces/" + owned.namespace + "/servicemonitors"
+	data, err := generateK8sClient().RESTClient().Get().AbsPath(path).DoRaw(context.TODO())
+	if err != nil {
+		// The prometheus operator is optional, nothing to clean up without its CRD
+		if errors.IsNotFound(err) {
+			return nil, nil
 		}
-	} else {
-		_, err := getGrafanaDashboard(namespace, redisName, false)
-		if err != nil && !errors.IsNotFound(err) {
-			return err
-		} else if err == nil {
-			if _, err := generateK8sClient().RESTClient().Delete().AbsPath("/apis/integreatly.org/v1alpha1/namespaces/" + namespace + "/grafanadashboards").Name(redisName + "-standalone").DoRaw(context.TODO()); err != nil {
-				logger.Error(err, "Failed to delete GrafanaDahsboard")
-				return err
-			}
+		logger.Error(err, "Could not list ServiceMonitors")
+		return nil, err
+	}
+	var monitors prometheusv1.ServiceMonitorList
+	if err := json.Unmarshal(data, &monitors); err != nil {
+		return nil, err
+	}
+	var removed []string
+	for _, sm := range monitors.Items {
+		if !isOwnedBy(sm, owned.uid) && !isLegacyObject(sm, owned.legacyServiceMonitors) {
+			continue
+		}
+		if _, err := generateK8sClient().RESTClient().Delete().AbsPath(path).Name(sm.Name).DoRaw(context.TODO()); err != nil && !errors.IsNotFound(err) {
+			logger.Error(err, "Failed to delete ServiceMonitor "+sm.Name)
+			return removed, err
+		}
+		removed = append(removed, "ServiceMonitor/"+sm.Name)
+	}
+	return removed, nil
+}
+
+// finalizeGrafanaDashboards delete GrafanaDashboards
+func finalizeGrafanaDashboards(owned ownedResources) ([]string, error) {
+	logger := finalizerLogger(owned.namespace, "GrafanaDashboard")
+	path := "/apis/integreatly.org/v1alpha1/namespaces/" + owned.namespace + "/grafanadashboards"
+	data, err := generateK8sClient().RESTClient().Get().AbsPath(path).DoRaw(context.TODO())
+	if err != nil {
+		// The grafana operator is optional, nothing to clean up without its CRD
+		if errors.IsNotFound(err) {
+			return nil, nil
 		}
+		logger.Error(err, "Could not list GrafanaDashboards")
+		return nil, err
 	}
+	var dashboards grafanav1alpha1.GrafanaDashboardList
+	if err := json.Unmarshal(data, &dashboards); err != nil {
+		return nil, err
+	}
+	var removed []string
+	for i := range dashboards.Items {
+		dsb := &dashboards.Items[i]
+		if !isOwnedBy(dsb, owned.uid) && !isLegacyObject(dsb, owned.legacyDashboards) {
+			continue
+		}
+		if _, err := generateK8sClient().RESTClient().Delete().AbsPath(path).Name(dsb.Name).DoRaw(context.TODO()); err != nil && !errors.IsNotFound(err) {
+			logger.Error(err, "Failed to delete GrafanaDahsboard "+dsb.Name)
+			return removed, err
+		}
+		removed = append(removed, "GrafanaDashboard/"+dsb.Name)
+	}
+	return removed, nil
+}
 
-	logger.Info("Delete GrafanaDashboard Success")
+// listOwnedPVCs returns the volume claims generated from the statefulsets of the instance
+func listOwnedPVCs(owned ownedResources) ([]corev1.PersistentVolumeClaim, error) {
+	logger := finalizerLogger(owned.namespace, "PersistentVolumeClaim")
+	pvcs, err := generateK8sClient().CoreV1().PersistentVolumeClaims(owned.namespace).List(context.TODO(), owned.listOptions())
+	if err != nil {
+		logger.Error(err, "Could not list Persistent Volume Claims")
+		return nil, err
+	}
+	var claims []corev1.PersistentVolumeClaim
+	for _, pvc := range pvcs.Items {
+		for _, sts := range owned.statefulSets {
+			if _, ok := claimOrdinal(pvc.Name, sts, owned.storageMigrations); ok {
+				claims = append(claims, pvc)
+				break
+			}
+		}
+	}
+	return claims, nil
+}
 
+// deletePVC delete a single PVC
+func deletePVC(namespace, name string) error {
+	logger := finalizerLogger(namespace, "PersistentVolumeClaim")
+	err := generateK8sClient().CoreV1().PersistentVolumeClaims(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		logger.Error(err, "Could not delete Persistent Volume Claim "+name)
+		return err
+	}
 	return nil
 }
 
-// finalizeServiceMonitor delete ServiceMonitor
-func finalizeServiceMonitor(namespace, redisName string, isCluster bool) error {
-	logger := finalizerLogger(namespace, redisName)
-	if isCluster {
-		_, err := getServiceMonitor(namespace, redisName, true, "leader")
-		if err != nil && !errors.IsNotFound(err) {
-			return err
-		} else if err == nil {
-			if _, err := generateK8sClient().RESTClient().Delete().AbsPath("/apis/monitoring.coreos.com/v1/namespaces/" + namespace + "/servicemonitors").Name(redisName + "-leader").DoRaw(context.TODO()); err != nil {
-				logger.Error(err, "Failed to delete ServiceMonitor")
-				return err
-			}
+// claimOrdinal returns the pod ordinal of a volume claim created from a claim template of the statefulset
+func claimOrdinal(claimName, stsName string, migrations []redisv1beta1.StorageMigrationStatus) (int, bool) {
+	// Claims are named <template>-<statefulset>-<ordinal>, only the templates the statefulset had are matched as
+	// the claims of another instance may embed the statefulset name
+	for _, template := range getClaimTemplateNames(stsName, migrations) {
+		suffix := strings.TrimPrefix(claimName, template+"-"+stsName+"-")
+		if suffix == claimName {
+			continue
+		}
+		if ordinal, err := strconv.Atoi(suffix); err == nil && strconv.Itoa(ordinal) == suffix && ordinal >= 0 {
+			return ordinal, true
 		}
+	}
+	return 0, false
+}
 
-		_, err = getServiceMonitor(namespace, redisName, true, "follower")
-		if err != nil && !errors.IsNotFound(err) {
-			return err
-		} else if err == nil {
-			if _, err := generateK8sClient().RESTClient().Delete().AbsPath("/apis/monitoring.coreos.com/v1/namespaces/" + namespace + "/servicemonitors").Name(redisName + "-follower").DoRaw(context.TODO()); err != nil {
-				logger.Error(err, "Failed to delete ServiceMonitor")
-				return err
+// getClaimTemplateNames returns the claim templates of the statefulset, its own name and those of a storage migration
+func getClaimTemplateNames(stsName string, migrations []redisv1beta1.StorageMigrationStatus) []string {
+	templates := []string{stsName}
+	if status := findStorageMigration(migrations, stsName); status != nil {
+		for _, template := range []string{status.ClaimTemplateName, status.SourceClaimTemplateName} {
+			if template != "" && template != stsName {
+				templates = append(templates, template)
 			}
 		}
-	} else {
-		_, err := getServiceMonitor(namespace, redisName, false, "")
-		if err != nil && !errors.IsNotFound(err) {
-			return err
-		} else if err == nil {
-			if _, err := generateK8sClient().RESTClient().Delete().AbsPath("/apis/monitoring.coreos.com/v1/namespaces/" + namespace + "/servicemonitors").Name(redisName + "-standalone").DoRaw(context.TODO()); err != nil {
-				logger.Error(err, "Failed to delete ServiceMonitor")
-				return err
-			}
+	}
+	return templates
+}
+
+// listOptions selects the objects labelled with the setup type of the instance
+func (owned ownedResources) listOptions() metav1.ListOptions {
+	return metav1.ListOptions{LabelSelector: "redis_setup_type=" + owned.setupType}
+}
+
+// isOwnedBy checks if the object carries an owner reference to the given uid
+func isOwnedBy(obj metav1.Object, uid types.UID) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID == uid {
+			return true
 		}
 	}
+	return false
+}
 
-	logger.Info("Delete ServiceMonitor Success")
+// isLegacyObject checks if the object has no owner and one of the expected names
+func isLegacyObject(obj metav1.Object, names []string) bool {
+	if len(obj.GetOwnerReferences()) != 0 {
+		return false
+	}
+	for _, name := range names {
+		if obj.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
 
-	return nil
+// recordRemovedResources emits a summary event of the removed objects
+func recordRemovedResources(recorder record.EventRecorder, obj client.Object, reason string, removed []string) {
+	if recorder == nil || len(removed) == 0 {
+		return
+	}
+	recorder.Event(obj, corev1.EventTypeNormal, reason, fmt.Sprintf("Removed %d resources: %s", len(removed), strings.Join(removed, ", ")))
 }
@@ -0,0 +1,38 @@
+package k8sutils
+
+import (
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"testing"
+)
+
+func TestClaimOrdinal(t *testing.T) {
+	migrations := []redisv1beta1.StorageMigrationStatus{
+		{StatefulSet: "redis-leader", ClaimTemplateName: "redis-leader-fast-ssd", SourceClaimTemplateName: "redis-leader"},
+	}
+	var tests = []struct {
+		claim   string
+		sts     string
+		ordinal int
+		want    bool
+	}{
+		{"redis-leader-redis-leader-2", "redis-leader", 2, true},
+		{"redis-leader-fast-ssd-redis-leader-0", "redis-leader", 0, true},
+		{"redis-follower-redis-follower-1", "redis-leader", 0, false},
+		{"redis-leader-redis-leader-x", "redis-leader", 0, false},
+		{"redis-leader-redis-leader-01", "redis-leader", 0, false},
+		{"data-redis-leader-0", "redis-leader", 0, false},
+		// Claims of another instance embedding the statefulset name
+		{"redis-leader-a-redis-leader-0", "redis-leader", 0, false},
+		{"redis-leader-a-redis-leader-a-0", "redis-leader", 0, false},
+		{"redis-leader-redis-leader-a-0", "redis-leader", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.claim, func(t *testing.T) {
+			ordinal, ok := claimOrdinal(tt.claim, tt.sts, migrations)
+			if ok != tt.want || ordinal != tt.ordinal {
+				t.Errorf("got %d,%t, want %d,%t", ordinal, ok, tt.ordinal, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package k8sutils
+
+import (
+	"fmt"
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clusterForgetWindow is how long a node refuses to relearn a forgotten node ID through gossip
+const clusterForgetWindow = 60 * time.Second
+
+// ReconcileRedisClusterGhostNodes will forget the failed node IDs no pod serves anymore and attach the restarted pods
+// which left them behind to their shard, it returns the actions taken
+func ReconcileRedisClusterGhostNodes(cr *redisv1beta1.RedisCluster, topology *ClusterTopology) ([]string, error) {
+	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
+	if !topology.Formed() {
+		return nil, nil
+	}
+	pods := getReadyClusterPods(cr)
+	if len(pods) < int(cr.Spec.GetReplicaCounts("leader")+cr.Spec.GetReplicaCounts("follower")) {
+		// A failed node may belong to a pod restarting with its data, only IDs unclaimed by every pod are ghosts
+		return nil, nil
+	}
+	served := map[string]bool{}
+	for _, pod := range pods {
+		served[pod.NodeID] = true
+	}
+	ghosts := findGhostNodes(topology.Nodes, served)
+	if len(ghosts) == 0 {
+		return nil, nil
+	}
+
+	var ghostIDs, actions []string
+	for _, ghost := range ghosts {
+		ghostIDs = append(ghostIDs, ghost.ID)
+	}
+	if err := forgetClusterNodes(cr, pods, ghostIDs); err != nil {
+		return nil, err
+	}
+	for _, nodeID := range ghostIDs {
+		actions = append(actions, fmt.Sprintf("Forgot ghost node %s", nodeID))
+	}
+	logger.Info("Ghost nodes forgotten by every node", "Nodes", ghostIDs)
+
+	domains, err := getPodFailureDomains(cr)
+	if err != nil {
+		logger.Error(err, "Could not get the failure domains of the pods")
+	}
+	nodes := append([]ClusterNode{}, topology.Nodes...)
+	for _, pod := range pods {
+		if len(ghosts) == 0 {
+			break
+		}
+		// A pod restarted without its data is unknown to the cluster or an empty master
+		if node := topology.NodeByID(pod.NodeID); node != nil && (!node.IsMaster() || len(node.Slots) > 0) {
+			continue
+		}
+		index := pairGhostNode(ghosts, pod.PodName)
+		ghost := ghosts[index]
+		ghosts = append(ghosts[:index], ghosts[index+1:]...)
+
+		masterID := getGhostShardMaster(topology, ghost)
+		if masterID == "" {
+			masterID = getMasterForReplica(nodes, domains, pod.IP)
+		}
+		master := topology.NodeByID(masterID)
+		if master == nil {
+			logger.Info("No master found for the restarted pod", "Pod", pod.PodName, "Ghost", ghost.ID)
+			continue
+		}
+		if err := attachRedisReplicaNative(cr, pod.PodName, master.ID, master.IP); err != nil {
+			return actions, err
+		}
+		// Count the attached replica so the next restarted pod goes to another master
+		nodes = append(nodes, ClusterNode{IP: pod.IP, Flags: []string{"slave"}, MasterID: master.ID, LinkState: "connected"})
+		actions = append(actions, fmt.Sprintf("Attached restarted pod %s as replica of %s", pod.PodName, topology.PodName(*master)))
+	}
+	return actions, nil
+}
+
+// findGhostNodes returns the failed nodes without slots whose ID no pod serves,
+// failed nodes still owning slots are left to the failover of their shard
+func findGhostNodes(nodes []ClusterNode, served map[string]bool) []ClusterNode {
+	var ghosts []ClusterNode
+	for _, node := range nodes {
+		if node.IsFailed() && !served[node.ID] && len(node.Slots) == 0 {
+			ghosts = append(ghosts, node)
+		}
+	}
+	return ghosts
+}
+
+// pairGhostNode returns the index of the ghost announced with the hostname of the pod, or of the first ghost
+func pairGhostNode(ghosts []ClusterNode, podName string) int {
+	for i, ghost := range ghosts {
+		if strings.HasPrefix(ghost.Hostname, podName+".") {
+			return i
+		}
+	}
+	return 0
+}
+
+// getGhostShardMaster returns the healthy master the ghost replicated, following a failover of that master
+func getGhostShardMaster(topology *ClusterTopology, ghost ClusterNode) string {
+	node := topology.NodeByID(ghost.MasterID)
+	if node != nil && node.IsReplica() {
+		node = topology.NodeByID(node.MasterID)
+	}
+	if node == nil || !node.IsMaster() || node.IsFailed() || len(node.Slots) == 0 {
+		return ""
+	}
+	return node.ID
+}
+
+// forgetClusterNodes will forget the node IDs on all pods in parallel, every node has to forget them
+// within the forget window or the nodes which still know them gossip them back
+func forgetClusterNodes(cr *redisv1beta1.RedisCluster, pods []clusterPod, nodeIDs []string) error {
+	start := time.Now()
+	errs := make(chan error, len(pods))
+	var wg sync.WaitGroup
+	for _, pod := range pods {
+		wg.Add(1)
+		go func(podName string) {
+			defer wg.Done()
+			client := configureRedisClient(cr, podName)
+			defer client.Close()
+			for _, nodeID := range nodeIDs {
+				// Nodes which already forgot the ID answer with an unknown node error
+				if err := client.ClusterForget(nodeID).Err(); err != nil && !strings.Contains(err.Error(), "Unknown node") {
+					errs <- fmt.Errorf("CLUSTER FORGET %s failed on %s: %v", nodeID, podName, err)
+					return
+				}
+			}
+		}(pod.PodName)
+	}
+	wg.Wait()
+	close(errs)
+	if err := <-errs; err != nil {
+		return err
+	}
+	if elapsed := time.Since(start); elapsed > clusterForgetWindow {
+		return fmt.Errorf("forgetting took %s, longer than the forget window of %s", elapsed, clusterForgetWindow)
+	}
+	return nil
+}
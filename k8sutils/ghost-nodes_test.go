@@ -0,0 +1,60 @@
+package k8sutils
+
+import (
+	"testing"
+)
+
+func TestFindGhostNodes(t *testing.T) {
+	nodes := clusterNodesFromOutput(`
+a 10.0.0.1:6379@16379 myself,master - 0 0 1 connected 0-8191
+b 10.0.0.2:6379@16379 master - 0 0 4 connected 8192-16383
+c :0@0 slave,fail,noaddr a 0 0 1 disconnected
+d 10.0.0.9:6379@16379 master,fail - 0 0 2 disconnected
+e 10.0.0.8:6379@16379 master,fail - 0 0 3 disconnected 100
+f 10.0.0.7:6379@16379 slave,fail b 0 0 4 disconnected`)
+	served := map[string]bool{"a": true, "b": true, "f": true}
+
+	ghosts := findGhostNodes(nodes, served)
+	if len(ghosts) != 2 || ghosts[0].ID != "c" || ghosts[1].ID != "d" {
+		t.Errorf("got ghosts %+v, want c and d", ghosts)
+	}
+}
+
+func TestGetGhostShardMaster(t *testing.T) {
+	// d was the master of shard b and failed over to b, c replicated a
+	topology := &ClusterTopology{Nodes: clusterNodesFromOutput(`
+a 10.0.0.1:6379@16379 myself,master - 0 0 1 connected 0-8191
+b 10.0.0.2:6379@16379 master - 0 0 4 connected 8192-16383
+c :0@0 slave,fail,noaddr a 0 0 1 disconnected
+e 10.0.0.8:6379@16379 slave,fail g 0 0 3 disconnected
+g 10.0.0.5:6379@16379 slave b 0 0 4 connected
+h 10.0.0.6:6379@16379 master,fail - 0 0 2 disconnected`)}
+
+	var tests = []struct {
+		ghost string
+		want  string
+	}{
+		{"c", "a"},
+		{"e", "b"},
+		{"h", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ghost, func(t *testing.T) {
+			if ans := getGhostShardMaster(topology, *topology.NodeByID(tt.ghost)); ans != tt.want {
+				t.Errorf("got %s, want %s", ans, tt.want)
+			}
+		})
+	}
+}
+
+func TestPairGhostNode(t *testing.T) {
+	ghosts := clusterNodesFromOutput(`
+c 10.0.0.3:6379@16379,redis-follower-0.redis-follower-headless.default.svc slave,fail a 0 0 1 disconnected
+d 10.0.0.4:6379@16379,redis-follower-1.redis-follower-headless.default.svc slave,fail b 0 0 2 disconnected`)
+	if index := pairGhostNode(ghosts, "redis-follower-1"); index != 1 {
+		t.Errorf("got %d, want the ghost announced with the pod hostname", index)
+	}
+	if index := pairGhostNode(ghosts, "redis-follower-10"); index != 0 {
+		t.Errorf("got %d, want the first ghost", index)
+	}
+}
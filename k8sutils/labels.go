@@ -29,8 +29,8 @@ func AddOwnerRefToObject(obj metav1.Object, ownerRef metav1.OwnerReference) {
 	obj.SetOwnerReferences(append(obj.GetOwnerReferences(), ownerRef))
 }
 
-// redisAsOwner generates and returns object refernece
-func redisAsOwner(cr *redisv1beta1.Redis) metav1.OwnerReference {
+// RedisAsOwner generates and returns object refernece
+func RedisAsOwner(cr *redisv1beta1.Redis) metav1.OwnerReference {
 	trueVar := true
 	return metav1.OwnerReference{
 		APIVersion: cr.APIVersion,
@@ -41,8 +41,8 @@ func redisAsOwner(cr *redisv1beta1.Redis) metav1.OwnerReference {
 	}
 }
 
-// redisClusterAsOwner generates and returns object refernece
-func redisClusterAsOwner(cr *redisv1beta1.RedisCluster) metav1.OwnerReference {
+// RedisClusterAsOwner generates and returns object refernece
+func RedisClusterAsOwner(cr *redisv1beta1.RedisCluster) metav1.OwnerReference {
 	trueVar := true
 	return metav1.OwnerReference{
 		APIVersion: cr.APIVersion,
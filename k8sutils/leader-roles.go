@@ -0,0 +1,95 @@
+package k8sutils
+
+import (
+	"fmt"
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// LeaderRolesCondition reports whether the masters of the cluster are served by the leader pods
+const LeaderRolesCondition = "LeaderRoles"
+
+// ReconcileRedisClusterRoles will find the masters served by follower pods and, once the cluster is healthy, fail one of them
+// over to a leader pod of its shard, it returns the pod promoted in this reconcile
+func ReconcileRedisClusterRoles(cr *redisv1beta1.RedisCluster, topology *ClusterTopology) (metav1.Condition, string, error) {
+	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
+	condition := metav1.Condition{Type: LeaderRolesCondition, Status: metav1.ConditionTrue, Reason: "LeadersAreMasters", Message: "Every master is served by a leader pod"}
+	if !topology.Formed() {
+		return condition, "", nil
+	}
+	drifted, promotions := findRoleDrift(topology, cr.ObjectMeta.Name+"-leader-")
+	if len(drifted) == 0 {
+		return condition, "", nil
+	}
+	condition.Status = metav1.ConditionFalse
+	condition.Reason = "RoleDrift"
+	condition.Message = fmt.Sprintf("Masters are served by follower pods: %s", strings.Join(drifted, ","))
+	if cr.Spec.RestoreLeaderRoles != nil && !*cr.Spec.RestoreLeaderRoles {
+		return condition, "", nil
+	}
+	for _, node := range topology.Nodes {
+		if node.IsFailed() || !node.IsConnected() {
+			logger.Info("Waiting for the cluster to be healthy before restoring the leader roles", "Node", node.ID)
+			return condition, "", nil
+		}
+	}
+
+	draining, _, err := getClusterPodDrainStates(cr)
+	if err != nil {
+		return condition, "", err
+	}
+	// One failover per reconcile keeps a single shard briefly unavailable for writes
+	for _, podName := range promotions {
+		if draining[podName] {
+			logger.Info("Leader pod is draining, the master stays on its follower pod", "Pod", podName)
+			continue
+		}
+		if !isReplicaCaughtUp(cr, podName) || !isReplicaInSync(cr, podName) {
+			logger.Info("Leader pod is not in sync with its master yet", "Pod", podName)
+			continue
+		}
+		client := configureRedisClient(cr, podName)
+		err := client.Do("cluster", "failover").Err()
+		client.Close()
+		if err != nil {
+			return condition, "", err
+		}
+		logger.Info("Failing the master over to its leader pod", "Pod", podName)
+		return condition, podName, nil
+	}
+	return condition, "", nil
+}
+
+// findRoleDrift returns the follower pods serving masters with slots and the leader pods replicating those masters,
+// the first leader pod by ordinal for each master
+func findRoleDrift(topology *ClusterTopology, leaderPrefix string) ([]string, []string) {
+	var drifted, promotions []string
+	for _, node := range topology.Nodes {
+		podName := topology.PodName(node)
+		if !node.IsMaster() || node.IsFailed() || len(node.Slots) == 0 || podName == "" || strings.HasPrefix(podName, leaderPrefix) {
+			continue
+		}
+		drifted = append(drifted, podName)
+		var leaders []string
+		for _, replica := range topology.Nodes {
+			replicaPod := topology.PodName(replica)
+			if replica.MasterID == node.ID && replica.IsReplica() && !replica.IsFailed() && strings.HasPrefix(replicaPod, leaderPrefix) {
+				leaders = append(leaders, replicaPod)
+			}
+		}
+		sort.Slice(leaders, func(i, j int) bool {
+			return podOrdinal(leaders[i]) < podOrdinal(leaders[j])
+		})
+		if len(leaders) > 0 {
+			promotions = append(promotions, leaders[0])
+		}
+	}
+	sort.Strings(drifted)
+	sort.Slice(promotions, func(i, j int) bool {
+		return podOrdinal(promotions[i]) < podOrdinal(promotions[j])
+	})
+	return drifted, promotions
+}
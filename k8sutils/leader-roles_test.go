@@ -0,0 +1,31 @@
+package k8sutils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindRoleDrift(t *testing.T) {
+	// Two shards failed over to follower pods, g is served by no pod
+	topology := &ClusterTopology{
+		Nodes: clusterNodesFromOutput(`
+a 10.0.0.1:6379@16379 slave d 0 0 4 connected
+b 10.0.0.2:6379@16379 master - 0 0 2 connected 5461-10922
+c 10.0.0.3:6379@16379 myself,master - 0 0 3 connected 10923-16383
+d 10.0.0.4:6379@16379 master - 0 0 4 connected 0-5460
+e 10.0.0.5:6379@16379 slave b 0 0 5 connected
+f 10.0.0.6:6379@16379 slave c 0 0 3 connected
+g 10.0.0.7:6379@16379 master - 0 0 6 connected 16383`),
+		PodsByIP: map[string]string{
+			"10.0.0.1": "redis-leader-0", "10.0.0.2": "redis-follower-1", "10.0.0.3": "redis-leader-2",
+			"10.0.0.4": "redis-follower-0", "10.0.0.5": "redis-leader-1", "10.0.0.6": "redis-follower-2",
+		},
+	}
+	drifted, promotions := findRoleDrift(topology, "redis-leader-")
+	if want := []string{"redis-follower-0", "redis-follower-1"}; !reflect.DeepEqual(drifted, want) {
+		t.Errorf("got drifted %v, want %v", drifted, want)
+	}
+	if want := []string{"redis-leader-0", "redis-leader-1"}; !reflect.DeepEqual(promotions, want) {
+		t.Errorf("got promotions %v, want %v", promotions, want)
+	}
+}
@@ -0,0 +1,362 @@
+package k8sutils
+
+import (
+	"context"
+	"fmt"
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ReconcileNodeLossRecovery will replace the volume of pods stuck on a lost node and rejoin them as replicas of their
+// shard, it returns the pods which are still recovering
+func ReconcileNodeLossRecovery(cr *redisv1beta1.RedisCluster, topology *ClusterTopology) ([]redisv1beta1.RecoveringPod, error) {
+	logger := nodeRecoveryLogger(cr.Namespace, cr.ObjectMeta.Name)
+	var recovering []redisv1beta1.RecoveringPod
+	for _, pod := range cr.Status.RecoveringPods {
+		done, err := rejoinRecoveredPod(cr, pod)
+		if err != nil {
+			logger.Error(err, "Could not rejoin recovered pod", "Pod", pod.Pod)
+		}
+		if !done {
+			recovering = append(recovering, pod)
+		}
+	}
+
+	if cr.Spec.NodeLossRecovery == nil || !cr.Spec.NodeLossRecovery.Enabled {
+		return recovering, nil
+	}
+	gracePeriod := time.Duration(cr.Spec.NodeLossRecovery.GracePeriodSeconds) * time.Second
+	for _, role := range []string{"leader", "follower"} {
+		for podCount := 0; podCount < int(cr.Spec.GetReplicaCounts(role)); podCount++ {
+			podName := cr.ObjectMeta.Name + "-" + role + "-" + strconv.Itoa(podCount)
+			if findRecoveringPod(recovering, podName) != nil {
+				continue
+			}
+			claimName, lost, err := checkPodOnLostNode(cr.Namespace, podName, gracePeriod)
+			if err != nil {
+				return recovering, err
+			}
+			if !lost {
+				continue
+			}
+			// The node left behind is looked up while the claim still exists, the lost node is gone with its data
+			nodeID, masterID := findLostClusterNode(topology, podName, cr.Status.ReplicationLag)
+			logger.Info("Pod is pinned to a lost node by its volume, deleting its claim", "Pod", podName, "PVC", claimName, "Node", nodeID, "Master", masterID)
+			if err := deletePVC(cr.Namespace, claimName); err != nil {
+				return recovering, err
+			}
+			// The claim is protected while the pod exists, the statefulset recreates both
+			err = generateK8sClient().CoreV1().Pods(cr.Namespace).Delete(context.TODO(), podName, metav1.DeleteOptions{})
+			if err != nil && !errors.IsNotFound(err) {
+				logger.Error(err, "Could not delete pod of lost node", "Pod", podName)
+				return recovering, err
+			}
+			recovering = append(recovering, redisv1beta1.RecoveringPod{Pod: podName, NodeID: nodeID, MasterID: masterID})
+		}
+	}
+	return recovering, nil
+}
+
+// findLostClusterNode returns the failed node a pod left behind and the master of its shard, the node is told apart by
+// its announced hostname or as the only failed node of the shard the pod was last reported in, failed nodes still
+// owning slots are never returned
+func findLostClusterNode(topology *ClusterTopology, podName string, shards []redisv1beta1.ShardReplicationLag) (string, string) {
+	masterID := getRecordedShardMaster(topology, podName, shards)
+	var candidates []ClusterNode
+	for _, node := range topology.Nodes {
+		if !node.IsFailed() || len(node.Slots) > 0 || topology.PodName(node) != "" {
+			continue
+		}
+		if strings.HasPrefix(node.Hostname, podName+".") {
+			candidates = []ClusterNode{node}
+			break
+		}
+		// A failed over master is turned into a replica of the new master by the other nodes
+		if masterID != "" && node.MasterID == masterID {
+			candidates = append(candidates, node)
+		}
+	}
+	if len(candidates) != 1 {
+		return "", masterID
+	}
+	if masterID == "" {
+		masterID = candidates[0].MasterID
+	}
+	return candidates[0].ID, masterID
+}
+
+// getRecordedShardMaster returns the current master of the shard the pod was last reported in by the replication lag
+func getRecordedShardMaster(topology *ClusterTopology, podName string, shards []redisv1beta1.ShardReplicationLag) string {
+	for _, shard := range shards {
+		members := []string{shard.Master}
+		for _, replica := range shard.Replicas {
+			members = append(members, replica.Pod)
+		}
+		if !ContainsString(members, podName) {
+			continue
+		}
+		for _, member := range members {
+			node := findClusterNodeByIP(topology.Nodes, topology.PodIP(member))
+			if member == podName || node == nil || node.IsFailed() {
+				continue
+			}
+			if node.IsMaster() && len(node.Slots) > 0 {
+				return node.ID
+			}
+			if node.IsReplica() {
+				return node.MasterID
+			}
+		}
+	}
+	return ""
+}
+
+// findRecoveringPod returns the recovery entry of a pod
+func findRecoveringPod(pods []redisv1beta1.RecoveringPod, podName string) *redisv1beta1.RecoveringPod {
+	for i := range pods {
+		if pods[i].Pod == podName {
+			return &pods[i]
+		}
+	}
+	return nil
+}
+
+// checkPodOnLostNode returns the claim of a pod which stays unschedulable because its volume is bound to a lost node
+func checkPodOnLostNode(namespace, podName string, gracePeriod time.Duration) (string, bool, error) {
+	client := generateK8sClient()
+	pod, err := client.CoreV1().Pods(namespace).Get(context.TODO(), podName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	if pod.Status.Phase != corev1.PodPending || !isUnschedulableSince(pod, gracePeriod) {
+		return "", false, nil
+	}
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim == nil {
+			continue
+		}
+		claimName := volume.PersistentVolumeClaim.ClaimName
+		pvc, err := client.CoreV1().PersistentVolumeClaims(namespace).Get(context.TODO(), claimName, metav1.GetOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return "", false, err
+		}
+		if pvc.Spec.VolumeName == "" {
+			continue
+		}
+		pv, err := client.CoreV1().PersistentVolumes().Get(context.TODO(), pvc.Spec.VolumeName, metav1.GetOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return "", false, err
+		}
+		for _, nodeName := range getVolumeNodeNames(pv) {
+			lost, err := isNodeLost(nodeName)
+			if err != nil {
+				return "", false, err
+			}
+			if lost {
+				return claimName, true, nil
+			}
+		}
+	}
+	return "", false, nil
+}
+
+// isUnschedulableSince checks if the scheduler reported the pod unschedulable for longer than the grace period
+func isUnschedulableSince(pod *corev1.Pod, gracePeriod time.Duration) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse && condition.Reason == corev1.PodReasonUnschedulable {
+			return time.Since(condition.LastTransitionTime.Time) > gracePeriod
+		}
+	}
+	return false
+}
+
+// getVolumeNodeNames returns the hostnames a local PersistentVolume is pinned to
+func getVolumeNodeNames(pv *corev1.PersistentVolume) []string {
+	var nodeNames []string
+	if pv.Spec.NodeAffinity == nil || pv.Spec.NodeAffinity.Required == nil {
+		return nodeNames
+	}
+	for _, term := range pv.Spec.NodeAffinity.Required.NodeSelectorTerms {
+		for _, expression := range term.MatchExpressions {
+			if expression.Key == corev1.LabelHostname && expression.Operator == corev1.NodeSelectorOpIn {
+				nodeNames = append(nodeNames, expression.Values...)
+			}
+		}
+	}
+	return nodeNames
+}
+
+// isNodeLost checks if a node was removed from the cluster or is not ready
+func isNodeLost(nodeName string) (bool, error) {
+	node, err := generateK8sClient().CoreV1().Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status != corev1.ConditionTrue, nil
+		}
+	}
+	return true, nil
+}
+
+// rejoinRecoveredPod will forget the node the pod left behind and attach the rescheduled pod as replica of its shard
+func rejoinRecoveredPod(cr *redisv1beta1.RedisCluster, recovering redisv1beta1.RecoveringPod) (bool, error) {
+	logger := nodeRecoveryLogger(cr.Namespace, cr.ObjectMeta.Name)
+	podName := recovering.Pod
+	pod, err := generateK8sClient().CoreV1().Pods(cr.Namespace).Get(context.TODO(), podName, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if !isPodReady(pod) {
+		logger.Info("Waiting for the recovered pod to become ready", "Pod", podName)
+		return false, nil
+	}
+
+	client := configureRedisClient(cr, podName)
+	defer client.Close()
+	nodes, err := getClusterNodes(client)
+	if err != nil {
+		return false, err
+	}
+	myself := findClusterNode(nodes, "myself")
+	if myself == nil {
+		return false, fmt.Errorf("pod %s does not report itself in cluster nodes", podName)
+	}
+	if myself.IsReplica() {
+		logger.Info("Recovered pod rejoined the cluster as replica", "Pod", podName)
+		return true, nil
+	}
+
+	if len(nodes) == 1 {
+		seed, err := getHealthyClusterPod(cr, podName)
+		if err != nil {
+			return false, err
+		}
+		if err := client.ClusterMeet(getRedisServerIP(RedisDetails{PodName: seed, Namespace: cr.Namespace}), "6379").Err(); err != nil {
+			return false, err
+		}
+		logger.Info("Recovered pod met the cluster, waiting for the topology to propagate", "Pod", podName, "Seed", seed)
+		return false, nil
+	}
+
+	if recovering.NodeID != "" {
+		if err := forgetClusterNodes(cr, getReadyClusterPods(cr), []string{recovering.NodeID}); err != nil {
+			return false, err
+		}
+		logger.Info("Lost node forgotten by the cluster", "Node", recovering.NodeID)
+	}
+	master := getRecoveredShardMaster(nodes, recovering.MasterID)
+	if master == "" {
+		logger.Info("The shard of the recovered pod is unknown, attaching it to the master with fewest replicas", "Pod", podName)
+		master = getMasterWithFewestReplicas(nodes)
+	}
+	if master == "" {
+		return false, fmt.Errorf("no master with slots found to replicate")
+	}
+	if err := client.ClusterReplicate(master).Err(); err != nil {
+		return false, err
+	}
+	logger.Info("Recovered pod attached as replica", "Pod", podName, "Master", master)
+	return false, nil
+}
+
+// getRecoveredShardMaster returns the healthy master of the shard, following a failover of the recorded master
+func getRecoveredShardMaster(nodes []ClusterNode, masterID string) string {
+	node := findClusterNodeByID(nodes, masterID)
+	if node != nil && node.IsReplica() {
+		node = findClusterNodeByID(nodes, node.MasterID)
+	}
+	if node == nil || !node.IsMaster() || node.IsFailed() || len(node.Slots) == 0 {
+		return ""
+	}
+	return node.ID
+}
+
+// getHealthyClusterPod returns a ready pod of the cluster other than the given one
+func getHealthyClusterPod(cr *redisv1beta1.RedisCluster, exclude string) (string, error) {
+	for _, role := range []string{"leader", "follower"} {
+		for podCount := 0; podCount < int(cr.Spec.GetReplicaCounts(role)); podCount++ {
+			podName := cr.ObjectMeta.Name + "-" + role + "-" + strconv.Itoa(podCount)
+			if podName == exclude {
+				continue
+			}
+			pod, err := generateK8sClient().CoreV1().Pods(cr.Namespace).Get(context.TODO(), podName, metav1.GetOptions{})
+			if err == nil && isPodReady(pod) {
+				return podName, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no ready pod found in cluster %s", cr.ObjectMeta.Name)
+}
+
+// getMasterWithFewestReplicas returns the ID of the healthy master owning slots with the fewest healthy replicas
+func getMasterWithFewestReplicas(nodes []ClusterNode) string {
+	replicas := map[string]int{}
+	for _, node := range nodes {
+		if node.IsReplica() && !node.IsFailed() {
+			replicas[node.MasterID]++
+		}
+	}
+	master := ""
+	for _, node := range nodes {
+		if !node.IsMaster() || node.IsFailed() || len(node.Slots) == 0 {
+			continue
+		}
+		if master == "" || replicas[node.ID] < replicas[master] {
+			master = node.ID
+		}
+	}
+	return master
+}
+
+// isPodReady checks the Ready condition of a pod
+func isPodReady(pod *corev1.Pod) bool {
+	if pod.Status.Phase != corev1.PodRunning {
+		return false
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// ContainsString checks if a slice contains the string
+func ContainsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeRecoveryLogger will generate logging interface for node loss recovery
+func nodeRecoveryLogger(namespace string, name string) logr.Logger {
+	reqLogger := log.WithValues("Request.NodeRecovery.Namespace", namespace, "Request.NodeRecovery.Name", name)
+	return reqLogger
+}
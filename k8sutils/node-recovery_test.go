@@ -0,0 +1,108 @@
+package k8sutils
+
+import (
+	"testing"
+
+	redisv1beta1 "redis-operator/api/v1beta1"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestGetMasterWithFewestReplicas(t *testing.T) {
+	output := "205dd1780dda981f9320c9d47d069b3c0ceaa358 172.17.0.24:6379@16379 slave b65312dcf5537b8826c344783f078096fdb7f27c 0 1654197347000 1 connected\n" +
+		"faa21623054227826e93dd71314cce3706491dac :0@0 slave,fail,noaddr d54557b21bc5a5aa947ce58b7dbadc5d39bdd551 1654197340000 1654197339000 2 disconnected\n" +
+		"b65312dcf5537b8826c344783f078096fdb7f27c 172.17.0.25:6379@16379 master - 0 1654197346000 1 connected 0-5460\n" +
+		"d54557b21bc5a5aa947ce58b7dbadc5d39bdd551 172.17.0.29:6379@16379 myself,master - 0 1654197347000 2 connected 5461-10922\n" +
+		"c9fa05269c4e662295bf34eb93f1315f962493ba 172.17.0.3:6379@16379 master - 0 1654197348006 3 connected 10923-16383\n" +
+		"e1c4b3e1f2b9b1d0c5f0a3e7d5c9b2a1f0e9d8c7 172.17.0.30:6379@16379 slave c9fa05269c4e662295bf34eb93f1315f962493ba 0 1654197348006 3 connected"
+	nodes := clusterNodesFromOutput(output)
+
+	if master := getMasterWithFewestReplicas(nodes); master != "d54557b21bc5a5aa947ce58b7dbadc5d39bdd551" {
+		t.Errorf("got %s, want the master which lost its replica", master)
+	}
+	if !nodes[1].IsFailed() || nodes[0].IsFailed() {
+		t.Errorf("failed node detection is wrong")
+	}
+	if myself := findClusterNode(nodes, "myself"); myself == nil || myself.ID != "d54557b21bc5a5aa947ce58b7dbadc5d39bdd551" {
+		t.Errorf("myself not found")
+	}
+}
+
+func TestGetVolumeNodeNames(t *testing.T) {
+	pv := &corev1.PersistentVolume{
+		Spec: corev1.PersistentVolumeSpec{
+			NodeAffinity: &corev1.VolumeNodeAffinity{
+				Required: &corev1.NodeSelector{
+					NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+						MatchExpressions: []corev1.NodeSelectorRequirement{{
+							Key:      corev1.LabelHostname,
+							Operator: corev1.NodeSelectorOpIn,
+							Values:   []string{"worker-1"},
+						}},
+					}},
+				},
+			},
+		},
+	}
+	names := getVolumeNodeNames(pv)
+	if len(names) != 1 || names[0] != "worker-1" {
+		t.Errorf("got %v, want [worker-1]", names)
+	}
+	if names := getVolumeNodeNames(&corev1.PersistentVolume{}); len(names) != 0 {
+		t.Errorf("got %v for a volume without node affinity", names)
+	}
+}
+
+func TestFindLostClusterNode(t *testing.T) {
+	output := "aaaa000000000000000000000000000000000001 10.0.0.1:6379@16379 myself,master - 0 1 1 connected 0-8191\n" +
+		"aaaa000000000000000000000000000000000002 10.0.0.2:6379@16379 master - 0 1 2 connected 8192-16383\n" +
+		"aaaa000000000000000000000000000000000003 10.0.0.3:6379@16379 slave aaaa000000000000000000000000000000000002 0 1 2 connected\n" +
+		"aaaa000000000000000000000000000000000004 :0@0 slave,fail,noaddr aaaa000000000000000000000000000000000001 1 1 1 disconnected\n" +
+		"aaaa000000000000000000000000000000000005 :0@0 master,fail,noaddr - 1 1 3 disconnected 100\n"
+	topology := &ClusterTopology{
+		Nodes: clusterNodesFromOutput(output),
+		PodsByIP: map[string]string{
+			"10.0.0.1": "redis-leader-0",
+			"10.0.0.2": "redis-leader-1",
+			"10.0.0.3": "redis-follower-1",
+		},
+	}
+	shards := []redisv1beta1.ShardReplicationLag{
+		{Master: "redis-leader-0", Replicas: []redisv1beta1.ReplicaLag{{Pod: "redis-follower-0"}}},
+		{Master: "redis-leader-1", Replicas: []redisv1beta1.ReplicaLag{{Pod: "redis-follower-1"}}},
+	}
+
+	tests := []struct {
+		name       string
+		pod        string
+		shards     []redisv1beta1.ShardReplicationLag
+		wantNode   string
+		wantMaster string
+	}{
+		{"failed replica of the recorded shard", "redis-follower-0", shards, "aaaa000000000000000000000000000000000004", "aaaa000000000000000000000000000000000001"},
+		{"shard without live members", "redis-leader-0", []redisv1beta1.ShardReplicationLag{{Master: "redis-follower-0", Replicas: []redisv1beta1.ReplicaLag{{Pod: "redis-leader-0"}}}}, "", ""},
+		{"shard without failed node", "redis-follower-1", shards, "", "aaaa000000000000000000000000000000000002"},
+		{"unknown shard", "redis-follower-2", shards, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, master := findLostClusterNode(topology, tt.pod, tt.shards)
+			if node != tt.wantNode || master != tt.wantMaster {
+				t.Errorf("got %q %q, want %q %q", node, master, tt.wantNode, tt.wantMaster)
+			}
+		})
+	}
+}
+
+func TestGetRecoveredShardMaster(t *testing.T) {
+	output := "aaaa000000000000000000000000000000000001 10.0.0.1:6379@16379 myself,slave aaaa000000000000000000000000000000000002 0 1 2 connected\n" +
+		"aaaa000000000000000000000000000000000002 10.0.0.2:6379@16379 master - 0 1 2 connected 0-16383\n"
+	nodes := clusterNodesFromOutput(output)
+
+	if master := getRecoveredShardMaster(nodes, "aaaa000000000000000000000000000000000001"); master != "aaaa000000000000000000000000000000000002" {
+		t.Errorf("got %q, want the master promoted by the failover", master)
+	}
+	if master := getRecoveredShardMaster(nodes, "aaaa000000000000000000000000000000000009"); master != "" {
+		t.Errorf("got %q, want no master for an unknown node", master)
+	}
+}
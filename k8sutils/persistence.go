@@ -0,0 +1,176 @@
+package k8sutils
+
+import (
+	"fmt"
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/go-redis/redis"
+)
+
+// defaultRDBSavePoints are the save points of the stock redis.conf
+var defaultRDBSavePoints = []redisv1beta1.RDBSavePoint{
+	{Seconds: 900, Changes: 1},
+	{Seconds: 300, Changes: 10},
+	{Seconds: 60, Changes: 10000},
+}
+
+// redisConfigParam is a single redis config directive
+type redisConfigParam struct {
+	Name  string
+	Value string
+}
+
+// ValidatePersistence will check the persistence configuration against the storage
+func ValidatePersistence(persistence *redisv1beta1.Persistence, storage *redisv1beta1.Storage) error {
+	if persistence == nil {
+		return nil
+	}
+	rdbEnabled := persistence.RDB != nil && persistence.RDB.Enabled
+	aofEnabled := persistence.AOF != nil && persistence.AOF.Enabled
+	if persistence.CacheOnly && (rdbEnabled || aofEnabled) {
+		return fmt.Errorf("persistence.cacheOnly cannot be combined with RDB or AOF")
+	}
+	if (rdbEnabled || aofEnabled) && (storage == nil || storage.IsEphemeral()) {
+		return fmt.Errorf("RDB and AOF persistence require spec.storage.volumeClaimTemplate to be set")
+	}
+	return nil
+}
+
+// effectivePersistence will disable RDB and AOF when the data directory is ephemeral
+func effectivePersistence(persistence *redisv1beta1.Persistence, storage *redisv1beta1.Storage) *redisv1beta1.Persistence {
+	if storage.IsEphemeral() {
+		return &redisv1beta1.Persistence{CacheOnly: true}
+	}
+	return persistence
+}
+
+// generatePersistenceConfig will render the persistence block into redis config directives
+func generatePersistenceConfig(persistence *redisv1beta1.Persistence) []redisConfigParam {
+	var params []redisConfigParam
+	if persistence == nil {
+		return params
+	}
+	if persistence.CacheOnly {
+		return []redisConfigParam{
+			{Name: "save", Value: ""},
+			{Name: "appendonly", Value: "no"},
+		}
+	}
+	if persistence.RDB != nil {
+		var savePoints []string
+		if persistence.RDB.Enabled {
+			points := persistence.RDB.SavePoints
+			if len(points) == 0 {
+				points = defaultRDBSavePoints
+			}
+			for _, point := range points {
+				savePoints = append(savePoints, fmt.Sprintf("%d %d", point.Seconds, point.Changes))
+			}
+		}
+		params = append(params, redisConfigParam{Name: "save", Value: strings.Join(savePoints, " ")})
+	}
+	if persistence.AOF != nil {
+		if !persistence.AOF.Enabled {
+			return append(params, redisConfigParam{Name: "appendonly", Value: "no"})
+		}
+		params = append(params, redisConfigParam{Name: "appendonly", Value: "yes"})
+		appendFsync := persistence.AOF.AppendFsync
+		if appendFsync == "" {
+			appendFsync = "everysec"
+		}
+		params = append(params, redisConfigParam{Name: "appendfsync", Value: appendFsync})
+		if persistence.AOF.AutoRewritePercentage != nil {
+			params = append(params, redisConfigParam{Name: "auto-aof-rewrite-percentage", Value: strconv.Itoa(int(*persistence.AOF.AutoRewritePercentage))})
+		}
+		if persistence.AOF.AutoRewriteMinSize != "" {
+			params = append(params, redisConfigParam{Name: "auto-aof-rewrite-min-size", Value: persistence.AOF.AutoRewriteMinSize})
+		}
+	}
+	return params
+}
+
+// ReconcileRedisPersistence will apply the persistence configuration on standalone Redis
+func ReconcileRedisPersistence(cr *redisv1beta1.Redis) (*redisv1beta1.PersistenceStatus, error) {
+	logger := persistenceLogger(cr.Namespace, cr.ObjectMeta.Name)
+	client := configureRedisStandaloneClient(cr)
+	defer client.Close()
+	if err := applyRedisConfig(client, generatePersistenceConfig(effectivePersistence(cr.Spec.Persistence, cr.Spec.Storage))); err != nil {
+		logger.Error(err, "Failed to apply persistence configuration")
+		return nil, err
+	}
+	return getPersistenceStatus(client)
+}
+
+// ReconcileRedisClusterPersistence will apply the persistence configuration on every leader and follower
+func ReconcileRedisClusterPersistence(cr *redisv1beta1.RedisCluster) (*redisv1beta1.PersistenceStatus, error) {
+	logger := persistenceLogger(cr.Namespace, cr.ObjectMeta.Name)
+	params := generatePersistenceConfig(effectivePersistence(cr.Spec.Persistence, cr.Spec.Storage))
+	status := &redisv1beta1.PersistenceStatus{}
+	for _, role := range []string{"leader", "follower"} {
+		for podCount := 0; podCount < int(cr.Spec.GetReplicaCounts(role)); podCount++ {
+			podName := cr.ObjectMeta.Name + "-" + role + "-" + strconv.Itoa(podCount)
+			client := configureRedisClient(cr, podName)
+			err := applyRedisConfig(client, params)
+			if err != nil {
+				client.Close()
+				logger.Error(err, "Failed to apply persistence configuration", "Pod", podName)
+				return nil, err
+			}
+			podStatus, err := getPersistenceStatus(client)
+			client.Close()
+			if err != nil {
+				return nil, err
+			}
+			mergePersistenceStatus(status, podStatus)
+		}
+	}
+	return status, nil
+}
+
+// applyRedisConfig will CONFIG SET every directive which differs from the running value
+func applyRedisConfig(client *redis.Client, params []redisConfigParam) error {
+	for _, param := range params {
+		current, err := client.ConfigGet(param.Name).Result()
+		if err != nil {
+			return err
+		}
+		if len(current) == 2 && fmt.Sprint(current[1]) == param.Value {
+			continue
+		}
+		if err := client.ConfigSet(param.Name, param.Value).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getPersistenceStatus will read the persistence status from INFO persistence
+func getPersistenceStatus(client *redis.Client) (*redisv1beta1.PersistenceStatus, error) {
+	info, err := getRedisInfo(client, "persistence")
+	if err != nil {
+		return nil, err
+	}
+	return &redisv1beta1.PersistenceStatus{
+		RDBLastBgsaveStatus: info["rdb_last_bgsave_status"],
+		AOFLastWriteStatus:  info["aof_last_write_status"],
+	}, nil
+}
+
+// mergePersistenceStatus keeps the first failing status of all nodes
+func mergePersistenceStatus(status *redisv1beta1.PersistenceStatus, podStatus *redisv1beta1.PersistenceStatus) {
+	if status.RDBLastBgsaveStatus == "" || status.RDBLastBgsaveStatus == "ok" {
+		status.RDBLastBgsaveStatus = podStatus.RDBLastBgsaveStatus
+	}
+	if status.AOFLastWriteStatus == "" || status.AOFLastWriteStatus == "ok" {
+		status.AOFLastWriteStatus = podStatus.AOFLastWriteStatus
+	}
+}
+
+// persistenceLogger will generate logging interface for persistence
+func persistenceLogger(namespace string, name string) logr.Logger {
+	reqLogger := log.WithValues("Request.Persistence.Namespace", namespace, "Request.Persistence.Name", name)
+	return reqLogger
+}
@@ -0,0 +1,60 @@
+package k8sutils
+
+import (
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"reflect"
+	"testing"
+)
+
+func TestGeneratePersistenceConfig(t *testing.T) {
+	percentage := int32(50)
+	var tests = []struct {
+		name        string
+		persistence *redisv1beta1.Persistence
+		want        []redisConfigParam
+	}{
+		{"unset", nil, nil},
+		{"cache only", &redisv1beta1.Persistence{CacheOnly: true}, []redisConfigParam{{"save", ""}, {"appendonly", "no"}}},
+		{"rdb defaults", &redisv1beta1.Persistence{RDB: &redisv1beta1.RDBPersistence{Enabled: true}}, []redisConfigParam{{"save", "900 1 300 10 60 10000"}}},
+		{"rdb disabled", &redisv1beta1.Persistence{RDB: &redisv1beta1.RDBPersistence{}}, []redisConfigParam{{"save", ""}}},
+		{"rdb and aof", &redisv1beta1.Persistence{
+			RDB: &redisv1beta1.RDBPersistence{Enabled: true, SavePoints: []redisv1beta1.RDBSavePoint{{Seconds: 60, Changes: 100}}},
+			AOF: &redisv1beta1.AOFPersistence{Enabled: true, AppendFsync: "always", AutoRewritePercentage: &percentage, AutoRewriteMinSize: "64mb"},
+		}, []redisConfigParam{{"save", "60 100"}, {"appendonly", "yes"}, {"appendfsync", "always"}, {"auto-aof-rewrite-percentage", "50"}, {"auto-aof-rewrite-min-size", "64mb"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ans := generatePersistenceConfig(tt.persistence)
+			if !reflect.DeepEqual(ans, tt.want) {
+				t.Errorf("got %v, want %v", ans, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePersistence(t *testing.T) {
+	storage := &redisv1beta1.Storage{}
+	var tests = []struct {
+		name        string
+		persistence *redisv1beta1.Persistence
+		storage     *redisv1beta1.Storage
+		wantErr     bool
+	}{
+		{"unset", nil, nil, false},
+		{"cache only without storage", &redisv1beta1.Persistence{CacheOnly: true}, nil, false},
+		{"aof without storage", &redisv1beta1.Persistence{AOF: &redisv1beta1.AOFPersistence{Enabled: true}}, nil, true},
+		{"rdb with storage", &redisv1beta1.Persistence{RDB: &redisv1beta1.RDBPersistence{Enabled: true}}, storage, false},
+		{"rdb with ephemeral storage", &redisv1beta1.Persistence{RDB: &redisv1beta1.RDBPersistence{Enabled: true}}, &redisv1beta1.Storage{Ephemeral: &redisv1beta1.EphemeralStorage{}}, true},
+		{"cache only with rdb", &redisv1beta1.Persistence{CacheOnly: true, RDB: &redisv1beta1.RDBPersistence{Enabled: true}}, storage, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePersistence(tt.persistence, tt.storage)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
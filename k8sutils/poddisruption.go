@@ -22,7 +22,7 @@ func ReconcileRedisPodDisruptionBudget(cr *redisv1beta1.RedisCluster, role strin
 		labels := getRedisLabels(cr.ObjectMeta.Name, "cluster", role, cr.ObjectMeta.GetLabels())
 		annotations := generateStatefulSetsAnots(cr.ObjectMeta)
 		pdbMeta := generateObjectMetaInformation(pdbName, cr.Namespace, labels, annotations)
-		pdbDef := generatePodDisruptionBudgetDef(cr, role, pdbMeta, cr.Spec.RedisLeader.PodDisruptionBudget)
+		pdbDef := generatePodDisruptionBudgetDef(cr, role, pdbMeta, pdbParams)
 		return CreateOrUpdatePodDisruptionBudget(pdbDef)
 	} else {
 		// Check if one exists, and delete it.
@@ -38,12 +38,48 @@ func ReconcileRedisPodDisruptionBudget(cr *redisv1beta1.RedisCluster, role strin
 	}
 }
 
+// ReconcileRedisMasterPodDisruptionBudget will keep the masters of the cluster from eviction until they failed over to
+// a replica, the budget exists while the budget of a role protects the masters
+func ReconcileRedisMasterPodDisruptionBudget(cr *redisv1beta1.RedisCluster) error {
+	pdbName := cr.ObjectMeta.Name + "-master"
+	if isMasterPodDisruptionBudgetEnabled(cr) {
+		labels := getRedisLabels(cr.ObjectMeta.Name, "cluster", "master", cr.ObjectMeta.GetLabels())
+		annotations := generateStatefulSetsAnots(cr.ObjectMeta)
+		pdbMeta := generateObjectMetaInformation(pdbName, cr.Namespace, labels, annotations)
+		return CreateOrUpdatePodDisruptionBudget(generateMasterPodDisruptionBudgetDef(cr, pdbMeta))
+	}
+	_, err := GetPodDisruptionBudget(cr.Namespace, pdbName)
+	if err == nil {
+		return deletePodDisruptionBudget(cr.Namespace, pdbName)
+	} else if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// isMasterPodDisruptionBudgetEnabled checks if an enabled budget of a role protects the masters
+func isMasterPodDisruptionBudgetEnabled(cr *redisv1beta1.RedisCluster) bool {
+	for _, pdbParams := range []*redisv1beta1.RedisPodDisruptionBudget{cr.Spec.RedisLeader.PodDisruptionBudget, cr.Spec.RedisFollower.PodDisruptionBudget} {
+		if pdbParams != nil && pdbParams.Enabled && pdbParams.ProtectMasters {
+			return true
+		}
+	}
+	return false
+}
+
 // generatePodDisruptionBudgetDef will create a PodDisruptionBudget definition
 func generatePodDisruptionBudgetDef(cr *redisv1beta1.RedisCluster, role string, pdbMeta metav1.ObjectMeta, pdbParams *redisv1beta1.RedisPodDisruptionBudget) *policyv1.PodDisruptionBudget {
 	lblSelector := LabelSelectors(map[string]string{
 		"app":  fmt.Sprintf("%s-%s", cr.ObjectMeta.Name, role),
 		"role": role,
 	})
+	masterBudget := isMasterPodDisruptionBudgetEnabled(cr)
+	if masterBudget {
+		// Masters are left to the budget of the masters, the eviction API refuses pods selected by two budgets
+		lblSelector.MatchExpressions = []metav1.LabelSelectorRequirement{
+			{Key: RedisRoleLabel, Operator: metav1.LabelSelectorOpNotIn, Values: []string{"master"}},
+		}
+	}
 	pdbTemplate := &policyv1.PodDisruptionBudget{
 		TypeMeta:   generateMetaInformation("PodDisruptionBudget", "policy/v1"),
 		ObjectMeta: pdbMeta,
@@ -57,11 +93,38 @@ func generatePodDisruptionBudgetDef(cr *redisv1beta1.RedisCluster, role string,
 	if pdbParams.MaxUnavailable != nil {
 		pdbTemplate.Spec.MaxUnavailable = &intstr.IntOrString{Type: intstr.Int, IntVal: int32(*pdbParams.MaxUnavailable)}
 	}
-	// If we don't have a value for either, assume quorum: (N/2)+1
+	// If we don't have a value for either, assume quorum: (N/2)+1, once the masters are selected by the budget of the
+	// masters a quorum of the role would never be met, one replica is evicted at a time instead
 	if pdbTemplate.Spec.MaxUnavailable == nil && pdbTemplate.Spec.MinAvailable == nil {
-		pdbTemplate.Spec.MinAvailable = &intstr.IntOrString{Type: intstr.Int, IntVal: int32((*cr.Spec.Size / 2) + 1)}
+		if masterBudget {
+			pdbTemplate.Spec.MaxUnavailable = &intstr.IntOrString{Type: intstr.Int, IntVal: 1}
+		} else {
+			pdbTemplate.Spec.MinAvailable = &intstr.IntOrString{Type: intstr.Int, IntVal: int32((*cr.Spec.Size / 2) + 1)}
+		}
+	}
+	AddOwnerRefToObject(pdbTemplate, RedisClusterAsOwner(cr))
+	return pdbTemplate
+}
+
+// generateMasterPodDisruptionBudgetDef will create the PodDisruptionBudget definition of the cluster masters, the
+// drain failover turns a master into a replica before its eviction is allowed
+func generateMasterPodDisruptionBudgetDef(cr *redisv1beta1.RedisCluster, pdbMeta metav1.ObjectMeta) *policyv1.PodDisruptionBudget {
+	lblSelector := LabelSelectors(map[string]string{
+		"redis_setup_type": "cluster",
+		RedisRoleLabel:     "master",
+	})
+	lblSelector.MatchExpressions = []metav1.LabelSelectorRequirement{
+		{Key: "app", Operator: metav1.LabelSelectorOpIn, Values: []string{cr.ObjectMeta.Name + "-leader", cr.ObjectMeta.Name + "-follower"}},
+	}
+	pdbTemplate := &policyv1.PodDisruptionBudget{
+		TypeMeta:   generateMetaInformation("PodDisruptionBudget", "policy/v1"),
+		ObjectMeta: pdbMeta,
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			Selector:       lblSelector,
+			MaxUnavailable: &intstr.IntOrString{Type: intstr.Int, IntVal: 0},
+		},
 	}
-	AddOwnerRefToObject(pdbTemplate, redisClusterAsOwner(cr))
+	AddOwnerRefToObject(pdbTemplate, RedisClusterAsOwner(cr))
 	return pdbTemplate
 }
 
@@ -95,10 +158,12 @@ func patchPodDisruptionBudget(storedPdb *policyv1.PodDisruptionBudget, newPdb *p
 	storedPdb.Kind = "PodDisruptionBudget"
 	storedPdb.APIVersion = "policy/v1"
 
-	patchResult, err := patch.DefaultPatchMaker.Calculate(storedPdb, newPdb,
-		patch.IgnorePDBSelector(),
-		patch.IgnoreStatusFields(),
-	)
+	opts := []patch.CalculateOption{patch.IgnoreStatusFields()}
+	// The selector is only compared when the masters are split off the budget of a role or joined back
+	if !hasRoleSelector(storedPdb) && !hasRoleSelector(newPdb) {
+		opts = append(opts, patch.IgnorePDBSelector())
+	}
+	patchResult, err := patch.DefaultPatchMaker.Calculate(storedPdb, newPdb, opts...)
 	if err != nil {
 		logger.Error(err, "Unable to patch redis PodDisruption with comparison object")
 		return err
@@ -124,6 +189,22 @@ func patchPodDisruptionBudget(storedPdb *policyv1.PodDisruptionBudget, newPdb *p
 	return nil
 }
 
+// hasRoleSelector checks if the budget selects pods by their role in the cluster
+func hasRoleSelector(pdb *policyv1.PodDisruptionBudget) bool {
+	if pdb.Spec.Selector == nil {
+		return false
+	}
+	if _, ok := pdb.Spec.Selector.MatchLabels[RedisRoleLabel]; ok {
+		return true
+	}
+	for _, requirement := range pdb.Spec.Selector.MatchExpressions {
+		if requirement.Key == RedisRoleLabel {
+			return true
+		}
+	}
+	return false
+}
+
 // createPodDisruptionBudget is a method to create PodDisruptionBudgets in Kubernetes
 func createPodDisruptionBudget(namespace string, pdb *policyv1.PodDisruptionBudget) error {
 	logger := pdbLogger(namespace, pdb.Name)
@@ -0,0 +1,75 @@
+package k8sutils
+
+import (
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestPodDisruptionBudgetSelectors(t *testing.T) {
+	size := int32(3)
+	pdbParams := &redisv1beta1.RedisPodDisruptionBudget{Enabled: true, ProtectMasters: true}
+	cr := &redisv1beta1.RedisCluster{ObjectMeta: metav1.ObjectMeta{Name: "redis"}}
+	cr.Spec.Size = &size
+	cr.Spec.RedisLeader.PodDisruptionBudget = pdbParams
+	leaderPDB := generatePodDisruptionBudgetDef(cr, "leader", metav1.ObjectMeta{}, pdbParams)
+	masterPDB := generateMasterPodDisruptionBudgetDef(cr, metav1.ObjectMeta{})
+	if leaderPDB.Spec.MaxUnavailable == nil || leaderPDB.Spec.MaxUnavailable.IntValue() != 1 {
+		t.Errorf("got maxUnavailable %v for the leaders, want 1", leaderPDB.Spec.MaxUnavailable)
+	}
+	if masterPDB.Spec.MaxUnavailable == nil || masterPDB.Spec.MaxUnavailable.IntValue() != 0 {
+		t.Errorf("got maxUnavailable %v for the masters, want 0", masterPDB.Spec.MaxUnavailable)
+	}
+	leaderSelector, err := metav1.LabelSelectorAsSelector(leaderPDB.Spec.Selector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	masterSelector, err := metav1.LabelSelectorAsSelector(masterPDB.Spec.Selector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		podLabels  labels.Set
+		wantLeader bool
+		wantMaster bool
+	}{
+		{"leader master", labels.Set{"app": "redis-leader", "role": "leader", "redis_setup_type": "cluster", RedisRoleLabel: "master"}, false, true},
+		{"leader replica", labels.Set{"app": "redis-leader", "role": "leader", "redis_setup_type": "cluster", RedisRoleLabel: "slave"}, true, false},
+		{"unlabelled leader", labels.Set{"app": "redis-leader", "role": "leader", "redis_setup_type": "cluster"}, true, false},
+		{"follower master", labels.Set{"app": "redis-follower", "role": "follower", "redis_setup_type": "cluster", RedisRoleLabel: "master"}, false, true},
+		{"other cluster master", labels.Set{"app": "other-leader", "role": "leader", "redis_setup_type": "cluster", RedisRoleLabel: "master"}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The eviction API refuses pods selected by more than one budget
+			if got := leaderSelector.Matches(tt.podLabels); got != tt.wantLeader {
+				t.Errorf("leader budget selects %v, want %v", got, tt.wantLeader)
+			}
+			if got := masterSelector.Matches(tt.podLabels); got != tt.wantMaster {
+				t.Errorf("master budget selects %v, want %v", got, tt.wantMaster)
+			}
+		})
+	}
+}
+
+func TestPodDisruptionBudgetWithoutMasterBudget(t *testing.T) {
+	size := int32(3)
+	pdbParams := &redisv1beta1.RedisPodDisruptionBudget{Enabled: true}
+	cr := &redisv1beta1.RedisCluster{ObjectMeta: metav1.ObjectMeta{Name: "redis"}}
+	cr.Spec.Size = &size
+	cr.Spec.RedisLeader.PodDisruptionBudget = pdbParams
+	if isMasterPodDisruptionBudgetEnabled(cr) {
+		t.Errorf("master budget enabled without protectMasters")
+	}
+	// Budgets created before the master budget existed keep their quorum and selector
+	leaderPDB := generatePodDisruptionBudgetDef(cr, "leader", metav1.ObjectMeta{}, pdbParams)
+	if leaderPDB.Spec.MinAvailable == nil || leaderPDB.Spec.MinAvailable.IntValue() != 2 || leaderPDB.Spec.MaxUnavailable != nil {
+		t.Errorf("got minAvailable %v maxUnavailable %v, want the quorum 2", leaderPDB.Spec.MinAvailable, leaderPDB.Spec.MaxUnavailable)
+	}
+	if hasRoleSelector(leaderPDB) {
+		t.Errorf("leader budget selects by role %v", leaderPDB.Spec.Selector)
+	}
+}
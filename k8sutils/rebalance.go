@@ -0,0 +1,369 @@
+package k8sutils
+
+import (
+	"fmt"
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// rebalanceBatchSize is the default number of slots moved per reconcile, the rebalance resumes on the next one
+	rebalanceBatchSize = 128
+	// migrateKeysCount is the number of keys moved by a single MIGRATE
+	migrateKeysCount = 100
+
+	rebalancePhaseRebalancing = "Rebalancing"
+	rebalancePhaseCompleted   = "Completed"
+)
+
+// clusterMaster is a master of the cluster with the pod serving it
+type clusterMaster struct {
+	ID      string
+	PodName string
+	IP      string
+	Slots   []int
+	// Draining masters are removed from the cluster and hand over all of their slots
+	Draining bool
+	// Weight is the share of the master in the unpinned slots, 0 counts as 1
+	Weight int
+	// Pinned slots are moved to the master whatever the weights
+	Pinned []int
+}
+
+// weight returns the share of the master in the unpinned slots
+func (m clusterMaster) weight() int {
+	if m.Draining {
+		return 0
+	}
+	if m.Weight == 0 {
+		return 1
+	}
+	return m.Weight
+}
+
+// slotMove is the migration of a single hash slot between two masters
+type slotMove struct {
+	Slot int
+	From string
+	To   string
+}
+
+// ReconcileRedisClusterScaleOut will add new leaders to the formed cluster and move hash slots onto them
+func ReconcileRedisClusterScaleOut(cr *redisv1beta1.RedisCluster, topology *ClusterTopology) (*redisv1beta1.RebalanceStatus, error) {
+	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
+	status := cr.Status.Rebalance.DeepCopy()
+	if !topology.Formed() {
+		return status, nil
+	}
+	for _, node := range topology.Nodes {
+		// A pod restarted without its data rejoins its shard instead of joining as a new leader
+		if node.IsFailed() {
+			logger.Info("Waiting for the failed cluster nodes to be cleaned up before the rebalance", "Node", node.ID)
+			return status, nil
+		}
+	}
+
+	joined := false
+	for podCount := 0; podCount < int(cr.Spec.GetReplicaCounts("leader")); podCount++ {
+		podName := cr.ObjectMeta.Name + "-leader-" + strconv.Itoa(podCount)
+		if isKnownClusterPod(topology, podName) {
+			continue
+		}
+		client := configureRedisClient(cr, podName)
+		nodes, err := getClusterNodes(client)
+		if err != nil || len(nodes) != 1 || len(nodes[0].Slots) > 0 {
+			// Not running yet or not an empty node
+			client.Close()
+			continue
+		}
+		err = client.ClusterMeet(topology.PodIP(topology.SeedPod), "6379").Err()
+		client.Close()
+		if err != nil {
+			return status, err
+		}
+		logger.Info("New leader met the cluster", "Pod", podName)
+		joined = true
+	}
+	if joined {
+		if status == nil || status.Phase != rebalancePhaseRebalancing {
+			now := metav1.Now()
+			status = &redisv1beta1.RebalanceStatus{Phase: rebalancePhaseRebalancing, StartTime: &now}
+		}
+		// Wait for the new masters to be gossiped before their slots are planned
+		return status, nil
+	}
+
+	masters := collectClusterMasters(topology, cr.ObjectMeta.Name+"-leader-")
+	if err := applyShardSlots(cr, masters, topology); err != nil {
+		return status, err
+	}
+	password := getRedisClusterPassword(cr)
+	// Slots left open by an interrupted rebalance are completed before a new plan is made
+	myselfNodes, err := getMasterSelfViews(cr, topology)
+	if err != nil {
+		return status, err
+	}
+	moves := getOpenSlotMoves(myselfNodes)
+	if len(moves) == 0 {
+		moves = planSlotMoves(masters)
+	}
+	if len(moves) == 0 {
+		if status != nil && status.Phase == rebalancePhaseRebalancing {
+			logger.Info("Rebalance of the cluster slots completed", "SlotsMoved", status.SlotsMoved)
+			status.Phase = rebalancePhaseCompleted
+			status.SlotsPending = 0
+		}
+		return status, nil
+	}
+	if status == nil || status.Phase != rebalancePhaseRebalancing {
+		now := metav1.Now()
+		status = &redisv1beta1.RebalanceStatus{Phase: rebalancePhaseRebalancing, StartTime: &now}
+	}
+
+	moved, err := executeSlotMoves(cr, moves, masters, password)
+	status.SlotsMoved += int32(moved)
+	status.SlotsPending = int32(len(moves) - moved)
+	if err != nil {
+		return status, err
+	}
+	logger.Info("Rebalancing cluster slots", "SlotsMoved", status.SlotsMoved, "SlotsPending", status.SlotsPending)
+	return status, nil
+}
+
+// executeSlotMoves will run up to the slot moves allowed per reconcile and return the number of slots moved
+func executeSlotMoves(cr *redisv1beta1.RedisCluster, moves []slotMove, masters []clusterMaster, password string) (int, error) {
+	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
+	mastersByID := map[string]clusterMaster{}
+	for _, master := range masters {
+		mastersByID[master.ID] = master
+	}
+	limit := getMaxSlotsPerReconcile(cr)
+	moved := 0
+	for _, move := range moves {
+		if moved >= limit {
+			break
+		}
+		source, ok := mastersByID[move.From]
+		target, found := mastersByID[move.To]
+		if !ok || !found || source.PodName == "" || target.PodName == "" {
+			return moved, fmt.Errorf("no pod found serving the nodes of slot %d", move.Slot)
+		}
+		// Keys migrated off a master are only safe once its replicas and the replicas of the target caught up
+		if lagging := append(getLaggingReplicas(cr, source.PodName), getLaggingReplicas(cr, target.PodName)...); len(lagging) > 0 {
+			logger.Info("Waiting for the replicas to catch up before the next slot migration", "From", source.PodName, "To", target.PodName, "Replicas", lagging)
+			break
+		}
+		if err := moveSlot(cr, source, target, move.Slot, password); err != nil {
+			logger.Error(err, "Slot migration failed", "Slot", move.Slot, "From", source.PodName, "To", target.PodName)
+			return moved, err
+		}
+		moved++
+	}
+	return moved, nil
+}
+
+// moveSlot will migrate the keys of a slot and assign it to the target, it can be resumed when interrupted
+func moveSlot(cr *redisv1beta1.RedisCluster, source, target clusterMaster, slot int, password string) error {
+	sourceClient := configureRedisClient(cr, source.PodName)
+	defer sourceClient.Close()
+	targetClient := configureRedisClient(cr, target.PodName)
+	defer targetClient.Close()
+
+	if err := targetClient.Do("cluster", "setslot", slot, "importing", source.ID).Err(); err != nil && !strings.Contains(err.Error(), "already the owner") {
+		return err
+	}
+	if err := sourceClient.Do("cluster", "setslot", slot, "migrating", target.ID).Err(); err != nil {
+		// The slot owner changed since the plan was made, the next plan starts from the new layout
+		targetClient.Do("cluster", "setslot", slot, "stable")
+		return err
+	}
+	if err := migrateSlotKeys(sourceClient, target, slot, password); err != nil {
+		return err
+	}
+	for _, client := range []*redis.Client{targetClient, sourceClient} {
+		if err := client.Do("cluster", "setslot", slot, "node", target.ID).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateSlotKeys will MIGRATE all keys of the slot from the source client to the target master
+func migrateSlotKeys(sourceClient *redis.Client, target clusterMaster, slot int, password string) error {
+	for {
+		keys, err := sourceClient.ClusterGetKeysInSlot(slot, migrateKeysCount).Result()
+		if err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			return nil
+		}
+		args := []interface{}{"migrate", target.IP, "6379", "", 0, 5000, "replace"}
+		if password != "" {
+			args = append(args, "auth", password)
+		}
+		args = append(args, "keys")
+		for _, key := range keys {
+			args = append(args, key)
+		}
+		if err := sourceClient.Do(args...).Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// planSlotMoves will compute the slot moves which hand the pinned slots to their masters and spread the other
+// slots over the masters by weight, draining masters give away all of their slots
+func planSlotMoves(masters []clusterMaster) []slotMove {
+	ordered := make([]clusterMaster, len(masters))
+	copy(ordered, masters)
+	// The masters holding the most slots keep the remainder to avoid needless moves
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if len(ordered[i].Slots) != len(ordered[j].Slots) {
+			return len(ordered[i].Slots) > len(ordered[j].Slots)
+		}
+		return ordered[i].ID < ordered[j].ID
+	})
+
+	owners := map[int]string{}
+	for _, master := range ordered {
+		for _, slot := range master.Slots {
+			owners[slot] = master.ID
+		}
+	}
+	pinned := map[int]bool{}
+	var moves []slotMove
+	for _, master := range ordered {
+		if master.Draining {
+			continue
+		}
+		for _, slot := range master.Pinned {
+			pinned[slot] = true
+			// Unassigned pinned slots are left to the slot healing
+			if owner, ok := owners[slot]; ok && owner != master.ID {
+				moves = append(moves, slotMove{Slot: slot, From: owner, To: master.ID})
+			}
+		}
+	}
+
+	unpinned := make([][]int, len(ordered))
+	total, weights := 0, 0
+	for i, master := range ordered {
+		for _, slot := range master.Slots {
+			if !pinned[slot] {
+				unpinned[i] = append(unpinned[i], slot)
+			}
+		}
+		sort.Ints(unpinned[i])
+		total += len(unpinned[i])
+		weights += master.weight()
+	}
+	if weights == 0 {
+		return moves
+	}
+	targets := make([]int, len(ordered))
+	assigned := 0
+	for i, master := range ordered {
+		targets[i] = total * master.weight() / weights
+		assigned += targets[i]
+	}
+	for i, master := range ordered {
+		if assigned == total {
+			break
+		}
+		if master.weight() > 0 {
+			targets[i]++
+			assigned++
+		}
+	}
+
+	var surplus []slotMove
+	for i, master := range ordered {
+		for _, slot := range unpinned[i][minInt(targets[i], len(unpinned[i])):] {
+			surplus = append(surplus, slotMove{Slot: slot, From: master.ID})
+		}
+	}
+	for i, master := range ordered {
+		for need := targets[i] - len(unpinned[i]); need > 0 && len(surplus) > 0; need-- {
+			move := surplus[0]
+			surplus = surplus[1:]
+			move.To = master.ID
+			moves = append(moves, move)
+		}
+	}
+	return moves
+}
+
+// getOpenSlotMoves returns the slots left in migrating state by an interrupted rebalance from the myself lines of the masters
+func getOpenSlotMoves(myselfNodes []ClusterNode) []slotMove {
+	var moves []slotMove
+	for _, node := range myselfNodes {
+		for slot, target := range node.Migrating {
+			moves = append(moves, slotMove{Slot: slot, From: node.ID, To: target})
+		}
+	}
+	sort.Slice(moves, func(i, j int) bool { return moves[i].Slot < moves[j].Slot })
+	return moves
+}
+
+// collectClusterMasters returns the healthy masters owning slots and the empty masters served by leader pods
+func collectClusterMasters(topology *ClusterTopology, leaderPrefix string) []clusterMaster {
+	var masters []clusterMaster
+	for _, node := range topology.Nodes {
+		if !node.IsMaster() || node.IsFailed() {
+			continue
+		}
+		podName := topology.PodName(node)
+		if len(node.Slots) == 0 && !strings.HasPrefix(podName, leaderPrefix) {
+			continue
+		}
+		masters = append(masters, clusterMaster{ID: node.ID, PodName: podName, IP: node.IP, Slots: node.SlotList()})
+	}
+	return masters
+}
+
+// getPodIP returns the IP of a pod from the IP index
+func getPodIP(podsByIP map[string]string, podName string) string {
+	for ip, name := range podsByIP {
+		if name == podName {
+			return ip
+		}
+	}
+	return ""
+}
+
+// isKnownClusterPod checks if the pod is a node of the cluster view
+func isKnownClusterPod(topology *ClusterTopology, podName string) bool {
+	for _, node := range topology.Nodes {
+		if topology.PodName(node) == podName {
+			return true
+		}
+	}
+	return false
+}
+
+// getRedisClusterPassword returns the password of the cluster or an empty string
+func getRedisClusterPassword(cr *redisv1beta1.RedisCluster) string {
+	secret := cr.Spec.KubernetesConfig.ExistingPasswordSecret
+	if secret == nil {
+		return ""
+	}
+	pass, err := getRedisPassword(cr.Namespace, *secret.Name, *secret.Key)
+	if err != nil {
+		generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name).Error(err, "Error in getting redis password")
+	}
+	return pass
+}
+
+// minInt returns the smaller of two integers
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
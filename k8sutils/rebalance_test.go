@@ -0,0 +1,99 @@
+package k8sutils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func slotRange(start, end int) []int {
+	var slots []int
+	for slot := start; slot <= end; slot++ {
+		slots = append(slots, slot)
+	}
+	return slots
+}
+
+func TestPlanSlotMoves(t *testing.T) {
+	masters := []clusterMaster{
+		{ID: "a", Slots: slotRange(0, 5460)},
+		{ID: "b", Slots: slotRange(5461, 10922)},
+		{ID: "c", Slots: slotRange(10923, 16383)},
+		{ID: "d"},
+	}
+	moves := planSlotMoves(masters)
+	if len(moves) != 4096 {
+		t.Fatalf("got %d moves, want 4096", len(moves))
+	}
+	owned := map[string]int{"a": 5461, "b": 5462, "c": 5461}
+	for _, move := range moves {
+		if move.To != "d" {
+			t.Fatalf("slot %d moved to %s, want d", move.Slot, move.To)
+		}
+		owned[move.From]--
+		owned[move.To]++
+	}
+	for id, count := range owned {
+		if count != 4096 {
+			t.Errorf("master %s owns %d slots, want 4096", id, count)
+		}
+	}
+
+	if moves := planSlotMoves(mastersFromCounts(owned)); len(moves) != 0 {
+		t.Errorf("balanced cluster should not move slots, got %d moves", len(moves))
+	}
+}
+
+func mastersFromCounts(owned map[string]int) []clusterMaster {
+	var masters []clusterMaster
+	start := 0
+	for _, id := range []string{"a", "b", "c", "d"} {
+		masters = append(masters, clusterMaster{ID: id, Slots: slotRange(start, start+owned[id]-1)})
+		start += owned[id]
+	}
+	return masters
+}
+
+func TestPlanSlotMovesWeighted(t *testing.T) {
+	masters := []clusterMaster{
+		{ID: "a", Slots: slotRange(0, 5460), Weight: 2},
+		{ID: "b", Slots: slotRange(5461, 10922)},
+		{ID: "c", Slots: slotRange(10923, 16383), Pinned: slotRange(0, 99)},
+	}
+	owned := map[string]int{"a": 5461, "b": 5462, "c": 5461}
+	for _, move := range planSlotMoves(masters) {
+		if move.Slot < 100 && move.To != "c" {
+			t.Errorf("pinned slot %d moved to %s, want c", move.Slot, move.To)
+		}
+		owned[move.From]--
+		owned[move.To]++
+	}
+	// 100 slots are pinned to c, the other 16284 are spread 2:1:1
+	want := map[string]int{"a": 8142, "b": 4071, "c": 4171}
+	if !reflect.DeepEqual(owned, want) {
+		t.Errorf("got %v, want %v", owned, want)
+	}
+}
+
+func TestGetOpenSlotMoves(t *testing.T) {
+	// The seed only shows its own open slots, b has left slot 6000 migrating to c
+	seed := clusterNodesFromOutput(`
+a 10.0.0.1:6379@16379 myself,master - 0 0 1 connected 0-5460
+b 10.0.0.2:6379@16379 master - 0 0 2 connected 5461-10922
+c 10.0.0.3:6379@16379 master - 0 0 3 connected 10923-16383`)
+	if moves := getOpenSlotMoves([]ClusterNode{*findClusterNode(seed, "myself")}); len(moves) != 0 {
+		t.Errorf("seed view got moves %v", moves)
+	}
+
+	var myselfNodes []ClusterNode
+	for _, view := range []string{
+		"a 10.0.0.1:6379@16379 myself,master - 0 0 1 connected 0-5460",
+		"b 10.0.0.2:6379@16379 myself,master - 0 0 2 connected 5461-10922 [6000->-c]",
+		"c 10.0.0.3:6379@16379 myself,master - 0 0 3 connected 10923-16383 [6000-<-b]",
+	} {
+		myselfNodes = append(myselfNodes, *findClusterNode(clusterNodesFromOutput(view), "myself"))
+	}
+	want := []slotMove{{Slot: 6000, From: "b", To: "c"}}
+	if got := getOpenSlotMoves(myselfNodes); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -18,6 +18,7 @@ type RedisClusterSTS struct {
 // RedisClusterService is a interface to call Redis Service function
 type RedisClusterService struct {
 	RedisServiceRole string
+	ServiceConfig    *redisv1beta1.ServiceConfig
 }
 
 // generateRedisStandalone generates Redis standalone information
@@ -30,6 +31,7 @@ func generateRedisClusterParams(cr *redisv1beta1.RedisCluster, replicas int32, e
 		PriorityClassName: cr.Spec.PriorityClassName,
 		Affinity:          affinity,
 		Tolerations:       cr.Spec.Tolerations,
+		OnDeleteUpdates:   true,
 	}
 	if cr.Spec.RedisExporter != nil {
 		res.EnableMetrics = cr.Spec.RedisExporter.Enabled
@@ -37,7 +39,9 @@ func generateRedisClusterParams(cr *redisv1beta1.RedisCluster, replicas int32, e
 	if cr.Spec.KubernetesConfig.ImagePullSecrets != nil {
 		res.ImagePullSecrets = cr.Spec.KubernetesConfig.ImagePullSecrets
 	}
-	if cr.Spec.Storage != nil {
+	if cr.Spec.Storage.IsEphemeral() {
+		res.EphemeralStorage = cr.Spec.Storage.Ephemeral
+	} else if cr.Spec.Storage != nil {
 		res.PersistentVolumeClaim = cr.Spec.Storage.VolumeClaimTemplate
 	}
 	if externalConfig != nil {
@@ -82,7 +86,7 @@ func generateRedisClusterContainerParams(cr *redisv1beta1.RedisCluster, readines
 	if livenessProbeDef != nil {
 		containerProp.LivenessProbe = livenessProbeDef
 	}
-	if cr.Spec.Storage != nil {
+	if cr.Spec.Storage != nil && !cr.Spec.Storage.IsEphemeral() {
 		containerProp.PersistenceEnabled = &trueProperty
 	}
 	if cr.Spec.TLS != nil {
@@ -124,6 +128,7 @@ func CreateRedisFollower(cr *redisv1beta1.RedisCluster) error {
 func CreateRedisLeaderService(cr *redisv1beta1.RedisCluster) error {
 	prop := RedisClusterService{
 		RedisServiceRole: "leader",
+		ServiceConfig:    cr.Spec.RedisLeader.Service,
 	}
 	return prop.CreateRedisClusterService(cr)
 }
@@ -132,11 +137,21 @@ func CreateRedisLeaderService(cr *redisv1beta1.RedisCluster) error {
 func CreateRedisFollowerService(cr *redisv1beta1.RedisCluster) error {
 	prop := RedisClusterService{
 		RedisServiceRole: "follower",
+		ServiceConfig:    cr.Spec.RedisFollower.Service,
 	}
 	return prop.CreateRedisClusterService(cr)
 }
 
 func (service RedisClusterSTS) getReplicaCount(cr *redisv1beta1.RedisCluster) int32 {
+	// Leaders removed by a scale-in keep running until their slots are drained
+	if service.RedisStateFulType == "leader" && cr.Status.ScaleIn != nil && cr.Status.ScaleIn.Phase != scaleInPhaseCompleted {
+		return cr.Status.ScaleIn.FromReplicas
+	}
+	// Followers removed along with the leaders keep running until they are forgotten by the cluster
+	if service.RedisStateFulType == "follower" && cr.Status.ScaleIn != nil && cr.Status.ScaleIn.Phase != scaleInPhaseCompleted &&
+		cr.Status.ScaleIn.FromFollowerReplicas > cr.Spec.GetReplicaCounts("follower") {
+		return cr.Status.ScaleIn.FromFollowerReplicas
+	}
 	return cr.Spec.GetReplicaCounts(service.RedisStateFulType)
 }
 
@@ -147,11 +162,28 @@ func (service RedisClusterSTS) CreateRedisClusterSetup(cr *redisv1beta1.RedisClu
 	labels := getRedisLabels(stateFulName, "cluster", service.RedisStateFulType, cr.ObjectMeta.Labels)
 	annotations := generateStatefulSetsAnots(cr.ObjectMeta)
 	objectMetaInfo := generateObjectMetaInformation(stateFulName, cr.Namespace, labels, annotations)
-	err := CreateOrUpdateStateFul(
+	params := generateRedisClusterParams(cr, service.getReplicaCount(cr), service.ExternalConfig, service.Affinity)
+	applyStorageMigration(&params, cr.Status.StorageMigrations, stateFulName)
+	announce, err := generateAnnounceConfigData(cr, stateFulName, params.Replicas)
+	if err != nil {
+		logger.Error(err, "Cannot read the external addresses of the pods", "Setup.Type", service.RedisStateFulType)
+		return err
+	}
+	configData := generateRedisConfigData(service.ExternalConfig, generatePersistenceConfig(effectivePersistence(cr.Spec.Persistence, cr.Spec.Storage)), announce)
+	configName := getGeneratedConfigName(stateFulName)
+	if err := ReconcileGeneratedConfig(cr.Namespace, generateObjectMetaInformation(configName, cr.Namespace, labels, annotations), configData, RedisClusterAsOwner(cr)); err != nil {
+		logger.Error(err, "Cannot create the generated config for Redis", "Setup.Type", service.RedisStateFulType)
+		return err
+	}
+	if configData != nil {
+		params.GeneratedConfig = &configName
+		params.AnnounceConfig = announce != nil
+	}
+	err = CreateOrUpdateStateFul(
 		cr.Namespace,
 		objectMetaInfo,
-		generateRedisClusterParams(cr, service.getReplicaCount(cr), service.ExternalConfig, service.Affinity),
-		redisClusterAsOwner(cr),
+		params,
+		RedisClusterAsOwner(cr),
 		generateRedisClusterContainerParams(cr, service.ReadinessProbe, service.LivenessProbe),
 		cr.Spec.Sidecars,
 	)
@@ -173,12 +205,12 @@ func (service RedisClusterService) CreateRedisClusterService(cr *redisv1beta1.Re
 	}
 	objectMetaInfo := generateObjectMetaInformation(serviceName, cr.Namespace, labels, annotations)
 	headlessObjectMetaInfo := generateObjectMetaInformation(serviceName+"-headless", cr.Namespace, labels, annotations)
-	err := CreateOrUpdateService(cr.Namespace, headlessObjectMetaInfo, redisClusterAsOwner(cr), false, true)
+	err := CreateOrUpdateService(cr.Namespace, headlessObjectMetaInfo, RedisClusterAsOwner(cr), false, true, nil)
 	if err != nil {
 		logger.Error(err, "Cannot create headless service for Redis", "Setup.Type", service.RedisServiceRole)
 		return err
 	}
-	err = CreateOrUpdateService(cr.Namespace, objectMetaInfo, redisClusterAsOwner(cr), enableMetrics, false)
+	err = CreateOrUpdateService(cr.Namespace, objectMetaInfo, RedisClusterAsOwner(cr), enableMetrics, false, service.ServiceConfig)
 	if err != nil {
 		logger.Error(err, "Cannot create service for Redis", "Setup.Type", service.RedisServiceRole)
 		return err
@@ -0,0 +1,146 @@
+package k8sutils
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	generatedConfigVolume = "generated-config"
+	// generatedConfigPath is the directory whose redis-additional.conf the redis image includes at startup
+	generatedConfigPath = "/etc/redis/external.conf.d"
+	generatedConfigKey  = "redis-additional.conf"
+	// additionalConfigPath is where the additional config of the user moves to when the operator renders a config
+	additionalConfigPath = "/etc/redis/additional.conf.d"
+	// announceConfigPath is where every pod finds the announce config rendered for its own name
+	announceConfigPath = "/etc/redis/announce.conf"
+)
+
+// renderRedisConfig will render config directives into the lines of a redis config file
+func renderRedisConfig(params []redisConfigParam) string {
+	var lines []string
+	for _, param := range params {
+		if param.Name == "save" {
+			// An empty save drops the save points of the image config, redis 6 reads a single save point per line
+			lines = append(lines, `save ""`)
+			fields := strings.Fields(param.Value)
+			for i := 0; i+1 < len(fields); i += 2 {
+				lines = append(lines, fmt.Sprintf("save %s %s", fields[i], fields[i+1]))
+			}
+			continue
+		}
+		if param.Value == "" {
+			lines = append(lines, param.Name+` ""`)
+			continue
+		}
+		lines = append(lines, param.Name+" "+param.Value)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// generateRedisConfigData will render the config included by redis at startup, the additional config of the user is
+// included first so the directives of the custom resource take precedence as they do at runtime, the announce config
+// holds a key by pod name and is left nil when the pods announce their pod IP
+func generateRedisConfigData(externalConfig *string, params []redisConfigParam, announce map[string]string) map[string]string {
+	if len(params) == 0 && announce == nil {
+		return nil
+	}
+	data := map[string]string{}
+	config := ""
+	if externalConfig != nil {
+		config = fmt.Sprintf("include %s/%s\n", additionalConfigPath, generatedConfigKey)
+	}
+	if announce != nil {
+		config += fmt.Sprintf("include %s\n", announceConfigPath)
+		for podName, podConfig := range announce {
+			data[podName+".conf"] = podConfig
+		}
+	}
+	if len(params) > 0 {
+		config += renderRedisConfig(params)
+	}
+	data[generatedConfigKey] = config
+	return data
+}
+
+// ReconcileGeneratedConfig will create or update the config map holding the config rendered by the operator, the
+// config map is deleted when there is nothing to render
+func ReconcileGeneratedConfig(namespace string, configMeta metav1.ObjectMeta, data map[string]string, ownerDef metav1.OwnerReference) error {
+	logger := statefulSetLogger(namespace, configMeta.Name)
+	client := generateK8sClient().CoreV1().ConfigMaps(namespace)
+	stored, err := client.Get(context.TODO(), configMeta.Name, metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if data == nil {
+		if err != nil {
+			return nil
+		}
+		logger.Info("Generated redis config no longer needed is deleted")
+		return client.Delete(context.TODO(), configMeta.Name, metav1.DeleteOptions{})
+	}
+	configMap := &corev1.ConfigMap{
+		TypeMeta:   generateMetaInformation("ConfigMap", "v1"),
+		ObjectMeta: configMeta,
+		Data:       data,
+	}
+	AddOwnerRefToObject(configMap, ownerDef)
+	if err != nil {
+		logger.Info("Generated redis config is created")
+		_, err = client.Create(context.TODO(), configMap, metav1.CreateOptions{})
+		return err
+	}
+	if reflect.DeepEqual(stored.Data, data) {
+		return nil
+	}
+	stored.Data = data
+	logger.Info("Generated redis config is updated")
+	_, err = client.Update(context.TODO(), stored, metav1.UpdateOptions{})
+	return err
+}
+
+// mountGeneratedConfig will mount the generated config where the redis image includes it and move the additional
+// config of the user next to it, the announce config of the pod is mounted by the name of the pod
+func mountGeneratedConfig(podSpec *corev1.PodSpec, configMapName string, announce bool) {
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name: generatedConfigVolume,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: configMapName},
+			},
+		},
+	})
+	container := &podSpec.Containers[0]
+	for i, mount := range container.VolumeMounts {
+		if mount.Name == "external-config" {
+			container.VolumeMounts[i].MountPath = additionalConfigPath
+		}
+	}
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+		Name:      generatedConfigVolume,
+		MountPath: generatedConfigPath,
+	})
+	if !announce {
+		return
+	}
+	container.Env = append(container.Env, corev1.EnvVar{
+		Name:      "POD_NAME",
+		ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"}},
+	})
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+		Name:        generatedConfigVolume,
+		MountPath:   announceConfigPath,
+		SubPathExpr: "$(POD_NAME).conf",
+	})
+}
+
+// getGeneratedConfigName returns the name of the config map holding the config rendered for a statefulset
+func getGeneratedConfigName(stsName string) string {
+	return stsName + "-generated-config"
+}
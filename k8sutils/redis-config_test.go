@@ -0,0 +1,86 @@
+package k8sutils
+
+import (
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestRenderRedisConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		persistence *redisv1beta1.Persistence
+		want        string
+	}{
+		{
+			name:        "cache only",
+			persistence: &redisv1beta1.Persistence{CacheOnly: true},
+			want:        "save \"\"\nappendonly no\n",
+		},
+		{
+			name: "rdb and aof",
+			persistence: &redisv1beta1.Persistence{
+				RDB: &redisv1beta1.RDBPersistence{Enabled: true, SavePoints: []redisv1beta1.RDBSavePoint{{Seconds: 60, Changes: 100}, {Seconds: 300, Changes: 1}}},
+				AOF: &redisv1beta1.AOFPersistence{Enabled: true, AppendFsync: "always"},
+			},
+			want: "save \"\"\nsave 60 100\nsave 300 1\nappendonly yes\nappendfsync always\n",
+		},
+		{
+			name:        "rdb disabled",
+			persistence: &redisv1beta1.Persistence{RDB: &redisv1beta1.RDBPersistence{Enabled: false}},
+			want:        "save \"\"\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderRedisConfig(generatePersistenceConfig(tt.persistence)); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateRedisConfigData(t *testing.T) {
+	if got := generateRedisConfigData(nil, nil, nil); got != nil {
+		t.Errorf("got %v without directives", got)
+	}
+	externalConfig := "redis-external-config"
+	params := generatePersistenceConfig(&redisv1beta1.Persistence{CacheOnly: true})
+	want := map[string]string{generatedConfigKey: "include /etc/redis/additional.conf.d/redis-additional.conf\nsave \"\"\nappendonly no\n"}
+	if got := generateRedisConfigData(&externalConfig, params, nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	announce := map[string]string{"redis-leader-0": "cluster-announce-ip 10.0.0.1\n", "redis-leader-1": ""}
+	want = map[string]string{
+		generatedConfigKey:    "include /etc/redis/announce.conf\n",
+		"redis-leader-0.conf": "cluster-announce-ip 10.0.0.1\n",
+		"redis-leader-1.conf": "",
+	}
+	if got := generateRedisConfigData(nil, nil, announce); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestMountGeneratedConfig(t *testing.T) {
+	podSpec := corev1.PodSpec{
+		Volumes:    getExternalConfig("redis-external-config"),
+		Containers: []corev1.Container{{VolumeMounts: []corev1.VolumeMount{{Name: "external-config", MountPath: "/etc/redis/external.conf.d"}}}},
+	}
+	mountGeneratedConfig(&podSpec, "redis-generated-config", true)
+	want := []corev1.VolumeMount{
+		{Name: "external-config", MountPath: additionalConfigPath},
+		{Name: generatedConfigVolume, MountPath: generatedConfigPath},
+		{Name: generatedConfigVolume, MountPath: announceConfigPath, SubPathExpr: "$(POD_NAME).conf"},
+	}
+	if !reflect.DeepEqual(podSpec.Containers[0].VolumeMounts, want) {
+		t.Errorf("got mounts %v, want %v", podSpec.Containers[0].VolumeMounts, want)
+	}
+	if len(podSpec.Volumes) != 2 || podSpec.Volumes[1].ConfigMap.Name != "redis-generated-config" {
+		t.Errorf("got volumes %v", podSpec.Volumes)
+	}
+	if env := podSpec.Containers[0].Env; len(env) != 1 || env[0].Name != "POD_NAME" || env[0].ValueFrom.FieldRef.FieldPath != "metadata.name" {
+		t.Errorf("got env %v", env)
+	}
+}
@@ -18,12 +18,12 @@ func CreateStandaloneService(cr *redisv1beta1.Redis) error {
 	}
 	objectMetaInfo := generateObjectMetaInformation(cr.ObjectMeta.Name, cr.Namespace, labels, annotations)
 	headlessObjectMetaInfo := generateObjectMetaInformation(cr.ObjectMeta.Name+"-headless", cr.Namespace, labels, annotations)
-	err := CreateOrUpdateService(cr.Namespace, headlessObjectMetaInfo, redisAsOwner(cr), false, true)
+	err := CreateOrUpdateService(cr.Namespace, headlessObjectMetaInfo, RedisAsOwner(cr), false, true, nil)
 	if err != nil {
 		logger.Error(err, "Cannot create standalone headless service for Redis")
 		return err
 	}
-	err = CreateOrUpdateService(cr.Namespace, objectMetaInfo, redisAsOwner(cr), enableMetrics, false)
+	err = CreateOrUpdateService(cr.Namespace, objectMetaInfo, RedisAsOwner(cr), enableMetrics, false, cr.Spec.Service)
 	if err != nil {
 		logger.Error(err, "Cannot create standalone service for Redis")
 		return err
@@ -37,10 +37,21 @@ func CreateStandaloneRedis(cr *redisv1beta1.Redis) error {
 	labels := getRedisLabels(cr.ObjectMeta.Name, "standalone", "standalone", cr.ObjectMeta.Labels)
 	annotations := generateStatefulSetsAnots(cr.ObjectMeta)
 	objectMetaInfo := generateObjectMetaInformation(cr.ObjectMeta.Name, cr.Namespace, labels, annotations)
+	params := generateRedisStandaloneParams(cr)
+	applyStorageMigration(&params, cr.Status.StorageMigrations, cr.ObjectMeta.Name)
+	configData := generateRedisConfigData(params.ExternalConfig, generatePersistenceConfig(effectivePersistence(cr.Spec.Persistence, cr.Spec.Storage)), nil)
+	configName := getGeneratedConfigName(cr.ObjectMeta.Name)
+	if err := ReconcileGeneratedConfig(cr.Namespace, generateObjectMetaInformation(configName, cr.Namespace, labels, annotations), configData, RedisAsOwner(cr)); err != nil {
+		logger.Error(err, "Cannot create the generated config for Redis")
+		return err
+	}
+	if configData != nil {
+		params.GeneratedConfig = &configName
+	}
 	err := CreateOrUpdateStateFul(cr.Namespace,
 		objectMetaInfo,
-		generateRedisStandaloneParams(cr),
-		redisAsOwner(cr),
+		params,
+		RedisAsOwner(cr),
 		generateRedisStandaloneContainerParams(cr),
 		cr.Spec.Sidecars,
 	)
@@ -65,7 +76,9 @@ func generateRedisStandaloneParams(cr *redisv1beta1.Redis) statefulSetParameters
 	if cr.Spec.KubernetesConfig.ImagePullSecrets != nil {
 		res.ImagePullSecrets = cr.Spec.KubernetesConfig.ImagePullSecrets
 	}
-	if cr.Spec.Storage != nil {
+	if cr.Spec.Storage.IsEphemeral() {
+		res.EphemeralStorage = cr.Spec.Storage.Ephemeral
+	} else if cr.Spec.Storage != nil {
 		res.PersistentVolumeClaim = cr.Spec.Storage.VolumeClaimTemplate
 	}
 	if cr.Spec.RedisConfig != nil {
@@ -114,7 +127,7 @@ func generateRedisStandaloneContainerParams(cr *redisv1beta1.Redis) containerPar
 	if cr.Spec.LivenessProbe != nil {
 		containerProp.LivenessProbe = cr.Spec.LivenessProbe
 	}
-	if cr.Spec.Storage != nil {
+	if cr.Spec.Storage != nil && !cr.Spec.Storage.IsEphemeral() {
 		containerProp.PersistenceEnabled = &trueProperty
 	}
 	return containerProp
@@ -3,7 +3,6 @@ package k8sutils
 import (
 	"bytes"
 	"context"
-	"encoding/csv"
 	"fmt"
 	"net"
 	"strconv"
@@ -13,12 +12,18 @@ import (
 
 	"github.com/go-logr/logr"
 	"github.com/go-redis/redis"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/remotecommand"
 )
 
+// GoverningServiceCondition reports whether the statefulsets are governed by the headless services the hostnames of
+// the pods resolve under
+const GoverningServiceCondition = "GoverningService"
+
 // RedisDetails will hold the information for Redis Pod
 type RedisDetails struct {
 	PodName   string
@@ -44,17 +49,153 @@ func getRedisServerIP(redisInfo RedisDetails) string {
 	return redisIP
 }
 
-// ExecuteRedisClusterCommand will execute redis cluster creation command
-func ExecuteRedisClusterCommand(cr *redisv1beta1.RedisCluster) {
+// getRedisHostname will return the DNS name of the pod under the headless service of its statefulset
+func getRedisHostname(redisInfo RedisDetails, stsName string) string {
+	return fmt.Sprintf("%s.%s-headless.%s.svc", redisInfo.PodName, stsName, redisInfo.Namespace)
+}
+
+// getRedisServerAddress will return the hostname of the pod when the cluster announces hostnames and its IP otherwise
+func getRedisServerAddress(redisInfo RedisDetails, stsName string, hostnames bool) string {
+	if hostnames {
+		return getRedisHostname(redisInfo, stsName)
+	}
+	return getRedisServerIP(redisInfo)
+}
+
+// useRedisClusterHostnames will check if the cluster runs Redis 7+ and its pods resolve under the headless services
+func useRedisClusterHostnames(cr *redisv1beta1.RedisCluster) bool {
+	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
+	if isExternalAccessEnabled(cr) {
+		// External clients cannot resolve the headless service names
+		return false
+	}
+	for _, role := range []string{"leader", "follower"} {
+		sts, err := GetStatefulSet(cr.Namespace, cr.ObjectMeta.Name+"-"+role)
+		if err != nil || sts.Spec.ServiceName != sts.Name+"-headless" {
+			return false
+		}
+	}
+	client := configureRedisClient(cr, cr.ObjectMeta.Name+"-leader-0")
+	defer client.Close()
+	info, err := getRedisInfo(client, "server")
+	if err != nil {
+		logger.Error(err, "Could not get the redis version")
+		return false
+	}
+	return isRedisVersionAtLeast(info["redis_version"], 7)
+}
+
+// CheckRedisClusterGoverningServices will report the statefulsets still governed by the client service, the governing
+// service is immutable so their pods announce IPs instead of hostnames until the statefulsets are recreated
+func CheckRedisClusterGoverningServices(cr *redisv1beta1.RedisCluster) (metav1.Condition, error) {
+	var statefulSets []appsv1.StatefulSet
+	for _, role := range []string{"leader", "follower"} {
+		sts, err := GetStatefulSet(cr.Namespace, cr.ObjectMeta.Name+"-"+role)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return metav1.Condition{}, err
+		}
+		statefulSets = append(statefulSets, *sts)
+	}
+	return getGoverningServiceCondition(statefulSets), nil
+}
+
+// getGoverningServiceCondition returns the governing service condition of the statefulsets
+func getGoverningServiceCondition(statefulSets []appsv1.StatefulSet) metav1.Condition {
+	var legacy []string
+	for _, sts := range statefulSets {
+		if sts.Spec.ServiceName != sts.Name+"-headless" {
+			legacy = append(legacy, sts.Name)
+		}
+	}
+	if len(legacy) > 0 {
+		return metav1.Condition{
+			Type:   GoverningServiceCondition,
+			Status: metav1.ConditionFalse,
+			Reason: "LegacyGoverningService",
+			Message: fmt.Sprintf("%s keep the governing service they were created with and their Redis 7 pods announce IPs instead of hostnames, "+
+				"delete them with kubectl delete statefulset --cascade=orphan to have them recreated on the headless service", strings.Join(legacy, ",")),
+		}
+	}
+	return metav1.Condition{Type: GoverningServiceCondition, Status: metav1.ConditionTrue, Reason: "HeadlessServiceGoverned", Message: "The statefulsets are governed by the headless services"}
+}
+
+// isRedisVersionAtLeast will check if the major version of a redis_version string is at least major
+func isRedisVersionAtLeast(version string, major int) bool {
+	versionMajor, err := strconv.Atoi(strings.SplitN(version, ".", 2)[0])
+	if err != nil {
+		return false
+	}
+	return versionMajor >= major
+}
+
+// ConfigureRedisClusterHostnames will make Redis 7+ nodes prefer the headless service DNS names they announce, the
+// hostname itself is loaded from the generated config when the pod starts
+func ConfigureRedisClusterHostnames(cr *redisv1beta1.RedisCluster) error {
+	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
+	if !useRedisClusterHostnames(cr) {
+		return nil
+	}
+	for _, role := range []string{"leader", "follower"} {
+		stsName := cr.ObjectMeta.Name + "-" + role
+		for podCount := 0; podCount < int(cr.Spec.GetReplicaCounts(role)); podCount++ {
+			podName := stsName + "-" + strconv.Itoa(podCount)
+			client := configureRedisClient(cr, podName)
+			hostname, err := client.ConfigGet("cluster-announce-hostname").Result()
+			if err == nil && (len(hostname) != 2 || hostname[1] == "") {
+				// Preferring hostnames before one is announced would spread empty endpoints
+				logger.Info("Pod announces its hostname after its next restart", "Pod", podName)
+				client.Close()
+				continue
+			}
+			if err == nil {
+				err = client.ConfigSet("cluster-preferred-endpoint-type", "hostname").Err()
+			}
+			client.Close()
+			if err != nil {
+				logger.Error(err, "Could not configure the preferred endpoint type", "Pod", podName)
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// renderHostnameConfigs returns the config announcing the headless service hostname of every pod of the statefulset by
+// pod name
+func renderHostnameConfigs(namespace, stsName string, replicas int32) map[string]string {
+	configs := map[string]string{}
+	for podCount := 0; podCount < int(replicas); podCount++ {
+		pod := RedisDetails{PodName: stsName + "-" + strconv.Itoa(podCount), Namespace: namespace}
+		configs[pod.PodName] = renderRedisConfig([]redisConfigParam{{Name: "cluster-announce-hostname", Value: getRedisHostname(pod, stsName)}})
+	}
+	return configs
+}
+
+// ExecuteRedisClusterCommand will create the cluster with native commands and fall back to redis-cli in leader-0
+func ExecuteRedisClusterCommand(cr *redisv1beta1.RedisCluster, topology *ClusterTopology) {
 	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
+	err := createRedisClusterNative(cr, topology)
+	if err == nil {
+		return
+	}
+	if assigned, checkErr := leadersOwnSlots(cr); checkErr != nil || assigned {
+		// The native creation resumes from the assigned slots on the next reconcile
+		logger.Error(err, "Native cluster creation failed after slots were assigned, redis-cli is not used")
+		return
+	}
+	logger.Error(err, "Native cluster creation failed, falling back to redis-cli")
 	replicas := cr.Spec.GetReplicaCounts("leader")
+	hostnames := useRedisClusterHostnames(cr)
 	cmd := []string{"redis-cli", "--cluster", "create"}
 	for podCount := 0; podCount <= int(replicas)-1; podCount++ {
 		pod := RedisDetails{
 			PodName:   cr.ObjectMeta.Name + "-leader-" + strconv.Itoa(podCount),
 			Namespace: cr.Namespace,
 		}
-		cmd = append(cmd, getRedisServerIP(pod)+":6379")
+		cmd = append(cmd, getRedisServerAddress(pod, cr.ObjectMeta.Name+"-leader", hostnames)+":6379")
 	}
 	cmd = append(cmd, "--cluster-yes")
 
@@ -84,12 +225,12 @@ func getRedisTLSArgs(tlsConfig *redisv1beta1.TLSConfig, clientHost string) []str
 }
 
 // createRedisReplicationCommand will create redis replication creation command
-func createRedisReplicationCommand(cr *redisv1beta1.RedisCluster, leaderPod RedisDetails, followerPod RedisDetails) []string {
+func createRedisReplicationCommand(cr *redisv1beta1.RedisCluster, leaderPod RedisDetails, followerPod RedisDetails, masterID string, hostnames bool) []string {
 	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
 	cmd := []string{"redis-cli", "--cluster", "add-node"}
-	cmd = append(cmd, getRedisServerIP(followerPod)+":6379")
-	cmd = append(cmd, getRedisServerIP(leaderPod)+":6379")
-	cmd = append(cmd, "--cluster-slave")
+	cmd = append(cmd, getRedisServerAddress(followerPod, cr.ObjectMeta.Name+"-follower", hostnames)+":6379")
+	cmd = append(cmd, getRedisServerAddress(leaderPod, cr.ObjectMeta.Name+"-leader", hostnames)+":6379")
+	cmd = append(cmd, "--cluster-slave", "--cluster-master-id", masterID)
 
 	if cr.Spec.KubernetesConfig.ExistingPasswordSecret != nil {
 		pass, err := getRedisPassword(cr.Namespace, *cr.Spec.KubernetesConfig.ExistingPasswordSecret.Name, *cr.Spec.KubernetesConfig.ExistingPasswordSecret.Key)
@@ -104,59 +245,58 @@ func createRedisReplicationCommand(cr *redisv1beta1.RedisCluster, leaderPod Redi
 	return cmd
 }
 
-// ExecuteRedisReplicationCommand will execute the replication command
-func ExecuteRedisReplicationCommand(cr *redisv1beta1.RedisCluster) {
+// ExecuteRedisReplicationCommand will add the missing followers as replicas of the masters with the fewest replicas
+func ExecuteRedisReplicationCommand(cr *redisv1beta1.RedisCluster, topology *ClusterTopology) {
 	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
 	replicas := cr.Spec.GetReplicaCounts("follower")
-	nodes := checkRedisCluster(cr)
+	nodes := append([]ClusterNode{}, topology.Nodes...)
+	hostnames := useRedisClusterHostnames(cr)
+	domains, err := getPodFailureDomains(cr)
+	if err != nil {
+		logger.Error(err, "Could not get the failure domains of the pods")
+	}
 	for podCount := 0; podCount <= int(replicas)-1; podCount++ {
 		followerPod := RedisDetails{
 			PodName:   cr.ObjectMeta.Name + "-follower-" + strconv.Itoa(podCount),
 			Namespace: cr.Namespace,
 		}
-		leaderPod := RedisDetails{
-			PodName:   cr.ObjectMeta.Name + "-leader-" + strconv.Itoa(podCount),
-			Namespace: cr.Namespace,
+		podIP := topology.PodIP(followerPod.PodName)
+		if podIP == "" {
+			logger.Info("Follower has no IP yet", "Follower.Pod", followerPod)
+			continue
 		}
-		podIP := getRedisServerIP(followerPod)
 		if !checkRedisNodePresence(cr, nodes, podIP) {
-			logger.Info("Adding node to cluster.", "Node.IP", podIP, "Follower.Pod", followerPod)
-			cmd := createRedisReplicationCommand(cr, leaderPod, followerPod)
-			executeCommand(cr, cmd, cr.ObjectMeta.Name+"-leader-0")
+			master := findClusterNodeByID(nodes, getMasterForReplica(nodes, domains, podIP))
+			if master == nil {
+				logger.Info("No master found for the follower", "Follower.Pod", followerPod)
+				return
+			}
+			leaderPod := RedisDetails{PodName: topology.PodName(*master), Namespace: cr.Namespace}
+			logger.Info("Adding node to cluster.", "Node.IP", podIP, "Follower.Pod", followerPod, "Master", master.ID)
+			if err := attachRedisReplicaNative(cr, followerPod.PodName, master.ID, master.IP); err != nil {
+				logger.Error(err, "Native replica attach failed, falling back to redis-cli", "Follower.Pod", followerPod)
+				cmd := createRedisReplicationCommand(cr, leaderPod, followerPod, master.ID, hostnames)
+				executeCommand(cr, cmd, cr.ObjectMeta.Name+"-leader-0")
+			}
+			// Count the new replica so the next follower goes to another master
+			nodes = append(nodes, ClusterNode{IP: podIP, Flags: []string{"slave"}, MasterID: master.ID, LinkState: "connected"})
+		} else if node := findClusterNodeByIP(nodes, podIP); node != nil && node.IsMaster() && len(node.Slots) == 0 {
+			// A follower left as empty master by an interrupted attach only needs to replicate
+			master := findClusterNodeByID(nodes, getMasterForReplica(nodes, domains, podIP))
+			if master == nil || master.ID == node.ID {
+				continue
+			}
+			logger.Info("Follower is an empty master, attaching it as replica", "Follower.Pod", followerPod, "Master", master.ID)
+			if err := attachRedisReplicaNative(cr, followerPod.PodName, master.ID, master.IP); err != nil {
+				logger.Error(err, "Could not attach follower as replica", "Follower.Pod", followerPod)
+			}
 		} else {
 			logger.Info("Skipping Adding node to cluster, already present.", "Follower.Pod", followerPod)
 		}
 	}
 }
 
-// checkRedisCluster will check the redis cluster have sufficient nodes or not
-func checkRedisCluster(cr *redisv1beta1.RedisCluster) [][]string {
-	var client *redis.Client
-	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
-	client = configureRedisClient(cr, cr.ObjectMeta.Name+"-leader-0")
-	cmd := redis.NewStringCmd("cluster", "nodes")
-	err := client.Process(cmd)
-	if err != nil {
-		logger.Error(err, "Redis command failed with this error")
-	}
-
-	output, err := cmd.Result()
-	if err != nil {
-		logger.Error(err, "Redis command failed with this error")
-	}
-	logger.Info("Redis cluster nodes are listed", "Output", output)
-
-	csvOutput := csv.NewReader(strings.NewReader(output))
-	csvOutput.Comma = ' '
-	csvOutput.FieldsPerRecord = -1
-	csvOutputRecords, err := csvOutput.ReadAll()
-	if err != nil {
-		logger.Error(err, "Error parsing Node Counts", "output", output)
-	}
-	return csvOutputRecords
-}
-
-// ExecuteFailoverOperation will execute redis failover operations
+// ExecuteFailoverOperation will reset and flush every redis node, it only runs on an explicit rebuild request
 func ExecuteFailoverOperation(cr *redisv1beta1.RedisCluster) error {
 	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
 	err := executeFailoverCommand(cr, "leader")
@@ -206,25 +346,14 @@ func executeFailoverCommand(cr *redisv1beta1.RedisCluster, role string) error {
 	return nil
 }
 
-// CheckRedisNodeCount will check the count of redis nodes
-func CheckRedisNodeCount(cr *redisv1beta1.RedisCluster, nodeType string) int32 {
-	var redisNodeType string
+// CheckRedisNodeCount will check the count of redis nodes in the topology snapshot
+func CheckRedisNodeCount(cr *redisv1beta1.RedisCluster, topology *ClusterTopology, nodeType string) int32 {
 	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
-	clusterNodes := checkRedisCluster(cr)
-	count := len(clusterNodes)
-
-	switch nodeType {
-	case "leader":
-		redisNodeType = "master"
-	case "follower":
-		redisNodeType = "slave"
-	default:
-		redisNodeType = nodeType
-	}
+	count := len(topology.Nodes)
 	if nodeType != "" {
 		count = 0
-		for _, node := range clusterNodes {
-			if strings.Contains(node[2], redisNodeType) {
+		for _, node := range topology.Nodes {
+			if (nodeType == "leader" && node.IsMaster()) || (nodeType == "follower" && node.IsReplica()) {
 				count++
 			}
 		}
@@ -235,14 +364,12 @@ func CheckRedisNodeCount(cr *redisv1beta1.RedisCluster, nodeType string) int32 {
 	return int32(count)
 }
 
-// CheckRedisClusterState will check the redis cluster state
-func CheckRedisClusterState(cr *redisv1beta1.RedisCluster) int {
+// CheckRedisClusterState will count the failed or disconnected nodes of the topology snapshot
+func CheckRedisClusterState(cr *redisv1beta1.RedisCluster, topology *ClusterTopology) int {
 	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
-	clusterNodes := checkRedisCluster(cr)
 	count := 0
-
-	for _, node := range clusterNodes {
-		if strings.Contains(node[2], "fail") || strings.Contains(node[7], "disconnected") {
+	for _, node := range topology.Nodes {
+		if node.HasFlag("fail") || node.HasFlag("fail?") || !node.IsConnected() {
 			count++
 		}
 	}
@@ -252,33 +379,64 @@ func CheckRedisClusterState(cr *redisv1beta1.RedisCluster) int {
 
 // configureRedisClient will configure the Redis Client
 func configureRedisClient(cr *redisv1beta1.RedisCluster, podName string) *redis.Client {
-	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
 	redisInfo := RedisDetails{
 		PodName:   podName,
 		Namespace: cr.Namespace,
 	}
-	var client *redis.Client
+	return newRedisClient(redisInfo, cr.Spec.KubernetesConfig.ExistingPasswordSecret, cr.Spec.TLS)
+}
 
-	if cr.Spec.KubernetesConfig.ExistingPasswordSecret != nil {
-		pass, err := getRedisPassword(cr.Namespace, *cr.Spec.KubernetesConfig.ExistingPasswordSecret.Name, *cr.Spec.KubernetesConfig.ExistingPasswordSecret.Key)
+// configureRedisStandaloneClient will configure the Redis Client for a standalone Redis
+func configureRedisStandaloneClient(cr *redisv1beta1.Redis) *redis.Client {
+	redisInfo := RedisDetails{
+		PodName:   cr.ObjectMeta.Name + "-0",
+		Namespace: cr.Namespace,
+	}
+	return newRedisClient(redisInfo, cr.Spec.KubernetesConfig.ExistingPasswordSecret, cr.Spec.TLS)
+}
+
+// newRedisClient will create the Redis Client for a single pod
+func newRedisClient(redisInfo RedisDetails, secret *redisv1beta1.ExistingPasswordSecret, tlsConfig *redisv1beta1.TLSConfig) *redis.Client {
+	logger := generateRedisManagerLogger(redisInfo.Namespace, redisInfo.PodName)
+	var pass string
+	if secret != nil {
+		var err error
+		pass, err = getRedisPassword(redisInfo.Namespace, *secret.Name, *secret.Key)
 		if err != nil {
 			logger.Error(err, "Error in getting redis password")
 		}
-		client = redis.NewClient(&redis.Options{
-			Addr:      getRedisServerIP(redisInfo) + ":6379",
-			Password:  pass,
-			DB:        0,
-			TLSConfig: getRedisTLSConfig(cr, redisInfo),
-		})
-	} else {
-		client = redis.NewClient(&redis.Options{
-			Addr:      getRedisServerIP(redisInfo) + ":6379",
-			Password:  "",
-			DB:        0,
-			TLSConfig: getRedisTLSConfig(cr, redisInfo),
-		})
-	}
-	return client
+	}
+	return redis.NewClient(&redis.Options{
+		Addr:      getRedisServerIP(redisInfo) + ":6379",
+		Password:  pass,
+		DB:        0,
+		TLSConfig: getRedisTLSConfig(redisInfo.Namespace, tlsConfig, redisInfo),
+	})
+}
+
+// getRedisInfo will return the parsed output of INFO for the given section
+func getRedisInfo(client *redis.Client, section string) (map[string]string, error) {
+	output, err := client.Info(section).Result()
+	if err != nil {
+		return nil, err
+	}
+	return parseRedisInfo(output), nil
+}
+
+// parseRedisInfo will parse the "key:value" lines of INFO output
+func parseRedisInfo(output string) map[string]string {
+	info := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) == 2 {
+			info[kv[0]] = kv[1]
+		}
+	}
+	return info
 }
 
 // executeCommand will execute the commands in pod
@@ -344,16 +502,10 @@ func getContainerID(cr *redisv1beta1.RedisCluster, podName string) (int, *corev1
 }
 
 // checkRedisNodePresence will check if the redis node exist in cluster or not
-func checkRedisNodePresence(cr *redisv1beta1.RedisCluster, nodeList [][]string, nodeName string) bool {
+func checkRedisNodePresence(cr *redisv1beta1.RedisCluster, nodeList []ClusterNode, nodeName string) bool {
 	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
 	logger.Info("Checking if Node is in cluster", "Node", nodeName)
-	for _, node := range nodeList {
-		s := strings.Split(node[1], ":")
-		if s[0] == nodeName {
-			return true
-		}
-	}
-	return false
+	return findClusterNodeByIP(nodeList, nodeName) != nil
 }
 
 // generateRedisManagerLogger will generate logging interface for Redis operations
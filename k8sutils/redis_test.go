@@ -2,23 +2,22 @@
 package k8sutils
 
 import (
-	"encoding/csv"
-	"fmt"
 	redisv1beta1 "redis-operator/api/v1beta1"
+	"reflect"
 	"strings"
 	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestCheckRedisNodePresence(t *testing.T) {
 	cr := &redisv1beta1.RedisCluster{}
 	output := "205dd1780dda981f9320c9d47d069b3c0ceaa358 172.17.0.24:6379@16379 slave b65312dcf5537b8826c344783f078096fdb7f27c 0 1654197347000 1 connected\nfaa21623054227826e93dd71314cce3706491dac 172.17.0.28:6379@16379 slave d54557b21bc5a5aa947ce58b7dbadc5d39bdd551 0 1654197347000 2 connected\nb65312dcf5537b8826c344783f078096fdb7f27c 172.17.0.25:6379@16379 master - 0 1654197346000 1 connected 0-5460\nd54557b21bc5a5aa947ce58b7dbadc5d39bdd551 172.17.0.29:6379@16379 myself,master - 0 1654197347000 2 connected 5461-10922\nc9fa05269c4e662295bf34eb93f1315f962493ba 172.17.0.3:6379@16379 master - 0 1654197348006 3 connected 10923-16383"
-	csvOutput := csv.NewReader(strings.NewReader(output))
-	csvOutput.Comma = ' '
-	csvOutput.FieldsPerRecord = -1
-	nodes, _ := csvOutput.ReadAll()
+	nodes, _ := ParseClusterNodes(output)
 
 	var tests = []struct {
-		nodes [][]string
+		nodes []ClusterNode
 		ip    string
 		want  bool
 	}{
@@ -28,8 +27,7 @@ func TestCheckRedisNodePresence(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		testname := fmt.Sprintf("%s,%s", tt.nodes, tt.ip)
-		t.Run(testname, func(t *testing.T) {
+		t.Run(tt.ip, func(t *testing.T) {
 			ans := checkRedisNodePresence(cr, tt.nodes, tt.ip)
 			if ans != tt.want {
 				t.Errorf("got %t, want %t", ans, tt.want)
@@ -37,3 +35,52 @@ func TestCheckRedisNodePresence(t *testing.T) {
 		})
 	}
 }
+
+func TestIsRedisVersionAtLeast(t *testing.T) {
+	var tests = []struct {
+		version string
+		want    bool
+	}{
+		{"6.2.7", false},
+		{"7.0.5", true},
+		{"7.2.0", true},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			if ans := isRedisVersionAtLeast(tt.version, 7); ans != tt.want {
+				t.Errorf("got %t, want %t", ans, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetRedisServerAddressHostname(t *testing.T) {
+	pod := RedisDetails{PodName: "redis-cluster-leader-0", Namespace: "ot-operators"}
+	want := "redis-cluster-leader-0.redis-cluster-leader-headless.ot-operators.svc"
+	if ans := getRedisServerAddress(pod, "redis-cluster-leader", true); ans != want {
+		t.Errorf("got %s, want %s", ans, want)
+	}
+}
+
+func TestRenderHostnameConfigs(t *testing.T) {
+	want := map[string]string{
+		"redis-leader-0": "cluster-announce-hostname redis-leader-0.redis-leader-headless.default.svc\n",
+		"redis-leader-1": "cluster-announce-hostname redis-leader-1.redis-leader-headless.default.svc\n",
+	}
+	if got := renderHostnameConfigs("default", "redis-leader", 2); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestGetGoverningServiceCondition(t *testing.T) {
+	leader := appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "redis-leader"}, Spec: appsv1.StatefulSetSpec{ServiceName: "redis-leader-headless"}}
+	follower := appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "redis-follower"}, Spec: appsv1.StatefulSetSpec{ServiceName: "redis-follower"}}
+	if condition := getGoverningServiceCondition([]appsv1.StatefulSet{leader}); condition.Status != metav1.ConditionTrue {
+		t.Errorf("got %+v", condition)
+	}
+	condition := getGoverningServiceCondition([]appsv1.StatefulSet{leader, follower})
+	if condition.Status != metav1.ConditionFalse || !strings.HasPrefix(condition.Message, "redis-follower keep") {
+		t.Errorf("got %+v", condition)
+	}
+}
@@ -0,0 +1,92 @@
+package k8sutils
+
+import (
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"sort"
+)
+
+// replicaMove reattaches a replica to another master
+type replicaMove struct {
+	Replica string
+	Master  string
+}
+
+// ReconcileRedisClusterReplicas will move replicas between masters until every master has the same number of replicas, it returns the number of replicas moved
+func ReconcileRedisClusterReplicas(cr *redisv1beta1.RedisCluster, topology *ClusterTopology) (int, error) {
+	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
+	if !topology.Formed() {
+		return 0, nil
+	}
+	domains, err := getPodFailureDomains(cr)
+	if err != nil {
+		logger.Error(err, "Could not get the failure domains of the pods")
+	}
+	moves := planReplicaMoves(topology.Nodes, domains)
+	for i, move := range moves {
+		podName := topology.PodNameByID(move.Replica)
+		if podName == "" {
+			return i, nil
+		}
+		client := configureRedisClient(cr, podName)
+		err := client.ClusterReplicate(move.Master).Err()
+		client.Close()
+		if err != nil {
+			return i, err
+		}
+		logger.Info("Replica moved to balance the shards", "Pod", podName, "Master", move.Master)
+	}
+	return len(moves), nil
+}
+
+// planReplicaMoves will plan the replica moves from the masters with the most replicas to the ones with the fewest, a
+// replica is preferably moved to a master outside its failure domain
+func planReplicaMoves(nodes []ClusterNode, domains map[string]string) []replicaMove {
+	var masters []string
+	for _, node := range nodes {
+		if node.IsMaster() && !node.IsFailed() && len(node.Slots) > 0 {
+			masters = append(masters, node.ID)
+		}
+	}
+	if len(masters) == 0 {
+		return nil
+	}
+	sort.Strings(masters)
+	// The moves are applied to a copy of the nodes so the next move counts them
+	current := append([]ClusterNode{}, nodes...)
+
+	var moves []replicaMove
+	for {
+		replicas := map[string][]int{}
+		for i, node := range current {
+			if node.IsReplica() && !node.IsFailed() {
+				replicas[node.MasterID] = append(replicas[node.MasterID], i)
+			}
+		}
+		most, fewest := masters[0], masters[0]
+		for _, master := range masters {
+			if len(replicas[master]) > len(replicas[most]) {
+				most = master
+			}
+			if len(replicas[master]) < len(replicas[fewest]) {
+				fewest = master
+			}
+		}
+		if len(replicas[most])-len(replicas[fewest]) <= 1 {
+			return moves
+		}
+		replica, master := replicas[most][len(replicas[most])-1], fewest
+		for i := len(replicas[most]) - 1; i >= 0; i-- {
+			candidate := current[replicas[most][i]]
+			target := getMasterForReplica(current, domains, candidate.IP)
+			if len(replicas[target]) > len(replicas[most])-2 {
+				continue
+			}
+			if domain := domains[candidate.IP]; domain == "" || domains[findClusterNodeByID(current, target).IP] != domain {
+				replica, master = replicas[most][i], target
+				break
+			}
+		}
+		current[replica].MasterID = master
+		moves = append(moves, replicaMove{Replica: current[replica].ID, Master: master})
+	}
+}
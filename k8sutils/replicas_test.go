@@ -0,0 +1,53 @@
+package k8sutils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlanReplicaMoves(t *testing.T) {
+	nodes := clusterNodesFromOutput(`
+a 10.0.0.1:6379@16379 myself,master - 0 0 1 connected 0-4095
+b 10.0.0.2:6379@16379 master - 0 0 2 connected 4096-8191
+c 10.0.0.3:6379@16379 master - 0 0 3 connected 8192-12287
+d 10.0.0.4:6379@16379 master - 0 0 4 connected 12288-16383
+e 10.0.0.5:6379@16379 slave a 0 0 1 connected
+f 10.0.0.6:6379@16379 slave a 0 0 1 connected
+g 10.0.0.7:6379@16379 slave a 0 0 1 connected
+h 10.0.0.8:6379@16379 slave b 0 0 2 connected
+i 10.0.0.9:6379@16379 slave,fail c 0 0 3 disconnected`)
+	want := []replicaMove{{Replica: "g", Master: "c"}, {Replica: "f", Master: "d"}}
+	if moves := planReplicaMoves(nodes, nil); !reflect.DeepEqual(moves, want) {
+		t.Errorf("got moves %v, want %v", moves, want)
+	}
+
+	balanced := clusterNodesFromOutput(`
+a 10.0.0.1:6379@16379 myself,master - 0 0 1 connected 0-8191
+b 10.0.0.2:6379@16379 master - 0 0 2 connected 8192-16383
+c 10.0.0.3:6379@16379 slave a 0 0 1 connected
+d 10.0.0.4:6379@16379 slave a 0 0 1 connected
+e 10.0.0.5:6379@16379 slave b 0 0 2 connected`)
+	if moves := planReplicaMoves(balanced, nil); len(moves) != 0 {
+		t.Errorf("balanced shards should not move replicas, got %v", moves)
+	}
+}
+
+func TestPlanReplicaMovesFailureDomains(t *testing.T) {
+	nodes := clusterNodesFromOutput(`
+a 10.0.0.1:6379@16379 myself,master - 0 0 1 connected 0-5460
+b 10.0.0.2:6379@16379 master - 0 0 2 connected 5461-10922
+c 10.0.0.3:6379@16379 master - 0 0 3 connected 10923-16383
+d 10.0.0.4:6379@16379 slave a 0 0 1 connected
+e 10.0.0.5:6379@16379 slave a 0 0 1 connected
+f 10.0.0.6:6379@16379 slave a 0 0 1 connected
+g 10.0.0.7:6379@16379 slave b 0 0 2 connected`)
+	domains := map[string]string{
+		"10.0.0.1": "zone-a", "10.0.0.2": "zone-b", "10.0.0.3": "zone-c",
+		"10.0.0.4": "zone-b", "10.0.0.5": "zone-b", "10.0.0.6": "zone-c", "10.0.0.7": "zone-a",
+	}
+	// f shares the zone of c and moves to b, e then fills c
+	want := []replicaMove{{Replica: "f", Master: "b"}, {Replica: "e", Master: "c"}}
+	if moves := planReplicaMoves(nodes, domains); !reflect.DeepEqual(moves, want) {
+		t.Errorf("got moves %v, want %v", moves, want)
+	}
+}
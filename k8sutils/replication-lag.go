@@ -0,0 +1,175 @@
+package k8sutils
+
+import (
+	"fmt"
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// defaultMaxReplicationLag is the lag in bytes allowed when the spec leaves it unset
+const defaultMaxReplicationLag = 1048576
+
+var replicationLagGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "redis_cluster_replication_lag_bytes",
+	Help: "Replication offset in bytes a replica trails its master by",
+}, []string{"namespace", "cluster", "master", "replica"})
+
+func init() {
+	metrics.Registry.MustRegister(replicationLagGauge)
+}
+
+// GetRedisClusterReplicationLag will compare the replication offset of every master with the offsets of its replicas
+// and publish the lag as metric, masters which cannot be read are left out
+func GetRedisClusterReplicationLag(cr *redisv1beta1.RedisCluster, topology *ClusterTopology) []redisv1beta1.ShardReplicationLag {
+	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
+	maxLag := getMaxReplicationLag(cr)
+	var shards []redisv1beta1.ShardReplicationLag
+	lags := map[string]map[string]int64{}
+	for _, node := range topology.Nodes {
+		masterPod := topology.PodName(node)
+		if !node.IsMaster() || node.IsFailed() || masterPod == "" {
+			continue
+		}
+		var replicaPods []string
+		for _, replica := range topology.Nodes {
+			if replicaPod := topology.PodName(replica); replica.IsReplica() && replica.MasterID == node.ID && replicaPod != "" {
+				replicaPods = append(replicaPods, replicaPod)
+			}
+		}
+		client := configureRedisClient(cr, masterPod)
+		info, err := getRedisInfo(client, "replication")
+		client.Close()
+		if err != nil {
+			logger.Error(err, "Could not read the replication offsets", "Pod", masterPod)
+			continue
+		}
+		shard, shardLags := getShardReplicationLag(masterPod, info, replicaPods, topology.PodsByIP, maxLag)
+		shards = append(shards, shard)
+		lags[masterPod] = shardLags
+	}
+	sort.Slice(shards, func(i, j int) bool {
+		return shards[i].Master < shards[j].Master
+	})
+
+	for _, shard := range cr.Status.ReplicationLag {
+		for _, replica := range shard.Replicas {
+			replicationLagGauge.DeleteLabelValues(cr.Namespace, cr.ObjectMeta.Name, shard.Master, replica.Pod)
+		}
+	}
+	for _, shard := range shards {
+		for _, replica := range shard.Replicas {
+			replicationLagGauge.WithLabelValues(cr.Namespace, cr.ObjectMeta.Name, shard.Master, replica.Pod).Set(float64(lags[shard.Master][replica.Pod]))
+		}
+	}
+	return shards
+}
+
+// getShardReplicationLag returns the sync state and the lag in bytes of the replicas of the master from its INFO
+// replication, replicas of the topology missing from the connected replicas of the master trail it by its whole offset
+func getShardReplicationLag(masterPod string, info map[string]string, replicaPods []string, podsByIP map[string]string, maxLag int64) (redisv1beta1.ShardReplicationLag, map[string]int64) {
+	shard := redisv1beta1.ShardReplicationLag{Master: masterPod}
+	masterOffset, _ := strconv.ParseInt(info["master_repl_offset"], 10, 64)
+	connected := map[string]redisv1beta1.ReplicaLag{}
+	lags := map[string]int64{}
+	slaves, _ := strconv.Atoi(info["connected_slaves"])
+	for i := 0; i < slaves; i++ {
+		// slave0:ip=10.0.0.5,port=6379,state=online,offset=1234,lag=0
+		fields := map[string]string{}
+		for _, field := range strings.Split(info[fmt.Sprintf("slave%d", i)], ",") {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) == 2 {
+				fields[kv[0]] = kv[1]
+			}
+		}
+		podName := podsByIP[fields["ip"]]
+		if podName == "" {
+			continue
+		}
+		offset, _ := strconv.ParseInt(fields["offset"], 10, 64)
+		lag := masterOffset - offset
+		if lag < 0 {
+			lag = 0
+		}
+		connected[podName] = redisv1beta1.ReplicaLag{Pod: podName, InSync: fields["state"] == "online" && lag <= maxLag}
+		lags[podName] = lag
+	}
+	for _, podName := range replicaPods {
+		replica, ok := connected[podName]
+		if !ok {
+			replica = redisv1beta1.ReplicaLag{Pod: podName}
+			lags[podName] = masterOffset
+		}
+		shard.Replicas = append(shard.Replicas, replica)
+	}
+	sort.Slice(shard.Replicas, func(i, j int) bool {
+		return shard.Replicas[i].Pod < shard.Replicas[j].Pod
+	})
+	return shard, lags
+}
+
+// ReplicationLagChanged checks if a replica appeared, left, fell behind or caught up, changes of the lag alone
+// are left to the metric so the status is not rewritten on every reconcile
+func ReplicationLagChanged(previous, current []redisv1beta1.ShardReplicationLag) bool {
+	return !reflect.DeepEqual(getReplicaSyncStates(previous), getReplicaSyncStates(current))
+}
+
+// getReplicaSyncStates returns whether the replicas are in sync by shard and replica
+func getReplicaSyncStates(shards []redisv1beta1.ShardReplicationLag) map[string]bool {
+	states := map[string]bool{}
+	for _, shard := range shards {
+		states[shard.Master] = true
+		for _, replica := range shard.Replicas {
+			states[shard.Master+"/"+replica.Pod] = replica.InSync
+		}
+	}
+	return states
+}
+
+// getLaggingReplicas returns the replicas of the master pod trailing it by more than the max lag, as measured
+// at the start of the reconcile, shards without measurement have no lagging replicas
+func getLaggingReplicas(cr *redisv1beta1.RedisCluster, masterPod string) []string {
+	var lagging []string
+	for _, shard := range cr.Status.ReplicationLag {
+		if shard.Master != masterPod {
+			continue
+		}
+		for _, replica := range shard.Replicas {
+			if !replica.InSync {
+				lagging = append(lagging, replica.Pod)
+			}
+		}
+	}
+	return lagging
+}
+
+// isReplicaCaughtUp checks that the replica pod trails its master by no more than the max lag
+func isReplicaCaughtUp(cr *redisv1beta1.RedisCluster, podName string) bool {
+	return isShardReplicaInSync(cr.Status.ReplicationLag, podName)
+}
+
+// isShardReplicaInSync checks that the replica pod is online and trails its master by no more than the max lag, as
+// measured at the start of the reconcile
+func isShardReplicaInSync(shards []redisv1beta1.ShardReplicationLag, podName string) bool {
+	for _, shard := range shards {
+		for _, replica := range shard.Replicas {
+			if replica.Pod == podName {
+				return replica.InSync
+			}
+		}
+	}
+	return false
+}
+
+// getMaxReplicationLag returns the replication lag in bytes the disruptive operations tolerate
+func getMaxReplicationLag(cr *redisv1beta1.RedisCluster) int64 {
+	if cr.Spec.MaxReplicationLag != nil {
+		return *cr.Spec.MaxReplicationLag
+	}
+	return defaultMaxReplicationLag
+}
@@ -0,0 +1,73 @@
+package k8sutils
+
+import (
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"reflect"
+	"testing"
+)
+
+func TestGetShardReplicationLag(t *testing.T) {
+	podsByIP := map[string]string{"10.0.0.4": "redis-follower-0", "10.0.0.5": "redis-follower-1", "10.0.0.6": "redis-follower-2"}
+	info := parseRedisInfo(`# Replication
+role:master
+connected_slaves:2
+slave0:ip=10.0.0.4,port=6379,state=online,offset=9000,lag=0
+slave1:ip=10.0.0.5,port=6379,state=wait_bgsave,offset=0,lag=1
+master_repl_offset:10000
+`)
+	got, lags := getShardReplicationLag("redis-leader-0", info, []string{"redis-follower-2", "redis-follower-1", "redis-follower-0"}, podsByIP, 1024)
+	want := redisv1beta1.ShardReplicationLag{
+		Master: "redis-leader-0",
+		Replicas: []redisv1beta1.ReplicaLag{
+			{Pod: "redis-follower-0", InSync: true},
+			{Pod: "redis-follower-1"},
+			// Not connected to its master
+			{Pod: "redis-follower-2"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	wantLags := map[string]int64{"redis-follower-0": 1000, "redis-follower-1": 10000, "redis-follower-2": 10000}
+	if !reflect.DeepEqual(lags, wantLags) {
+		t.Errorf("got lags %v, want %v", lags, wantLags)
+	}
+
+	got, lags = getShardReplicationLag("redis-leader-0", info, []string{"redis-follower-0"}, podsByIP, 100)
+	if got.Replicas[0].InSync {
+		t.Errorf("replica trailing by %d bytes is in sync with max lag 100", lags["redis-follower-0"])
+	}
+}
+
+func TestReplicationLagChanged(t *testing.T) {
+	previous := []redisv1beta1.ShardReplicationLag{
+		{Master: "redis-leader-0", Replicas: []redisv1beta1.ReplicaLag{{Pod: "redis-follower-0", InSync: true}}},
+	}
+	tests := []struct {
+		name    string
+		current []redisv1beta1.ShardReplicationLag
+		want    bool
+	}{
+		{
+			name:    "replica still in sync",
+			current: []redisv1beta1.ShardReplicationLag{{Master: "redis-leader-0", Replicas: []redisv1beta1.ReplicaLag{{Pod: "redis-follower-0", InSync: true}}}},
+		},
+		{
+			name:    "replica fell behind",
+			current: []redisv1beta1.ShardReplicationLag{{Master: "redis-leader-0", Replicas: []redisv1beta1.ReplicaLag{{Pod: "redis-follower-0"}}}},
+			want:    true,
+		},
+		{
+			name:    "master failed over",
+			current: []redisv1beta1.ShardReplicationLag{{Master: "redis-follower-0", Replicas: []redisv1beta1.ReplicaLag{{Pod: "redis-leader-0", InSync: true}}}},
+			want:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ReplicationLagChanged(previous, tt.current); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
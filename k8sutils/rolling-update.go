@@ -0,0 +1,180 @@
+package k8sutils
+
+import (
+	"context"
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"sort"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	rollingUpdatePhaseUpdating  = "Updating"
+	rollingUpdatePhaseCompleted = "Completed"
+
+	rollingUpdateActionWait     = "Wait"
+	rollingUpdateActionFailover = "Failover"
+	rollingUpdateActionRestart  = "Restart"
+)
+
+// ReconcileRedisClusterRollingUpdate will restart the outdated pods one at a time, followers first, failing masters over to a replica before their restart
+func ReconcileRedisClusterRollingUpdate(cr *redisv1beta1.RedisCluster, topology *ClusterTopology) (*redisv1beta1.RollingUpdateStatus, error) {
+	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
+	status := cr.Status.RollingUpdate.DeepCopy()
+	var outdated []string
+	ready := true
+	for _, role := range []string{"follower", "leader"} {
+		sts, err := GetStatefulSet(cr.Namespace, cr.ObjectMeta.Name+"-"+role)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return status, nil
+			}
+			return status, err
+		}
+		if sts.Spec.UpdateStrategy.Type != appsv1.OnDeleteStatefulSetStrategyType {
+			return status, nil
+		}
+		pods, err := getOutdatedPods(sts)
+		if err != nil {
+			return status, err
+		}
+		outdated = append(outdated, pods...)
+		ready = ready && sts.Status.ReadyReplicas == *sts.Spec.Replicas
+	}
+	if len(outdated) == 0 {
+		if status != nil && status.Phase == rollingUpdatePhaseUpdating {
+			logger.Info("Rolling update of the cluster pods is completed")
+			status = &redisv1beta1.RollingUpdateStatus{Phase: rollingUpdatePhaseCompleted}
+		}
+		return status, nil
+	}
+	if status == nil || status.Phase != rollingUpdatePhaseUpdating {
+		status = &redisv1beta1.RollingUpdateStatus{Phase: rollingUpdatePhaseUpdating}
+	}
+	status.PodsPending = int32(len(outdated))
+
+	step := planRollingUpdateStep(topology, outdated, ready, cr.Status.ReplicationLag)
+	if step.Pod != "" {
+		status.Pod = step.Pod
+	}
+	switch step.Action {
+	case rollingUpdateActionWait:
+		logger.Info(step.Reason, "Pod", status.Pod)
+	case rollingUpdateActionFailover:
+		client := configureRedisClient(cr, step.Replica)
+		err := client.Do("cluster", "failover").Err()
+		client.Close()
+		if err != nil {
+			return status, err
+		}
+		logger.Info("Failing master over to its replica before the restart", "Pod", step.Pod, "Replica", step.Replica)
+	case rollingUpdateActionRestart:
+		if step.Reason != "" {
+			logger.Info(step.Reason, "Pod", step.Pod)
+		}
+		logger.Info("Restarting outdated pod", "Pod", step.Pod, "Pods.Pending", len(outdated))
+		err := generateK8sClient().CoreV1().Pods(cr.Namespace).Delete(context.TODO(), step.Pod, metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return status, err
+		}
+	}
+	return status, nil
+}
+
+// rollingUpdateStep is the next action of a rolling update on one pod
+type rollingUpdateStep struct {
+	Action string
+	Pod    string
+	// Replica takes over the slots of the master pod on a failover
+	Replica string
+	Reason  string
+}
+
+// planRollingUpdateStep will pick the next outdated pod, followers first and highest ordinal first, and decide whether
+// it is restarted, its master role failed over first or the rolling update waits
+func planRollingUpdateStep(topology *ClusterTopology, outdated []string, ready bool, shards []redisv1beta1.ShardReplicationLag) rollingUpdateStep {
+	if !ready {
+		return rollingUpdateStep{Action: rollingUpdateActionWait, Reason: "Waiting for the cluster pods to become ready before the next restart"}
+	}
+	pods := append([]string{}, outdated...)
+	sort.SliceStable(pods, func(i, j int) bool {
+		iLeader, jLeader := strings.Contains(pods[i], "-leader-"), strings.Contains(pods[j], "-leader-")
+		if iLeader != jLeader {
+			return jLeader
+		}
+		return getPodOrdinal(pods[i]) > getPodOrdinal(pods[j])
+	})
+	podName := pods[0]
+	if !topology.Formed() {
+		return rollingUpdateStep{Action: rollingUpdateActionRestart, Pod: podName}
+	}
+	for _, node := range topology.Nodes {
+		if node.IsFailed() {
+			return rollingUpdateStep{Action: rollingUpdateActionWait, Pod: podName, Reason: "Waiting for the failed cluster nodes to recover before the next restart"}
+		}
+	}
+	node := findClusterNodeByIP(topology.Nodes, topology.PodIP(podName))
+	if node != nil && node.IsMaster() && len(node.Slots) > 0 {
+		for _, shard := range shards {
+			for _, replica := range shard.Replicas {
+				if shard.Master == podName && !replica.InSync {
+					return rollingUpdateStep{Action: rollingUpdateActionWait, Pod: podName, Reason: "Waiting for the replicas to catch up before the master restart"}
+				}
+			}
+		}
+		if replica := getHealthyReplicaPod(topology.Nodes, node.ID, topology.PodsByIP); replica != "" {
+			return rollingUpdateStep{Action: rollingUpdateActionFailover, Pod: podName, Replica: replica}
+		}
+		return rollingUpdateStep{Action: rollingUpdateActionRestart, Pod: podName, Reason: "Master has no healthy replica, restarting it without failover"}
+	}
+	if node != nil && node.IsReplica() && !isShardReplicaInSync(shards, podName) {
+		return rollingUpdateStep{Action: rollingUpdateActionWait, Pod: podName, Reason: "Waiting for the replica to sync before the restart"}
+	}
+	return rollingUpdateStep{Action: rollingUpdateActionRestart, Pod: podName}
+}
+
+// getPodOrdinal returns the statefulset ordinal of a pod name
+func getPodOrdinal(podName string) int {
+	ordinal, err := strconv.Atoi(podName[strings.LastIndex(podName, "-")+1:])
+	if err != nil {
+		return -1
+	}
+	return ordinal
+}
+
+// getOutdatedPods returns the pods of the statefulset not running its update revision
+func getOutdatedPods(sts *appsv1.StatefulSet) ([]string, error) {
+	var outdated []string
+	if sts.Status.UpdateRevision == "" {
+		return nil, nil
+	}
+	for ordinal := int(*sts.Spec.Replicas) - 1; ordinal >= 0; ordinal-- {
+		podName := sts.Name + "-" + strconv.Itoa(ordinal)
+		pod, err := generateK8sClient().CoreV1().Pods(sts.Namespace).Get(context.TODO(), podName, metav1.GetOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		if pod.Labels[appsv1.ControllerRevisionHashLabelKey] != sts.Status.UpdateRevision {
+			outdated = append(outdated, podName)
+		}
+	}
+	return outdated, nil
+}
+
+// isReplicaInSync checks that the pod replicates from a master with its link up and no sync in progress
+func isReplicaInSync(cr *redisv1beta1.RedisCluster, podName string) bool {
+	client := configureRedisClient(cr, podName)
+	defer client.Close()
+	info, err := getRedisInfo(client, "replication")
+	if err != nil {
+		return false
+	}
+	return info["role"] == "slave" && info["master_link_status"] == "up" && info["master_sync_in_progress"] == "0"
+}
@@ -0,0 +1,84 @@
+package k8sutils
+
+import (
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGenerateStatefulSetsDefUpdateStrategy(t *testing.T) {
+	partition := int32(2)
+	containerParams := containerParameters{ReadinessProbe: &redisv1beta1.Probe{}, LivenessProbe: &redisv1beta1.Probe{}}
+	var tests = []struct {
+		name   string
+		params statefulSetParameters
+		want   appsv1.StatefulSetUpdateStrategyType
+	}{
+		{"default", statefulSetParameters{}, ""},
+		{"on-delete", statefulSetParameters{OnDeleteUpdates: true}, appsv1.OnDeleteStatefulSetStrategyType},
+		{"migration", statefulSetParameters{OnDeleteUpdates: true, Partition: &partition}, appsv1.RollingUpdateStatefulSetStrategyType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sts := generateStatefulSetsDef(metav1.ObjectMeta{Name: "redis-cluster-leader"}, tt.params, metav1.OwnerReference{}, containerParams, nil)
+			if sts.Spec.UpdateStrategy.Type != tt.want {
+				t.Errorf("got update strategy %q, want %q", sts.Spec.UpdateStrategy.Type, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanRollingUpdateStep(t *testing.T) {
+	formed := &ClusterTopology{
+		Nodes: clusterNodesFromOutput(`
+a 10.0.0.1:6379@16379 myself,master - 0 0 1 connected 0-8191
+b 10.0.0.2:6379@16379 master - 0 0 2 connected 8192-16383
+c 10.0.0.3:6379@16379 slave a 0 0 1 connected
+d 10.0.0.4:6379@16379 slave b 0 0 2 connected`),
+		PodsByIP: map[string]string{
+			"10.0.0.1": "redis-leader-0",
+			"10.0.0.2": "redis-leader-1",
+			"10.0.0.3": "redis-follower-0",
+			"10.0.0.4": "redis-follower-1",
+		},
+	}
+	failed := &ClusterTopology{
+		Nodes:    append(append([]ClusterNode{}, formed.Nodes...), clusterNodesFromOutput("e :0@0 slave,fail,noaddr a 0 0 1 disconnected")...),
+		PodsByIP: formed.PodsByIP,
+	}
+	synced := []redisv1beta1.ShardReplicationLag{
+		{Master: "redis-leader-0", Replicas: []redisv1beta1.ReplicaLag{{Pod: "redis-follower-0", InSync: true}}},
+		{Master: "redis-leader-1", Replicas: []redisv1beta1.ReplicaLag{{Pod: "redis-follower-1", InSync: true}}},
+	}
+	lagging := []redisv1beta1.ShardReplicationLag{
+		{Master: "redis-leader-0", Replicas: []redisv1beta1.ReplicaLag{{Pod: "redis-follower-0", InSync: true}}},
+		{Master: "redis-leader-1", Replicas: []redisv1beta1.ReplicaLag{{Pod: "redis-follower-1"}}},
+	}
+	allPods := []string{"redis-leader-0", "redis-leader-1", "redis-follower-0", "redis-follower-1"}
+
+	var tests = []struct {
+		name     string
+		topology *ClusterTopology
+		outdated []string
+		ready    bool
+		shards   []redisv1beta1.ShardReplicationLag
+		want     rollingUpdateStep
+	}{
+		{"not ready", formed, allPods, false, synced, rollingUpdateStep{Action: rollingUpdateActionWait, Reason: "Waiting for the cluster pods to become ready before the next restart"}},
+		{"followers first highest ordinal first", formed, allPods, true, synced, rollingUpdateStep{Action: rollingUpdateActionRestart, Pod: "redis-follower-1"}},
+		{"replica sync gate", formed, []string{"redis-leader-0", "redis-follower-1"}, true, lagging, rollingUpdateStep{Action: rollingUpdateActionWait, Pod: "redis-follower-1", Reason: "Waiting for the replica to sync before the restart"}},
+		{"failover before delete", formed, []string{"redis-leader-0", "redis-leader-1"}, true, synced, rollingUpdateStep{Action: rollingUpdateActionFailover, Pod: "redis-leader-1", Replica: "redis-follower-1"}},
+		{"master lag gate", formed, []string{"redis-leader-1"}, true, lagging, rollingUpdateStep{Action: rollingUpdateActionWait, Pod: "redis-leader-1", Reason: "Waiting for the replicas to catch up before the master restart"}},
+		{"pause on failed nodes", failed, allPods, true, synced, rollingUpdateStep{Action: rollingUpdateActionWait, Pod: "redis-follower-1", Reason: "Waiting for the failed cluster nodes to recover before the next restart"}},
+		{"cluster not formed", &ClusterTopology{}, []string{"redis-leader-0", "redis-leader-2"}, true, nil, rollingUpdateStep{Action: rollingUpdateActionRestart, Pod: "redis-leader-2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := planRollingUpdateStep(tt.topology, tt.outdated, tt.ready, tt.shards); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package k8sutils
+
+import (
+	"fmt"
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	scaleInPhaseDryRun    = "DryRun"
+	scaleInPhaseDraining  = "Draining"
+	scaleInPhaseCompleted = "Completed"
+)
+
+// ReconcileRedisClusterScaleIn will drain the leaders removed by a scale-in before the statefulset shrinks, a paused
+// scale-in only holds the statefulset at its current size
+func ReconcileRedisClusterScaleIn(cr *redisv1beta1.RedisCluster, topology *ClusterTopology, paused bool) (*redisv1beta1.ScaleInStatus, error) {
+	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
+	status := cr.Status.ScaleIn.DeepCopy()
+	desired := cr.Spec.GetReplicaCounts("leader")
+	if status == nil || status.Phase == scaleInPhaseCompleted {
+		sts, err := GetStatefulSet(cr.Namespace, cr.ObjectMeta.Name+"-leader")
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return status, nil
+			}
+			return status, err
+		}
+		if sts.Spec.Replicas == nil || *sts.Spec.Replicas <= desired {
+			return status, nil
+		}
+		status = &redisv1beta1.ScaleInStatus{Phase: scaleInPhaseDraining, FromReplicas: *sts.Spec.Replicas, ToReplicas: desired}
+		followerSts, err := GetStatefulSet(cr.Namespace, cr.ObjectMeta.Name+"-follower")
+		if err != nil && !errors.IsNotFound(err) {
+			return cr.Status.ScaleIn, err
+		}
+		if err == nil && followerSts.Spec.Replicas != nil {
+			status.FromFollowerReplicas = *followerSts.Spec.Replicas
+		}
+	}
+	if desired >= status.FromReplicas {
+		// The scale-in was reverted, the remaining rebalance is left to the scale-out
+		status.Phase = scaleInPhaseCompleted
+		status.ToReplicas = desired
+		status.Plan = nil
+		return status, nil
+	}
+	status.ToReplicas = desired
+	if paused {
+		// The leaders keep their replicas while no slot can be moved off them
+		logger.Info("Scale-in waits for the cluster topology to be consistent", "From.Replicas", status.FromReplicas, "To.Replicas", status.ToReplicas)
+		return status, nil
+	}
+
+	removed := map[string]bool{}
+	for ordinal := status.ToReplicas; ordinal < status.FromReplicas; ordinal++ {
+		removed[cr.ObjectMeta.Name+"-leader-"+strconv.Itoa(int(ordinal))] = true
+	}
+	removedFollowers := map[string]bool{}
+	for ordinal := cr.Spec.GetReplicaCounts("follower"); ordinal < status.FromFollowerReplicas; ordinal++ {
+		removedFollowers[cr.ObjectMeta.Name+"-follower-"+strconv.Itoa(int(ordinal))] = true
+	}
+	if !topology.Formed() {
+		logger.Info("Cluster owns no slots, the statefulset is scaled in directly")
+		status.Phase = scaleInPhaseCompleted
+		return status, nil
+	}
+
+	masters := collectClusterMasters(topology, cr.ObjectMeta.Name+"-leader-")
+	for i := range masters {
+		masters[i].Draining = removed[masters[i].PodName]
+	}
+	if err := applyShardSlots(cr, masters, topology); err != nil {
+		return status, err
+	}
+	myselfNodes, err := getMasterSelfViews(cr, topology)
+	if err != nil {
+		return status, err
+	}
+	moves := getOpenSlotMoves(myselfNodes)
+	if len(moves) == 0 {
+		moves = planSlotMoves(masters)
+	}
+	status.Plan = summarizeSlotMoves(moves, masters)
+	if cr.Spec.RedisLeader.ScaleInDryRun {
+		status.Phase = scaleInPhaseDryRun
+		return status, nil
+	}
+	status.Phase = scaleInPhaseDraining
+	if len(moves) > 0 {
+		moved, err := executeSlotMoves(cr, moves, masters, getRedisClusterPassword(cr))
+		logger.Info("Draining slots of removed leaders", "SlotsMoved", moved, "SlotsPending", len(moves)-moved)
+		return status, err
+	}
+
+	if err := detachRemovedNodes(cr, topology, removed, removedFollowers); err != nil {
+		return status, err
+	}
+	logger.Info("Removed leaders are drained and forgotten, scaling in the statefulset", "Replicas", status.ToReplicas)
+	status.Phase = scaleInPhaseCompleted
+	status.Plan = nil
+	return status, nil
+}
+
+// detachRemovedNodes will move the replicas of the removed masters, reset the removed leaders and followers and forget
+// them on the cluster
+func detachRemovedNodes(cr *redisv1beta1.RedisCluster, topology *ClusterTopology, removed, removedFollowers map[string]bool) error {
+	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
+	moves, err := planDetachedReplicaMoves(topology, removed, removedFollowers)
+	if err != nil {
+		return err
+	}
+	for _, move := range moves {
+		podName := topology.PodNameByID(move.Replica)
+		client := configureRedisClient(cr, podName)
+		err := client.ClusterReplicate(move.Master).Err()
+		client.Close()
+		if err != nil {
+			return err
+		}
+		logger.Info("Replica of a removed leader moved", "Pod", podName, "Master", move.Master)
+	}
+
+	var removedIDs []string
+	for _, node := range topology.Nodes {
+		podName := topology.PodName(node)
+		if !removed[podName] && !removedFollowers[podName] {
+			continue
+		}
+		client := configureRedisClient(cr, podName)
+		err := client.ClusterResetSoft().Err()
+		client.Close()
+		if err != nil {
+			logger.Error(err, "Could not reset removed node", "Pod", podName)
+			return err
+		}
+		removedIDs = append(removedIDs, node.ID)
+	}
+	if len(removedIDs) == 0 {
+		return nil
+	}
+	if err := forgetClusterNodes(cr, getReadyClusterPods(cr), removedIDs); err != nil {
+		return err
+	}
+	logger.Info("Removed nodes forgotten by the cluster", "Nodes", removedIDs)
+	return nil
+}
+
+// planDetachedReplicaMoves will plan the moves of the kept replicas of removed masters onto the remaining masters with
+// the fewest replicas, replicas removed themselves are left in place
+func planDetachedReplicaMoves(topology *ClusterTopology, removed, removedFollowers map[string]bool) ([]replicaMove, error) {
+	removedIDs := map[string]bool{}
+	var remaining []ClusterNode
+	for _, node := range topology.Nodes {
+		podName := topology.PodName(node)
+		if removed[podName] || removedFollowers[podName] {
+			removedIDs[node.ID] = true
+		} else {
+			remaining = append(remaining, node)
+		}
+	}
+
+	var moves []replicaMove
+	for i, node := range remaining {
+		if !node.IsReplica() || !removedIDs[node.MasterID] || topology.PodName(node) == "" {
+			continue
+		}
+		master := getMasterWithFewestReplicas(remaining)
+		if master == "" {
+			return nil, fmt.Errorf("no remaining master found for the replica %s", topology.PodName(node))
+		}
+		// Count the moved replica so the next one goes to another master
+		remaining[i].MasterID = master
+		moves = append(moves, replicaMove{Replica: node.ID, Master: master})
+	}
+	return moves, nil
+}
+
+// summarizeSlotMoves will group the slot moves by source and target pod into slot ranges
+func summarizeSlotMoves(moves []slotMove, masters []clusterMaster) []redisv1beta1.SlotMigration {
+	podNames := map[string]string{}
+	for _, master := range masters {
+		podNames[master.ID] = master.PodName
+	}
+	grouped := map[[2]string][]int{}
+	var keys [][2]string
+	for _, move := range moves {
+		key := [2]string{podNames[move.From], podNames[move.To]}
+		if _, ok := grouped[key]; !ok {
+			keys = append(keys, key)
+		}
+		grouped[key] = append(grouped[key], move.Slot)
+	}
+	var migrations []redisv1beta1.SlotMigration
+	for _, key := range keys {
+		migrations = append(migrations, redisv1beta1.SlotMigration{From: key[0], To: key[1], Slots: formatSlotRanges(grouped[key])})
+	}
+	return migrations
+}
+
+// formatSlotRanges will format slots as comma separated ranges
+func formatSlotRanges(slots []int) string {
+	sorted := append([]int{}, slots...)
+	sort.Ints(sorted)
+	var ranges []string
+	for i := 0; i < len(sorted); {
+		j := i
+		for j+1 < len(sorted) && sorted[j+1] == sorted[j]+1 {
+			j++
+		}
+		if i == j {
+			ranges = append(ranges, strconv.Itoa(sorted[i]))
+		} else {
+			ranges = append(ranges, strconv.Itoa(sorted[i])+"-"+strconv.Itoa(sorted[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(ranges, ",")
+}
@@ -0,0 +1,86 @@
+package k8sutils
+
+import (
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"reflect"
+	"testing"
+)
+
+func TestPlanSlotMovesDraining(t *testing.T) {
+	masters := []clusterMaster{
+		{ID: "a", PodName: "redis-leader-0", Slots: slotRange(0, 4095)},
+		{ID: "b", PodName: "redis-leader-1", Slots: slotRange(4096, 8191)},
+		{ID: "c", PodName: "redis-leader-2", Slots: slotRange(8192, 12287)},
+		{ID: "d", PodName: "redis-leader-3", Slots: slotRange(12288, 16383), Draining: true},
+	}
+	moves := planSlotMoves(masters)
+	if len(moves) != 4096 {
+		t.Fatalf("got %d moves, want 4096", len(moves))
+	}
+	for _, move := range moves {
+		if move.From != "d" {
+			t.Fatalf("slot %d moved from %s, want d", move.Slot, move.From)
+		}
+	}
+
+	want := []redisv1beta1.SlotMigration{
+		{From: "redis-leader-3", To: "redis-leader-0", Slots: "12288-13653"},
+		{From: "redis-leader-3", To: "redis-leader-1", Slots: "13654-15018"},
+		{From: "redis-leader-3", To: "redis-leader-2", Slots: "15019-16383"},
+	}
+	if plan := summarizeSlotMoves(moves, masters); !reflect.DeepEqual(plan, want) {
+		t.Errorf("got plan %v, want %v", plan, want)
+	}
+}
+
+func TestFormatSlotRanges(t *testing.T) {
+	var tests = []struct {
+		slots []int
+		want  string
+	}{
+		{nil, ""},
+		{[]int{5}, "5"},
+		{[]int{3, 1, 2, 7, 9, 8}, "1-3,7-9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if ans := formatSlotRanges(tt.slots); ans != tt.want {
+				t.Errorf("got %s, want %s", ans, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanDetachedReplicaMoves(t *testing.T) {
+	output := "aaaa000000000000000000000000000000000001 10.0.0.1:6379@16379 myself,master - 0 1 1 connected 0-8191\n" +
+		"aaaa000000000000000000000000000000000002 10.0.0.2:6379@16379 master - 0 1 2 connected 8192-16383\n" +
+		"aaaa000000000000000000000000000000000003 10.0.0.3:6379@16379 master - 0 1 3 connected\n" +
+		"aaaa000000000000000000000000000000000004 10.0.0.4:6379@16379 slave aaaa000000000000000000000000000000000003 0 1 3 connected\n" +
+		"aaaa000000000000000000000000000000000005 10.0.0.5:6379@16379 slave aaaa000000000000000000000000000000000003 0 1 3 connected\n" +
+		"aaaa000000000000000000000000000000000006 10.0.0.6:6379@16379 slave aaaa000000000000000000000000000000000003 0 1 3 connected\n"
+	topology := &ClusterTopology{
+		Nodes: clusterNodesFromOutput(output),
+		PodsByIP: map[string]string{
+			"10.0.0.1": "redis-leader-0",
+			"10.0.0.2": "redis-leader-1",
+			"10.0.0.3": "redis-leader-2",
+			"10.0.0.4": "redis-follower-0",
+			"10.0.0.5": "redis-follower-1",
+			"10.0.0.6": "redis-follower-2",
+		},
+	}
+	removed := map[string]bool{"redis-leader-2": true}
+	removedFollowers := map[string]bool{"redis-follower-2": true}
+
+	moves, err := planDetachedReplicaMoves(topology, removed, removedFollowers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []replicaMove{
+		{Replica: "aaaa000000000000000000000000000000000004", Master: "aaaa000000000000000000000000000000000001"},
+		{Replica: "aaaa000000000000000000000000000000000005", Master: "aaaa000000000000000000000000000000000002"},
+	}
+	if !reflect.DeepEqual(moves, want) {
+		t.Errorf("got %v, want %v", moves, want)
+	}
+}
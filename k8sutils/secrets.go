@@ -35,10 +35,10 @@ func secretLogger(namespace string, name string) logr.Logger {
 	return reqLogger
 }
 
-func getRedisTLSConfig(cr *redisv1beta1.RedisCluster, redisInfo RedisDetails) *tls.Config {
-	if cr.Spec.TLS != nil {
-		reqLogger := log.WithValues("Request.Namespace", cr.Namespace, "Request.Name", cr.ObjectMeta.Name)
-		secretName, err := generateK8sClient().CoreV1().Secrets(cr.Namespace).Get(context.TODO(), cr.Spec.TLS.Secret.SecretName, metav1.GetOptions{})
+func getRedisTLSConfig(namespace string, tlsConfig *redisv1beta1.TLSConfig, redisInfo RedisDetails) *tls.Config {
+	if tlsConfig != nil {
+		reqLogger := log.WithValues("Request.Namespace", namespace, "Request.Name", redisInfo.PodName)
+		secretName, err := generateK8sClient().CoreV1().Secrets(namespace).Get(context.TODO(), tlsConfig.Secret.SecretName, metav1.GetOptions{})
 		if err != nil {
 			reqLogger.Error(err, "Failed in getting TLS secret for redis")
 		}
@@ -51,11 +51,11 @@ func getRedisTLSConfig(cr *redisv1beta1.RedisCluster, redisInfo RedisDetails) *t
 			tlsClientCertificates []tls.Certificate
 		)
 		for key, value := range secretName.Data {
-			if key == cr.Spec.TLS.CaKeyFile || key == "ca.crt" {
+			if key == tlsConfig.CaKeyFile || key == "ca.crt" {
 				tlsCaCertificate = value
-			} else if key == cr.Spec.TLS.CertKeyFile || key == "tls.crt" {
+			} else if key == tlsConfig.CertKeyFile || key == "tls.crt" {
 				tlsClientCert = value
-			} else if key == cr.Spec.TLS.KeyFile || key == "tls.key" {
+			} else if key == tlsConfig.KeyFile || key == "tls.key" {
 				tlsClientKey = value
 			}
 		}
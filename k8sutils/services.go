@@ -2,6 +2,8 @@ package k8sutils
 
 import (
 	"context"
+	"encoding/json"
+	redisv1beta1 "redis-operator/api/v1beta1"
 
 	"github.com/banzaicloud/k8s-objectmatcher/patch"
 	"github.com/go-logr/logr"
@@ -119,11 +121,53 @@ func serviceLogger(namespace string, name string) logr.Logger {
 	return reqLogger
 }
 
+// applyServiceConfig will customize the service with the service block of the custom resource, the selector is kept
+func applyServiceConfig(service *corev1.Service, serviceConfig *redisv1beta1.ServiceConfig) {
+	if serviceConfig == nil {
+		return
+	}
+	service.Spec.Type = generateServiceType(serviceConfig.Type)
+	labels := map[string]string{}
+	for key, value := range service.Labels {
+		labels[key] = value
+	}
+	for key, value := range serviceConfig.Labels {
+		if _, ok := labels[key]; !ok {
+			labels[key] = value
+		}
+	}
+	service.Labels = labels
+	annotations := map[string]string{}
+	for key, value := range service.Annotations {
+		annotations[key] = value
+	}
+	for key, value := range serviceConfig.Annotations {
+		annotations[key] = value
+	}
+	service.Annotations = annotations
+	if service.Spec.Type == corev1.ServiceTypeLoadBalancer {
+		service.Spec.LoadBalancerSourceRanges = serviceConfig.LoadBalancerSourceRanges
+	}
+	if service.Spec.Type != corev1.ServiceTypeClusterIP {
+		service.Spec.ExternalTrafficPolicy = serviceConfig.ExternalTrafficPolicy
+	}
+	service.Spec.SessionAffinity = serviceConfig.SessionAffinity
+	service.Spec.Ports = append(service.Spec.Ports, serviceConfig.AdditionalPorts...)
+}
+
 // CreateOrUpdateService method will create or update Redis service
-func CreateOrUpdateService(namespace string, serviceMeta metav1.ObjectMeta, ownerDef metav1.OwnerReference, enableMetrics, headless bool) error {
-	logger := serviceLogger(namespace, serviceMeta.Name)
+func CreateOrUpdateService(namespace string, serviceMeta metav1.ObjectMeta, ownerDef metav1.OwnerReference, enableMetrics, headless bool, serviceConfig *redisv1beta1.ServiceConfig) error {
 	serviceDef := generateServiceDef(serviceMeta, enableMetrics, ownerDef, headless)
-	storedService, err := getService(namespace, serviceMeta.Name)
+	if !headless {
+		applyServiceConfig(serviceDef, serviceConfig)
+	}
+	return createOrPatchService(namespace, serviceDef)
+}
+
+// createOrPatchService will create the service or patch the stored one
+func createOrPatchService(namespace string, serviceDef *corev1.Service) error {
+	logger := serviceLogger(namespace, serviceDef.Name)
+	storedService, err := getService(namespace, serviceDef.Name)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			if err := patch.DefaultAnnotator.SetLastAppliedAnnotation(serviceDef); err != nil {
@@ -144,9 +188,10 @@ func patchService(storedService *corev1.Service, newService *corev1.Service, nam
 	newService.CreationTimestamp = storedService.CreationTimestamp
 	newService.ManagedFields = storedService.ManagedFields
 
-	if newService.Spec.Type == generateServiceType("ClusterIP") {
+	if newService.Spec.ClusterIP == "" {
 		newService.Spec.ClusterIP = storedService.Spec.ClusterIP
 	}
+	keepAllocatedFields(storedService, newService)
 
 	patchResult, err := patch.DefaultPatchMaker.Calculate(storedService, newService,
 		patch.IgnoreStatusFields(),
@@ -160,11 +205,15 @@ func patchService(storedService *corev1.Service, newService *corev1.Service, nam
 	if !patchResult.IsEmpty() {
 		logger.Info("Changes in service Detected, Updating...", "patch", string(patchResult.Patch))
 
-		for key, value := range storedService.Annotations {
-			if _, present := newService.Annotations[key]; !present {
-				newService.Annotations[key] = value
+		// Annotations and labels set by others are kept, the ones removed from the custom resource are dropped
+		applied := &corev1.Service{}
+		if original, err := patch.DefaultAnnotator.GetOriginalConfiguration(storedService); err == nil && original != nil {
+			if err := json.Unmarshal(original, applied); err != nil {
+				logger.Error(err, "Unable to read the last applied redis service")
 			}
 		}
+		newService.Annotations = keepForeignEntries(storedService.Annotations, newService.Annotations, applied.Annotations)
+		newService.Labels = keepForeignEntries(storedService.Labels, newService.Labels, applied.Labels)
 		if err := patch.DefaultAnnotator.SetLastAppliedAnnotation(newService); err != nil {
 			logger.Error(err, "Unable to patch redis service with comparison object")
 			return err
@@ -175,3 +224,34 @@ func patchService(storedService *corev1.Service, newService *corev1.Service, nam
 	logger.Info("Redis service is already in-sync")
 	return nil
 }
+
+// keepAllocatedFields will copy the node ports and the health check node port allocated by Kubernetes to the new service
+func keepAllocatedFields(storedService *corev1.Service, newService *corev1.Service) {
+	if newService.Spec.Type == corev1.ServiceTypeClusterIP {
+		return
+	}
+	for i, port := range newService.Spec.Ports {
+		for _, storedPort := range storedService.Spec.Ports {
+			if port.NodePort == 0 && port.Name == storedPort.Name {
+				newService.Spec.Ports[i].NodePort = storedPort.NodePort
+			}
+		}
+	}
+	if newService.Spec.Type == corev1.ServiceTypeLoadBalancer && newService.Spec.ExternalTrafficPolicy == corev1.ServiceExternalTrafficPolicyTypeLocal && newService.Spec.HealthCheckNodePort == 0 {
+		newService.Spec.HealthCheckNodePort = storedService.Spec.HealthCheckNodePort
+	}
+}
+
+// keepForeignEntries returns the desired entries plus the stored entries the operator did not apply before
+func keepForeignEntries(stored, desired, applied map[string]string) map[string]string {
+	entries := map[string]string{}
+	for key, value := range stored {
+		if _, ok := applied[key]; !ok {
+			entries[key] = value
+		}
+	}
+	for key, value := range desired {
+		entries[key] = value
+	}
+	return entries
+}
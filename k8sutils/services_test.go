@@ -0,0 +1,86 @@
+package k8sutils
+
+import (
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestApplyServiceConfig(t *testing.T) {
+	labels := map[string]string{"app": "redis-leader", "redis_setup_type": "cluster", "role": "leader"}
+	service := generateServiceDef(generateObjectMetaInformation("redis-leader", "default", labels, map[string]string{"redis.opstreelabs.in": "true"}), false, metav1.OwnerReference{}, false)
+	applyServiceConfig(service, &redisv1beta1.ServiceConfig{
+		Type:                     "LoadBalancer",
+		Annotations:              map[string]string{"service.beta.kubernetes.io/aws-load-balancer-internal": "true"},
+		Labels:                   map[string]string{"team": "cache", "app": "other"},
+		LoadBalancerSourceRanges: []string{"10.0.0.0/8"},
+		ExternalTrafficPolicy:    corev1.ServiceExternalTrafficPolicyTypeLocal,
+		SessionAffinity:          corev1.ServiceAffinityClientIP,
+		AdditionalPorts:          []corev1.ServicePort{{Name: "sentinel", Port: 26379}},
+	})
+
+	if service.Spec.Type != corev1.ServiceTypeLoadBalancer || service.Spec.ExternalTrafficPolicy != corev1.ServiceExternalTrafficPolicyTypeLocal || service.Spec.SessionAffinity != corev1.ServiceAffinityClientIP {
+		t.Errorf("got spec %+v", service.Spec)
+	}
+	if !reflect.DeepEqual(service.Spec.LoadBalancerSourceRanges, []string{"10.0.0.0/8"}) {
+		t.Errorf("got source ranges %v", service.Spec.LoadBalancerSourceRanges)
+	}
+	if want := map[string]string{"app": "redis-leader", "redis_setup_type": "cluster", "role": "leader"}; !reflect.DeepEqual(service.Spec.Selector, want) {
+		t.Errorf("got selector %v, want %v", service.Spec.Selector, want)
+	}
+	if service.Labels["team"] != "cache" || service.Labels["app"] != "redis-leader" {
+		t.Errorf("got labels %v", service.Labels)
+	}
+	if labels["team"] != "" {
+		t.Errorf("labels shared with the headless service were modified: %v", labels)
+	}
+	if service.Annotations["service.beta.kubernetes.io/aws-load-balancer-internal"] != "true" || service.Annotations["redis.opstreelabs.in"] != "true" {
+		t.Errorf("got annotations %v", service.Annotations)
+	}
+	if len(service.Spec.Ports) != 2 || service.Spec.Ports[1].Name != "sentinel" {
+		t.Errorf("got ports %v", service.Spec.Ports)
+	}
+
+	clusterIP := generateServiceDef(generateObjectMetaInformation("redis", "default", labels, nil), false, metav1.OwnerReference{}, false)
+	applyServiceConfig(clusterIP, &redisv1beta1.ServiceConfig{ExternalTrafficPolicy: corev1.ServiceExternalTrafficPolicyTypeLocal, LoadBalancerSourceRanges: []string{"10.0.0.0/8"}})
+	if clusterIP.Spec.Type != corev1.ServiceTypeClusterIP || clusterIP.Spec.ExternalTrafficPolicy != "" || clusterIP.Spec.LoadBalancerSourceRanges != nil {
+		t.Errorf("ClusterIP service got %+v", clusterIP.Spec)
+	}
+}
+
+func TestKeepAllocatedFields(t *testing.T) {
+	stored := &corev1.Service{Spec: corev1.ServiceSpec{
+		Type:                  corev1.ServiceTypeLoadBalancer,
+		ExternalTrafficPolicy: corev1.ServiceExternalTrafficPolicyTypeLocal,
+		HealthCheckNodePort:   32000,
+		Ports:                 []corev1.ServicePort{{Name: "redis-client", Port: 6379, NodePort: 31001}},
+	}}
+	desired := &corev1.Service{Spec: corev1.ServiceSpec{
+		Type:                  corev1.ServiceTypeLoadBalancer,
+		ExternalTrafficPolicy: corev1.ServiceExternalTrafficPolicyTypeLocal,
+		Ports:                 []corev1.ServicePort{{Name: "redis-client", Port: 6379}, {Name: "sentinel", Port: 26379}},
+	}}
+	keepAllocatedFields(stored, desired)
+	if desired.Spec.Ports[0].NodePort != 31001 || desired.Spec.Ports[1].NodePort != 0 || desired.Spec.HealthCheckNodePort != 32000 {
+		t.Errorf("got spec %+v", desired.Spec)
+	}
+
+	clusterIP := &corev1.Service{Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeClusterIP, Ports: []corev1.ServicePort{{Name: "redis-client", Port: 6379}}}}
+	keepAllocatedFields(stored, clusterIP)
+	if clusterIP.Spec.Ports[0].NodePort != 0 {
+		t.Errorf("ClusterIP service kept node port %d", clusterIP.Spec.Ports[0].NodePort)
+	}
+}
+
+func TestKeepForeignEntries(t *testing.T) {
+	stored := map[string]string{"cloud.example.com/lb-id": "lb-1", "team": "cache", "old": "true"}
+	applied := map[string]string{"team": "cache", "old": "true"}
+	desired := map[string]string{"team": "storage"}
+	want := map[string]string{"cloud.example.com/lb-id": "lb-1", "team": "storage"}
+	if got := keepForeignEntries(stored, desired, applied); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
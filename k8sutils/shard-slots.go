@@ -0,0 +1,98 @@
+package k8sutils
+
+import (
+	"fmt"
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"strconv"
+	"strings"
+)
+
+// applyShardSlots will set the weight and the pinned slots of the masters from the shards of the leader spec
+func applyShardSlots(cr *redisv1beta1.RedisCluster, masters []clusterMaster, topology *ClusterTopology) error {
+	shards := map[int]redisv1beta1.ShardSlots{}
+	pinned := map[int][]int{}
+	pinnedBy := map[int]int{}
+	for _, shard := range cr.Spec.RedisLeader.Shards {
+		ordinal := int(shard.Ordinal)
+		slots, err := parseSlotRanges(shard.Slots)
+		if err != nil {
+			return fmt.Errorf("invalid slots of shard %d: %v", ordinal, err)
+		}
+		for _, slot := range slots {
+			if other, ok := pinnedBy[slot]; ok {
+				return fmt.Errorf("slot %d is pinned to shard %d and shard %d", slot, other, ordinal)
+			}
+			pinnedBy[slot] = ordinal
+		}
+		shards[ordinal] = shard
+		pinned[ordinal] = slots
+	}
+	if len(shards) == 0 {
+		return nil
+	}
+
+	leaderPrefix := cr.ObjectMeta.Name + "-leader-"
+	for i := range masters {
+		ordinal := getShardOrdinal(topology, masters[i].ID, leaderPrefix)
+		shard, ok := shards[ordinal]
+		if !ok {
+			continue
+		}
+		if shard.Weight != nil {
+			masters[i].Weight = int(*shard.Weight)
+		}
+		masters[i].Pinned = pinned[ordinal]
+	}
+	return nil
+}
+
+// getShardOrdinal returns the ordinal of the leader pod serving the master or one of its replicas, -1 otherwise,
+// a shard keeps its settings when a failover promoted a follower pod
+func getShardOrdinal(topology *ClusterTopology, masterID, leaderPrefix string) int {
+	if podName := topology.PodNameByID(masterID); strings.HasPrefix(podName, leaderPrefix) {
+		return podOrdinal(podName)
+	}
+	for _, node := range topology.Nodes {
+		if podName := topology.PodName(node); node.MasterID == masterID && strings.HasPrefix(podName, leaderPrefix) {
+			return podOrdinal(podName)
+		}
+	}
+	return -1
+}
+
+// parseSlotRanges will parse comma separated slots and slot ranges
+func parseSlotRanges(value string) ([]int, error) {
+	var slots []int
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		bounds := strings.SplitN(field, "-", 2)
+		start, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		if err != nil {
+			return nil, fmt.Errorf("malformed slot range %q", field)
+		}
+		end := start
+		if len(bounds) == 2 {
+			if end, err = strconv.Atoi(strings.TrimSpace(bounds[1])); err != nil {
+				return nil, fmt.Errorf("malformed slot range %q", field)
+			}
+		}
+		if start < 0 || end >= totalHashSlots || start > end {
+			return nil, fmt.Errorf("slot range %q is out of 0-%d", field, totalHashSlots-1)
+		}
+		for slot := start; slot <= end; slot++ {
+			slots = append(slots, slot)
+		}
+	}
+	return slots, nil
+}
+
+// getMaxSlotsPerReconcile returns the number of slots migrated per reconcile
+func getMaxSlotsPerReconcile(cr *redisv1beta1.RedisCluster) int {
+	if cr.Spec.RedisLeader.MaxSlotsPerReconcile != nil && *cr.Spec.RedisLeader.MaxSlotsPerReconcile > 0 {
+		return int(*cr.Spec.RedisLeader.MaxSlotsPerReconcile)
+	}
+	return rebalanceBatchSize
+}
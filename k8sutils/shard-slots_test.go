@@ -0,0 +1,63 @@
+package k8sutils
+
+import (
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"reflect"
+	"testing"
+)
+
+func TestParseSlotRanges(t *testing.T) {
+	var tests = []struct {
+		value   string
+		want    []int
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"0-2, 7", []int{0, 1, 2, 7}, false},
+		{"16383", []int{16383}, false},
+		{"5-x", nil, true},
+		{"10-5", nil, true},
+		{"16384", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			slots, err := parseSlotRanges(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(slots, tt.want) {
+				t.Errorf("got %v, want %v", slots, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyShardSlots(t *testing.T) {
+	weight := int32(3)
+	cr := &redisv1beta1.RedisCluster{}
+	cr.ObjectMeta.Name = "redis"
+	cr.Spec.RedisLeader.Shards = []redisv1beta1.ShardSlots{{Ordinal: 0, Weight: &weight}, {Ordinal: 1, Slots: "0-1"}}
+	// Shard 0 failed over to a follower pod, the leader pod is its replica now
+	topology := &ClusterTopology{
+		Nodes: clusterNodesFromOutput(`
+a 10.0.0.1:6379@16379 slave b 0 0 1 connected
+b 10.0.0.4:6379@16379 master - 0 0 2 connected 0-8191
+c 10.0.0.2:6379@16379 myself,master - 0 0 3 connected 8192-16383`),
+		PodsByIP: map[string]string{"10.0.0.1": "redis-leader-0", "10.0.0.2": "redis-leader-1", "10.0.0.4": "redis-follower-0"},
+	}
+	masters := []clusterMaster{{ID: "b"}, {ID: "c"}}
+	if err := applyShardSlots(cr, masters, topology); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if masters[0].Weight != 3 || masters[0].Pinned != nil {
+		t.Errorf("got %+v for the shard of leader 0", masters[0])
+	}
+	if masters[1].Weight != 0 || !reflect.DeepEqual(masters[1].Pinned, []int{0, 1}) {
+		t.Errorf("got %+v for the shard of leader 1", masters[1])
+	}
+
+	cr.Spec.RedisLeader.Shards = append(cr.Spec.RedisLeader.Shards, redisv1beta1.ShardSlots{Ordinal: 2, Slots: "1"})
+	if err := applyShardSlots(cr, masters, topology); err == nil {
+		t.Errorf("no error for a slot pinned to two shards")
+	}
+}
@@ -0,0 +1,231 @@
+package k8sutils
+
+import (
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"sort"
+)
+
+const (
+	totalHashSlots = 16384
+
+	unassignedSlotsReassign = "Reassign"
+)
+
+// openSlot is a slot left in migrating or importing state, either side may be missing
+type openSlot struct {
+	Slot      int
+	Migrating string
+	Importing string
+}
+
+// ReconcileRedisClusterSlotCoverage will report the open and unassigned slots and fix them when slot healing is enabled
+func ReconcileRedisClusterSlotCoverage(cr *redisv1beta1.RedisCluster, topology *ClusterTopology) (*redisv1beta1.SlotCoverageStatus, error) {
+	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
+	if !topology.Formed() {
+		return cr.Status.SlotCoverage, nil
+	}
+	nodes := topology.Nodes
+	myselfNodes, err := getMasterSelfViews(cr, topology)
+	if err != nil {
+		return cr.Status.SlotCoverage, err
+	}
+	open := findOpenSlots(myselfNodes)
+	unassigned := findUnassignedSlots(nodes)
+	status := getSlotCoverageStatus(open, unassigned)
+	healing := cr.Spec.SlotHealing
+	if status == nil || healing == nil || !healing.Enabled {
+		return status, nil
+	}
+
+	if len(open) > 0 {
+		var moves []slotMove
+		for _, slot := range open {
+			if slot.Migrating != "" && slot.Importing != "" {
+				moves = append(moves, slotMove{Slot: slot.Slot, From: slot.Migrating, To: slot.Importing})
+				continue
+			}
+			// Only one side of the migration is left, the slot stays with its owner
+			if err := closeOpenSlot(cr, topology, slot); err != nil {
+				return status, err
+			}
+		}
+		if len(moves) > 0 {
+			masters := collectClusterMasters(topology, cr.ObjectMeta.Name+"-leader-")
+			moved, err := executeSlotMoves(cr, moves, masters, getRedisClusterPassword(cr))
+			logger.Info("Completed the migration of open slots", "SlotsMoved", moved, "SlotsPending", len(moves)-moved)
+			if err != nil {
+				return status, err
+			}
+		}
+	}
+
+	if len(unassigned) > 0 && healing.UnassignedSlots == unassignedSlotsReassign {
+		masters := collectClusterMasters(topology, cr.ObjectMeta.Name+"-leader-")
+		for masterID, slots := range planSlotAssignment(masters, unassigned) {
+			podName := topology.PodNameByID(masterID)
+			if podName == "" {
+				continue
+			}
+			client := configureRedisClient(cr, podName)
+			err := client.ClusterAddSlots(slots...).Err()
+			client.Close()
+			if err != nil {
+				return status, err
+			}
+			logger.Info("Unassigned slots added to master", "Pod", podName, "Slots", formatSlotRanges(slots))
+		}
+	}
+	return status, nil
+}
+
+// closeOpenSlot will set a slot left open on one side stable, the keys an importing node received are migrated back
+// to the owner of the slot first as redis-cli --cluster fix does
+func closeOpenSlot(cr *redisv1beta1.RedisCluster, topology *ClusterTopology, slot openSlot) error {
+	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
+	nodeID := slot.Migrating + slot.Importing
+	podName := topology.PodNameByID(nodeID)
+	if podName == "" {
+		return nil
+	}
+	client := configureRedisClient(cr, podName)
+	defer client.Close()
+	if slot.Importing != "" {
+		owner := getSlotOwner(topology.Nodes, slot.Slot)
+		ownerPod := ""
+		if owner != nil {
+			ownerPod = topology.PodName(*owner)
+		}
+		if owner != nil && owner.ID != nodeID && ownerPod == "" {
+			logger.Info("Owner of the open slot has no pod, the slot is left open", "Slot", slot.Slot, "Owner", owner.ID)
+			return nil
+		}
+		if owner != nil && owner.ID != nodeID {
+			target := clusterMaster{ID: owner.ID, PodName: ownerPod, IP: owner.IP}
+			if err := migrateSlotKeys(client, target, slot.Slot, getRedisClusterPassword(cr)); err != nil {
+				return err
+			}
+			logger.Info("Keys of the open slot migrated back to its owner", "Slot", slot.Slot, "Pod", podName, "Owner", ownerPod)
+		}
+	}
+	if err := client.Do("cluster", "setslot", slot.Slot, "stable").Err(); err != nil {
+		return err
+	}
+	logger.Info("Open slot set stable", "Slot", slot.Slot, "Pod", podName)
+	return nil
+}
+
+// getSlotOwner returns the healthy master owning the slot or nil
+func getSlotOwner(nodes []ClusterNode, slot int) *ClusterNode {
+	for i, node := range nodes {
+		if !node.IsMaster() || node.IsFailed() {
+			continue
+		}
+		for _, owned := range node.SlotList() {
+			if owned == slot {
+				return &nodes[i]
+			}
+		}
+	}
+	return nil
+}
+
+// getMasterSelfViews returns the myself line of every healthy master, open slots only show up in the view of the
+// node holding them
+func getMasterSelfViews(cr *redisv1beta1.RedisCluster, topology *ClusterTopology) ([]ClusterNode, error) {
+	var myselfNodes []ClusterNode
+	for _, node := range topology.Nodes {
+		podName := topology.PodName(node)
+		if !node.IsMaster() || node.IsFailed() || podName == "" {
+			continue
+		}
+		client := configureRedisClient(cr, podName)
+		view, err := getClusterNodes(client)
+		client.Close()
+		if err != nil {
+			return nil, err
+		}
+		if myself := findClusterNode(view, "myself"); myself != nil {
+			myselfNodes = append(myselfNodes, *myself)
+		}
+	}
+	return myselfNodes, nil
+}
+
+// findOpenSlots returns the slots in migrating or importing state from the myself lines of the masters
+func findOpenSlots(myselfNodes []ClusterNode) []openSlot {
+	open := map[int]*openSlot{}
+	for _, node := range myselfNodes {
+		for slot := range node.Migrating {
+			if open[slot] == nil {
+				open[slot] = &openSlot{Slot: slot}
+			}
+			open[slot].Migrating = node.ID
+		}
+		for slot := range node.Importing {
+			if open[slot] == nil {
+				open[slot] = &openSlot{Slot: slot}
+			}
+			open[slot].Importing = node.ID
+		}
+	}
+	var slots []openSlot
+	for _, slot := range open {
+		slots = append(slots, *slot)
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i].Slot < slots[j].Slot })
+	return slots
+}
+
+// findUnassignedSlots returns the slots owned by no master, failed masters still own their slots
+func findUnassignedSlots(nodes []ClusterNode) []int {
+	assigned := make([]bool, totalHashSlots)
+	for _, node := range nodes {
+		for _, slot := range node.SlotList() {
+			if slot >= 0 && slot < totalHashSlots {
+				assigned[slot] = true
+			}
+		}
+	}
+	var unassigned []int
+	for slot, ok := range assigned {
+		if !ok {
+			unassigned = append(unassigned, slot)
+		}
+	}
+	return unassigned
+}
+
+// planSlotAssignment will spread the unassigned slots over the masters with the fewest slots, empty masters first
+func planSlotAssignment(masters []clusterMaster, unassigned []int) map[string][]int {
+	if len(masters) == 0 {
+		return nil
+	}
+	owned := map[string]int{}
+	for _, master := range masters {
+		owned[master.ID] = len(master.Slots)
+	}
+	assignment := map[string][]int{}
+	for _, slot := range unassigned {
+		target := masters[0].ID
+		for _, master := range masters {
+			if owned[master.ID] < owned[target] {
+				target = master.ID
+			}
+		}
+		owned[target]++
+		assignment[target] = append(assignment[target], slot)
+	}
+	return assignment
+}
+
+// getSlotCoverageStatus returns the status listing the open and unassigned slots or nil when every slot is served
+func getSlotCoverageStatus(open []openSlot, unassigned []int) *redisv1beta1.SlotCoverageStatus {
+	if len(open) == 0 && len(unassigned) == 0 {
+		return nil
+	}
+	var openSlots []int
+	for _, slot := range open {
+		openSlots = append(openSlots, slot.Slot)
+	}
+	return &redisv1beta1.SlotCoverageStatus{OpenSlots: formatSlotRanges(openSlots), UnassignedSlots: formatSlotRanges(unassigned)}
+}
@@ -0,0 +1,78 @@
+package k8sutils
+
+import (
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"reflect"
+	"testing"
+)
+
+func TestFindOpenSlots(t *testing.T) {
+	myselfNodes := clusterNodesFromOutput(`
+a 10.0.0.1:6379@16379 myself,master - 0 0 1 connected 0-5460 [93->-b] [94->-c]
+b 10.0.0.2:6379@16379 myself,master - 0 0 2 connected 5461-10922 [93-<-a] [11000-<-c]
+c 10.0.0.3:6379@16379 myself,master - 0 0 3 connected 10923-16383`)
+	want := []openSlot{
+		{Slot: 93, Migrating: "a", Importing: "b"},
+		{Slot: 94, Migrating: "a"},
+		{Slot: 11000, Importing: "b"},
+	}
+	if open := findOpenSlots(myselfNodes); !reflect.DeepEqual(open, want) {
+		t.Errorf("got open slots %v, want %v", open, want)
+	}
+}
+
+func TestFindUnassignedSlots(t *testing.T) {
+	nodes := clusterNodesFromOutput(`
+a 10.0.0.1:6379@16379 myself,master - 0 0 1 connected 0-5460
+b 10.0.0.2:6379@16379 master,fail - 0 0 2 disconnected 5461-10922
+c 10.0.0.3:6379@16379 master - 0 0 3 connected 10923-16380`)
+	if unassigned := findUnassignedSlots(nodes); !reflect.DeepEqual(unassigned, []int{16381, 16382, 16383}) {
+		t.Errorf("got unassigned slots %v", unassigned)
+	}
+}
+
+func TestPlanSlotAssignment(t *testing.T) {
+	masters := []clusterMaster{
+		{ID: "a", Slots: slotRange(0, 5)},
+		{ID: "b"},
+		{ID: "c", Slots: slotRange(6, 7)},
+	}
+	want := map[string][]int{"b": {8, 9, 10}, "c": {11}}
+	if assignment := planSlotAssignment(masters, slotRange(8, 11)); !reflect.DeepEqual(assignment, want) {
+		t.Errorf("got assignment %v, want %v", assignment, want)
+	}
+}
+
+func TestGetSlotCoverageStatus(t *testing.T) {
+	if status := getSlotCoverageStatus(nil, nil); status != nil {
+		t.Errorf("covered cluster should have no status, got %v", status)
+	}
+	want := &redisv1beta1.SlotCoverageStatus{OpenSlots: "93", UnassignedSlots: "16381-16383"}
+	if status := getSlotCoverageStatus([]openSlot{{Slot: 93}}, slotRange(16381, 16383)); !reflect.DeepEqual(status, want) {
+		t.Errorf("got status %v, want %v", status, want)
+	}
+}
+
+func TestGetSlotOwner(t *testing.T) {
+	nodes := clusterNodesFromOutput(`
+a 10.0.0.1:6379@16379 myself,master - 0 0 1 connected 0-5460
+b 10.0.0.2:6379@16379 master,fail - 0 0 2 disconnected 5461-10922
+c 10.0.0.3:6379@16379 master - 0 0 3 connected 10923-16383`)
+	var tests = []struct {
+		slot int
+		want string
+	}{
+		{93, "a"},
+		{6000, ""},
+		{11000, "c"},
+	}
+	for _, tt := range tests {
+		got := ""
+		if owner := getSlotOwner(nodes, tt.slot); owner != nil {
+			got = owner.ID
+		}
+		if got != tt.want {
+			t.Errorf("slot %d: got owner %q, want %q", tt.slot, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package k8sutils
+
+import (
+	"context"
+	"fmt"
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/go-redis/redis"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// SnapshotAnnotation requests a snapshot group named after the annotation value
+	SnapshotAnnotation = "redis.opstreelabs.in/snapshot"
+
+	snapshotGroupLabel   = "redis.opstreelabs.in/snapshot-group"
+	snapshotOrdinalLabel = "redis.opstreelabs.in/snapshot-ordinal"
+	snapshotAPIGroup     = "snapshot.storage.k8s.io"
+	bgsaveTimeout        = 2 * time.Minute
+
+	snapshotPhaseSaving   = "Saving"
+	snapshotPhaseCreating = "Creating"
+	snapshotPhaseReady    = "Ready"
+)
+
+var (
+	volumeSnapshotGVK     = schema.GroupVersionKind{Group: snapshotAPIGroup, Version: "v1", Kind: "VolumeSnapshot"}
+	volumeSnapshotListGVK = schema.GroupVersionKind{Group: snapshotAPIGroup, Version: "v1", Kind: "VolumeSnapshotList"}
+)
+
+// ReconcileRedisClusterSnapshot will take a snapshot group when requested through the snapshot annotation, the dumps of
+// the nodes and the readiness of the VolumeSnapshots are checked on the following reconciles
+func ReconcileRedisClusterSnapshot(cl client.Client, cr *redisv1beta1.RedisCluster) (*redisv1beta1.SnapshotStatus, error) {
+	group := cr.GetAnnotations()[SnapshotAnnotation]
+	status := cr.Status.Snapshot.DeepCopy()
+	// Groups taken before the phases were reported have no phase and are complete
+	if group == "" || (status != nil && status.Name == group && (status.Phase == snapshotPhaseReady || status.Phase == "")) {
+		return cr.Status.Snapshot, nil
+	}
+	if status == nil || status.Name != group {
+		status = &redisv1beta1.SnapshotStatus{Name: group, Phase: snapshotPhaseSaving}
+	}
+	logger := snapshotLogger(cr.Namespace, group)
+	switch status.Phase {
+	case snapshotPhaseSaving:
+		saved, err := executeRedisClusterBGSave(cr, status)
+		if err != nil || !saved {
+			return status, err
+		}
+		snapshots, err := createVolumeSnapshots(cl, cr, group)
+		if err != nil {
+			return status, err
+		}
+		status.SaveStartTimes = nil
+		status.VolumeSnapshots = snapshots
+		status.Phase = snapshotPhaseCreating
+	case snapshotPhaseCreating:
+		ready, err := areVolumeSnapshotsReady(cl, cr.Namespace, status.VolumeSnapshots)
+		if err != nil || !ready {
+			return status, err
+		}
+		now := metav1.Now()
+		status.CreationTime = &now
+		status.Phase = snapshotPhaseReady
+		logger.Info("VolumeSnapshot group is ready to use", "VolumeSnapshots", status.VolumeSnapshots)
+	}
+	return status, nil
+}
+
+// createVolumeSnapshots will create a VolumeSnapshot of every PVC of the cluster and return their names
+func createVolumeSnapshots(cl client.Client, cr *redisv1beta1.RedisCluster, group string) ([]string, error) {
+	logger := snapshotLogger(cr.Namespace, group)
+	pvcs := &corev1.PersistentVolumeClaimList{}
+	if err := cl.List(context.TODO(), pvcs, client.InNamespace(cr.Namespace), client.MatchingLabels{"redis_setup_type": "cluster"}); err != nil {
+		logger.Error(err, "Could not list Persistent Volume Claims")
+		return nil, err
+	}
+	var snapshots []string
+	for _, pvc := range pvcs.Items {
+		for _, role := range []string{"leader", "follower"} {
+			ordinal, ok := claimOrdinal(pvc.Name, cr.ObjectMeta.Name+"-"+role, cr.Status.StorageMigrations)
+			if !ok {
+				continue
+			}
+			snapshot := generateVolumeSnapshotDef(cr, group, role, ordinal, pvc.Name)
+			if err := cl.Create(context.TODO(), snapshot); err != nil && !errors.IsAlreadyExists(err) {
+				logger.Error(err, "VolumeSnapshot creation failed", "PVC", pvc.Name)
+				return nil, err
+			}
+			snapshots = append(snapshots, snapshot.GetName())
+		}
+	}
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("no persistent volume claims found to snapshot for %s", cr.ObjectMeta.Name)
+	}
+	sort.Strings(snapshots)
+	logger.Info("VolumeSnapshot group successfully created", "VolumeSnapshots", snapshots)
+	return snapshots, nil
+}
+
+// areVolumeSnapshotsReady checks if every VolumeSnapshot of the group is ready to use, a failed snapshot is an error
+func areVolumeSnapshotsReady(cl client.Client, namespace string, names []string) (bool, error) {
+	for _, name := range names {
+		snapshot := &unstructured.Unstructured{}
+		snapshot.SetGroupVersionKind(volumeSnapshotGVK)
+		if err := cl.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, snapshot); err != nil {
+			return false, err
+		}
+		if message, found, _ := unstructured.NestedString(snapshot.Object, "status", "error", "message"); found {
+			return false, fmt.Errorf("VolumeSnapshot %s failed: %s", name, message)
+		}
+		if ready, _, _ := unstructured.NestedBool(snapshot.Object, "status", "readyToUse"); !ready {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// RestoreRedisClusterPVCs will pre-create the PVCs of the cluster from the snapshot group in spec.snapshot.restoreFrom
+func RestoreRedisClusterPVCs(cl client.Client, cr *redisv1beta1.RedisCluster) error {
+	if cr.Spec.Snapshot == nil || cr.Spec.Snapshot.RestoreFrom == "" || cr.Spec.Storage == nil || cr.Spec.Storage.IsEphemeral() {
+		return nil
+	}
+	group := cr.Spec.Snapshot.RestoreFrom
+	logger := snapshotLogger(cr.Namespace, group)
+	for _, role := range []string{"leader", "follower"} {
+		snapshots := &unstructured.UnstructuredList{}
+		snapshots.SetGroupVersionKind(volumeSnapshotListGVK)
+		if err := cl.List(context.TODO(), snapshots, client.InNamespace(cr.Namespace), client.MatchingLabels{snapshotGroupLabel: group, "role": role}); err != nil {
+			logger.Error(err, "Could not list VolumeSnapshots")
+			return err
+		}
+		snapshotByOrdinal := map[string]string{}
+		for _, snapshot := range snapshots.Items {
+			snapshotByOrdinal[snapshot.GetLabels()[snapshotOrdinalLabel]] = snapshot.GetName()
+		}
+
+		stsName := cr.ObjectMeta.Name + "-" + role
+		labels := getRedisLabels(stsName, "cluster", role, cr.ObjectMeta.Labels)
+		stsMeta := generateObjectMetaInformation(stsName, cr.Namespace, labels, generateStatefulSetsAnots(cr.ObjectMeta))
+		for ordinal := 0; ordinal < int(cr.Spec.GetReplicaCounts(role)); ordinal++ {
+			snapshotName, ok := snapshotByOrdinal[strconv.Itoa(ordinal)]
+			if !ok {
+				logger.Info("No VolumeSnapshot found, the PVC is provisioned empty", "Role", role, "Ordinal", ordinal)
+				continue
+			}
+			pvc := generateRestoredPVCDef(stsMeta, cr.Spec.Storage.VolumeClaimTemplate, ordinal, snapshotName)
+			err := cl.Get(context.TODO(), types.NamespacedName{Namespace: cr.Namespace, Name: pvc.Name}, &corev1.PersistentVolumeClaim{})
+			if err == nil {
+				continue
+			} else if !errors.IsNotFound(err) {
+				return err
+			}
+			if err := cl.Create(context.TODO(), pvc); err != nil {
+				logger.Error(err, "Restored PVC creation failed", "PVC", pvc.Name)
+				return err
+			}
+			logger.Info("PVC restored from VolumeSnapshot", "PVC", pvc.Name, "VolumeSnapshot", snapshotName)
+		}
+	}
+	return nil
+}
+
+// generateVolumeSnapshotDef generates the VolumeSnapshot of a single PVC
+func generateVolumeSnapshotDef(cr *redisv1beta1.RedisCluster, group, role string, ordinal int, pvcName string) *unstructured.Unstructured {
+	snapshot := &unstructured.Unstructured{}
+	snapshot.SetGroupVersionKind(volumeSnapshotGVK)
+	snapshot.SetName(group + "-" + pvcName)
+	snapshot.SetNamespace(cr.Namespace)
+	snapshot.SetLabels(map[string]string{
+		"app":                cr.ObjectMeta.Name + "-" + role,
+		"redis_setup_type":   "cluster",
+		"role":               role,
+		snapshotGroupLabel:   group,
+		snapshotOrdinalLabel: strconv.Itoa(ordinal),
+	})
+	spec := map[string]interface{}{
+		"source": map[string]interface{}{
+			"persistentVolumeClaimName": pvcName,
+		},
+	}
+	if cr.Spec.Snapshot != nil && cr.Spec.Snapshot.VolumeSnapshotClassName != nil {
+		spec["volumeSnapshotClassName"] = *cr.Spec.Snapshot.VolumeSnapshotClassName
+	}
+	snapshot.Object["spec"] = spec
+	return snapshot
+}
+
+// generateRestoredPVCDef generates the PVC the statefulset would create, provisioned from a VolumeSnapshot
+func generateRestoredPVCDef(stsMeta metav1.ObjectMeta, storageSpec corev1.PersistentVolumeClaim, ordinal int, snapshotName string) *corev1.PersistentVolumeClaim {
+	apiGroup := snapshotAPIGroup
+	pvc := createPVCTemplate(stsMeta, storageSpec)
+	pvc.Name = stsMeta.GetName() + "-" + stsMeta.GetName() + "-" + strconv.Itoa(ordinal)
+	pvc.Namespace = stsMeta.GetNamespace()
+	pvc.Spec.DataSource = &corev1.TypedLocalObjectReference{
+		APIGroup: &apiGroup,
+		Kind:     volumeSnapshotGVK.Kind,
+		Name:     snapshotName,
+	}
+	return &pvc
+}
+
+// executeRedisClusterBGSave will start BGSAVE on every node and check on the following reconciles that the dumps
+// completed, it returns true once every node saved after the request
+func executeRedisClusterBGSave(cr *redisv1beta1.RedisCluster, status *redisv1beta1.SnapshotStatus) (bool, error) {
+	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
+	if status.SaveStartTimes == nil {
+		status.SaveStartTimes = map[string]int64{}
+	}
+	saved := true
+	for _, role := range []string{"leader", "follower"} {
+		for podCount := 0; podCount < int(cr.Spec.GetReplicaCounts(role)); podCount++ {
+			podName := cr.ObjectMeta.Name + "-" + role + "-" + strconv.Itoa(podCount)
+			client := configureRedisClient(cr, podName)
+			done, err := checkBGSave(client, status.SaveStartTimes, podName)
+			client.Close()
+			if err != nil {
+				logger.Error(err, "Redis BGSAVE failed", "Pod", podName)
+				return false, err
+			}
+			saved = saved && done
+		}
+	}
+	return saved, nil
+}
+
+// checkBGSave will start BGSAVE on a node without a recorded start time and check if a dump completed since then, a
+// node busy with another dump is asked again once it is done as that dump may predate the request
+func checkBGSave(client *redis.Client, startTimes map[string]int64, podName string) (bool, error) {
+	startTime, started := startTimes[podName]
+	if !started {
+		// The time is read first, a dump completing within the same second still counts
+		now, err := client.Time().Result()
+		if err != nil {
+			return false, err
+		}
+		if err := client.BgSave().Err(); err != nil {
+			if strings.Contains(err.Error(), "in progress") {
+				return false, nil
+			}
+			return false, err
+		}
+		startTimes[podName] = now.Unix()
+		return false, nil
+	}
+	info, err := getRedisInfo(client, "persistence")
+	if err != nil {
+		return false, err
+	}
+	return isBGSaveCompleted(info, startTime)
+}
+
+// isBGSaveCompleted checks the persistence info for a dump which completed after the start time
+func isBGSaveCompleted(info map[string]string, startTime int64) (bool, error) {
+	if info["rdb_bgsave_in_progress"] != "0" {
+		return false, nil
+	}
+	if info["rdb_last_bgsave_status"] != "ok" {
+		return false, fmt.Errorf("last BGSAVE failed with status %s", info["rdb_last_bgsave_status"])
+	}
+	lastSave, err := strconv.ParseInt(info["rdb_last_save_time"], 10, 64)
+	if err != nil {
+		return false, err
+	}
+	return lastSave >= startTime, nil
+}
+
+// executeBGSave will run BGSAVE and wait until LASTSAVE moves forward
+func executeBGSave(client *redis.Client) error {
+	lastSave, err := client.LastSave().Result()
+	if err != nil {
+		return err
+	}
+	if err := client.BgSave().Err(); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(bgsaveTimeout)
+	for time.Now().Before(deadline) {
+		current, err := client.LastSave().Result()
+		if err != nil {
+			return err
+		}
+		if current > lastSave {
+			return nil
+		}
+		time.Sleep(time.Second)
+	}
+	return fmt.Errorf("BGSAVE did not complete within %s", bgsaveTimeout)
+}
+
+// snapshotLogger will generate logging interface for VolumeSnapshots
+func snapshotLogger(namespace string, name string) logr.Logger {
+	reqLogger := log.WithValues("Request.VolumeSnapshot.Namespace", namespace, "Request.VolumeSnapshot.Group", name)
+	return reqLogger
+}
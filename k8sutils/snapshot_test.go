@@ -0,0 +1,155 @@
+package k8sutils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+func TestGenerateVolumeSnapshotDef(t *testing.T) {
+	className := "csi-snapclass"
+	cr := &redisv1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "redis-cluster", Namespace: "ot"},
+		Spec:       redisv1beta1.RedisClusterSpec{Snapshot: &redisv1beta1.Snapshot{VolumeSnapshotClassName: &className}},
+	}
+	snapshot := generateVolumeSnapshotDef(cr, "nightly", "leader", 2, "redis-cluster-leader-redis-cluster-leader-2")
+	if snapshot.GetName() != "nightly-redis-cluster-leader-redis-cluster-leader-2" {
+		t.Errorf("unexpected name %s", snapshot.GetName())
+	}
+	if snapshot.GetLabels()[snapshotOrdinalLabel] != "2" || snapshot.GetLabels()[snapshotGroupLabel] != "nightly" {
+		t.Errorf("unexpected labels %v", snapshot.GetLabels())
+	}
+	spec := snapshot.Object["spec"].(map[string]interface{})
+	if spec["volumeSnapshotClassName"] != className {
+		t.Errorf("unexpected volumeSnapshotClassName %v", spec["volumeSnapshotClassName"])
+	}
+}
+
+func TestRestoreRedisClusterPVCs(t *testing.T) {
+	leaders := int32(2)
+	followers := int32(0)
+	cr := &redisv1beta1.RedisCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "redis-cluster", Namespace: "ot"},
+		Spec: redisv1beta1.RedisClusterSpec{
+			Size:          &leaders,
+			RedisLeader:   redisv1beta1.RedisLeader{Replicas: &leaders},
+			RedisFollower: redisv1beta1.RedisFollower{Replicas: &followers},
+			Storage:       &redisv1beta1.Storage{},
+			Snapshot:      &redisv1beta1.Snapshot{RestoreFrom: "nightly"},
+		},
+	}
+	snapshot := generateVolumeSnapshotDef(cr, "nightly", "leader", 0, "redis-cluster-leader-redis-cluster-leader-0")
+	cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(snapshot).Build()
+
+	if err := RestoreRedisClusterPVCs(cl, cr); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := cl.Get(context.TODO(), types.NamespacedName{Namespace: "ot", Name: "redis-cluster-leader-redis-cluster-leader-0"}, pvc); err != nil {
+		t.Fatalf("restored PVC not found: %v", err)
+	}
+	if pvc.Spec.DataSource == nil || pvc.Spec.DataSource.Name != snapshot.GetName() || pvc.Spec.DataSource.Kind != "VolumeSnapshot" {
+		t.Errorf("unexpected dataSource %v", pvc.Spec.DataSource)
+	}
+	err := cl.Get(context.TODO(), types.NamespacedName{Namespace: "ot", Name: "redis-cluster-leader-redis-cluster-leader-1"}, &corev1.PersistentVolumeClaim{})
+	if err == nil {
+		t.Errorf("PVC without a VolumeSnapshot should be left to the statefulset")
+	}
+}
+
+func TestIsBGSaveCompleted(t *testing.T) {
+	tests := []struct {
+		name    string
+		info    map[string]string
+		want    bool
+		wantErr bool
+	}{
+		{"in progress", map[string]string{"rdb_bgsave_in_progress": "1", "rdb_last_bgsave_status": "ok", "rdb_last_save_time": "90"}, false, false},
+		{"saved before the request", map[string]string{"rdb_bgsave_in_progress": "0", "rdb_last_bgsave_status": "ok", "rdb_last_save_time": "99"}, false, false},
+		{"saved within the second of the request", map[string]string{"rdb_bgsave_in_progress": "0", "rdb_last_bgsave_status": "ok", "rdb_last_save_time": "100"}, true, false},
+		{"failed", map[string]string{"rdb_bgsave_in_progress": "0", "rdb_last_bgsave_status": "err", "rdb_last_save_time": "90"}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := isBGSaveCompleted(tt.info, 100)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("got %v, %v, want %v, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestVolumeSnapshotGroupEnvtest runs against an API server with the VolumeSnapshot CRD installed, make test provides
+// the envtest binaries
+func TestVolumeSnapshotGroupEnvtest(t *testing.T) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		t.Skip("KUBEBUILDER_ASSETS is not set")
+	}
+	testEnv := &envtest.Environment{CRDDirectoryPaths: []string{filepath.Join("testdata")}}
+	cfg, err := testEnv.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer testEnv.Stop()
+	cl, err := client.New(cfg, client.Options{Scheme: scheme.Scheme})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cr := &redisv1beta1.RedisCluster{ObjectMeta: metav1.ObjectMeta{Name: "redis-cluster", Namespace: "default"}}
+	for _, name := range []string{"redis-cluster-leader-redis-cluster-leader-0", "redis-cluster-follower-redis-cluster-follower-0"} {
+		pvc := &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{"redis_setup_type": "cluster"}},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+				Resources:   corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")}},
+			},
+		}
+		if err := cl.Create(context.TODO(), pvc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snapshots, err := createVolumeSnapshots(cl, cr, "nightly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 {
+		t.Fatalf("got VolumeSnapshots %v", snapshots)
+	}
+	// Creating the group again keeps the existing VolumeSnapshots
+	if _, err := createVolumeSnapshots(cl, cr, "nightly"); err != nil {
+		t.Fatal(err)
+	}
+	if ready, err := areVolumeSnapshotsReady(cl, "default", snapshots); err != nil || ready {
+		t.Fatalf("got ready %v, %v before the snapshotter reported", ready, err)
+	}
+	for _, name := range snapshots {
+		snapshot := &unstructured.Unstructured{}
+		snapshot.SetGroupVersionKind(volumeSnapshotGVK)
+		if err := cl.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: name}, snapshot); err != nil {
+			t.Fatal(err)
+		}
+		if err := unstructured.SetNestedField(snapshot.Object, true, "status", "readyToUse"); err != nil {
+			t.Fatal(err)
+		}
+		if err := cl.Status().Update(context.TODO(), snapshot); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ready, err := areVolumeSnapshotsReady(cl, "default", snapshots); err != nil || !ready {
+		t.Fatalf("got ready %v, %v once every VolumeSnapshot is ready to use", ready, err)
+	}
+}
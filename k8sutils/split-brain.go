@@ -0,0 +1,311 @@
+package k8sutils
+
+import (
+	"fmt"
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"sort"
+	"strings"
+
+	"github.com/go-redis/redis"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DegradedCondition reports whether the nodes of the cluster disagree about the slot owners or the config epochs
+	DegradedCondition = "Degraded"
+	// ResolveTopologyAnnotation set to "true" hands every conflicting slot to the claimant with the highest config epoch,
+	// the keys the other claimants hold in those slots are deleted
+	ResolveTopologyAnnotation = "redis.opstreelabs.in/resolve-topology"
+)
+
+// clusterConflicts lists the inconsistencies between the views of the cluster nodes
+type clusterConflicts struct {
+	Issues []string
+	// Owners maps the conflicting slots to the claimant with the highest config epoch
+	Owners map[int]string
+	// Bumps are the masters sharing a config epoch which take a new one
+	Bumps []string
+}
+
+// ReconcileRedisClusterConsistency will compare the CLUSTER NODES views of every pod and report the conflicts in the
+// Degraded condition, conflicts are resolved by the highest config epoch when requested, it returns the actions taken
+func ReconcileRedisClusterConsistency(cr *redisv1beta1.RedisCluster, topology *ClusterTopology, resolve bool) (metav1.Condition, []string, error) {
+	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
+	condition := metav1.Condition{Type: DegradedCondition, Status: metav1.ConditionFalse, Reason: "ConsistentTopology", Message: "The nodes agree about the slot owners and the config epochs"}
+	if !topology.Formed() {
+		return condition, nil, nil
+	}
+	views := getClusterViews(cr, topology)
+	conflicts := findClusterConflicts(views)
+	if len(conflicts.Issues) == 0 {
+		return condition, nil, nil
+	}
+	condition.Status = metav1.ConditionTrue
+	condition.Reason = "TopologyConflict"
+	condition.Message = strings.Join(conflicts.Issues, "; ")
+	logger.Info("Cluster nodes disagree about the topology, automated topology changes are paused", "Issues", conflicts.Issues)
+	if !resolve {
+		return condition, nil, nil
+	}
+	actions, err := resolveClusterConflicts(cr, views, conflicts)
+	return condition, actions, err
+}
+
+// getClusterViews returns the CLUSTER NODES view of every pod answering, by pod name
+func getClusterViews(cr *redisv1beta1.RedisCluster, topology *ClusterTopology) map[string][]ClusterNode {
+	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
+	views := map[string][]ClusterNode{}
+	for _, podName := range topology.PodsByIP {
+		if podName == topology.SeedPod {
+			views[podName] = topology.Nodes
+			continue
+		}
+		client := configureRedisClient(cr, podName)
+		nodes, err := getClusterNodes(client)
+		client.Close()
+		if err != nil {
+			logger.Error(err, "Could not read the cluster nodes", "Pod", podName)
+			continue
+		}
+		views[podName] = translateAnnouncedAddresses(nodes, topology.Announced)
+	}
+	return views
+}
+
+// findClusterConflicts will find the slots claimed by several masters, the masters sharing a config epoch
+// and the slots the views disagree about
+func findClusterConflicts(views map[string][]ClusterNode) clusterConflicts {
+	conflicts := clusterConflicts{Owners: map[int]string{}}
+	podNames := getViewPodNames(views)
+	claims := make([][]ClusterNode, totalHashSlots)
+	owners := make([][]string, totalHashSlots)
+	var masters []ClusterNode
+	for _, podName := range podNames {
+		// A master is authoritative about its own slots only in its own view
+		if myself := findClusterNode(views[podName], "myself"); myself != nil && myself.IsMaster() && len(myself.Slots) > 0 {
+			masters = append(masters, *myself)
+			for _, slot := range myself.SlotList() {
+				claims[slot] = append(claims[slot], *myself)
+			}
+		}
+		for _, node := range views[podName] {
+			for _, slot := range node.SlotList() {
+				if !ContainsString(owners[slot], node.ID) {
+					owners[slot] = append(owners[slot], node.ID)
+				}
+			}
+		}
+	}
+
+	claimed := map[string][]int{}
+	disputed := map[string][]int{}
+	for slot := 0; slot < totalHashSlots; slot++ {
+		switch {
+		case len(claims[slot]) > 1:
+			var ids []string
+			for _, node := range claims[slot] {
+				ids = append(ids, fmt.Sprintf("%s (epoch %d)", node.ID, node.ConfigEpoch))
+			}
+			sort.Strings(ids)
+			key := strings.Join(ids, ", ")
+			claimed[key] = append(claimed[key], slot)
+			conflicts.Owners[slot] = getHighestEpochNode(claims[slot]).ID
+		case len(owners[slot]) > 1:
+			ids := append([]string{}, owners[slot]...)
+			sort.Strings(ids)
+			key := strings.Join(ids, ", ")
+			disputed[key] = append(disputed[key], slot)
+			// Only the master claiming the slot in its own view can be its owner
+			if len(claims[slot]) == 1 {
+				conflicts.Owners[slot] = claims[slot][0].ID
+			}
+		}
+	}
+	for _, key := range getSortedSlotKeys(claimed) {
+		conflicts.Issues = append(conflicts.Issues, fmt.Sprintf("slots %s are claimed by %s", formatSlotRanges(claimed[key]), key))
+	}
+	for _, key := range getSortedSlotKeys(disputed) {
+		conflicts.Issues = append(conflicts.Issues, fmt.Sprintf("views disagree whether %s own slots %s", key, formatSlotRanges(disputed[key])))
+	}
+
+	epochs := map[int64][]string{}
+	var epochOrder []int64
+	for _, master := range masters {
+		if _, ok := epochs[master.ConfigEpoch]; !ok {
+			epochOrder = append(epochOrder, master.ConfigEpoch)
+		}
+		epochs[master.ConfigEpoch] = append(epochs[master.ConfigEpoch], master.ID)
+	}
+	sort.Slice(epochOrder, func(i, j int) bool { return epochOrder[i] < epochOrder[j] })
+	for _, epoch := range epochOrder {
+		ids := epochs[epoch]
+		if len(ids) < 2 {
+			continue
+		}
+		sort.Strings(ids)
+		conflicts.Issues = append(conflicts.Issues, fmt.Sprintf("masters %s share config epoch %d", strings.Join(ids, ", "), epoch))
+		// As in Redis, the masters with the smaller IDs take a new epoch
+		conflicts.Bumps = append(conflicts.Bumps, ids[:len(ids)-1]...)
+	}
+	sort.Strings(conflicts.Bumps)
+	return conflicts
+}
+
+// resolveClusterConflicts will bump the colliding config epochs and assign every conflicting slot to its owner on the masters
+// which see another owner, the keys a losing claimant holds in the slot are deleted
+func resolveClusterConflicts(cr *redisv1beta1.RedisCluster, views map[string][]ClusterNode, conflicts clusterConflicts) ([]string, error) {
+	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
+	podsByID := map[string]string{}
+	nodesByID := map[string]ClusterNode{}
+	for podName, view := range views {
+		for _, node := range view {
+			nodesByID[node.ID] = node
+		}
+		if myself := findClusterNode(view, "myself"); myself != nil {
+			podsByID[myself.ID] = podName
+		}
+	}
+
+	var actions []string
+	// The owners take the highest epoch so their claims win the gossip
+	bumps := append([]string{}, conflicts.Bumps...)
+	for _, owner := range conflicts.Owners {
+		if !ContainsString(bumps, owner) {
+			bumps = append(bumps, owner)
+		}
+	}
+	sort.Strings(bumps)
+	for _, nodeID := range bumps {
+		podName := podsByID[nodeID]
+		if podName == "" {
+			continue
+		}
+		client := configureRedisClient(cr, podName)
+		output, err := client.Do("cluster", "bumpepoch").String()
+		client.Close()
+		if err != nil {
+			return actions, err
+		}
+		logger.Info("Config epoch bumped", "Pod", podName, "Output", output)
+		actions = append(actions, fmt.Sprintf("Bumped the config epoch of %s", podName))
+	}
+
+	reassignments := planSlotReassignments(views, conflicts.Owners)
+	for _, podName := range getViewPodNames(views) {
+		reassigned := reassignments[podName]
+		if len(reassigned) == 0 {
+			continue
+		}
+		view := views[podName]
+		owned := map[int]bool{}
+		if myself := findClusterNode(view, "myself"); myself != nil {
+			for _, slot := range myself.SlotList() {
+				owned[slot] = true
+			}
+		}
+		client := configureRedisClient(cr, podName)
+		for _, owner := range getSortedSlotKeys(reassigned) {
+			if findClusterNodeByID(view, owner) == nil {
+				if err := client.ClusterMeet(nodesByID[owner].IP, "6379").Err(); err != nil {
+					client.Close()
+					return actions, err
+				}
+				actions = append(actions, fmt.Sprintf("Introduced %s to %s", podsByID[owner], podName))
+				continue
+			}
+			slots := reassigned[owner]
+			for _, slot := range slots {
+				if owned[slot] {
+					if err := deleteSlotKeys(client, slot); err != nil {
+						client.Close()
+						return actions, err
+					}
+				}
+				if err := client.Do("cluster", "setslot", slot, "node", owner).Err(); err != nil {
+					client.Close()
+					return actions, err
+				}
+			}
+			actions = append(actions, fmt.Sprintf("Assigned slots %s to %s on %s", formatSlotRanges(slots), podsByID[owner], podName))
+		}
+		client.Close()
+	}
+	return actions, nil
+}
+
+// planSlotReassignments returns by pod and owner the conflicting slots the pod sees with another owner, redis only
+// accepts SETSLOT on masters so replicas are left to follow their master
+func planSlotReassignments(views map[string][]ClusterNode, owners map[int]string) map[string]map[string][]int {
+	reassignments := map[string]map[string][]int{}
+	for podName, view := range views {
+		if myself := findClusterNode(view, "myself"); myself == nil || !myself.IsMaster() {
+			continue
+		}
+		seen := map[int]string{}
+		for _, node := range view {
+			for _, slot := range node.SlotList() {
+				seen[slot] = node.ID
+			}
+		}
+		reassigned := map[string][]int{}
+		for slot, owner := range owners {
+			if seen[slot] != owner {
+				reassigned[owner] = append(reassigned[owner], slot)
+			}
+		}
+		for _, slots := range reassigned {
+			sort.Ints(slots)
+		}
+		if len(reassigned) > 0 {
+			reassignments[podName] = reassigned
+		}
+	}
+	return reassignments
+}
+
+// deleteSlotKeys will delete all keys of the slot on the node
+func deleteSlotKeys(client *redis.Client, slot int) error {
+	for {
+		keys, err := client.ClusterGetKeysInSlot(slot, migrateKeysCount).Result()
+		if err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			return nil
+		}
+		if err := client.Del(keys...).Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// getHighestEpochNode returns the node with the highest config epoch, the larger ID wins a tie
+func getHighestEpochNode(nodes []ClusterNode) ClusterNode {
+	highest := nodes[0]
+	for _, node := range nodes[1:] {
+		if node.ConfigEpoch > highest.ConfigEpoch || (node.ConfigEpoch == highest.ConfigEpoch && node.ID > highest.ID) {
+			highest = node
+		}
+	}
+	return highest
+}
+
+// getViewPodNames returns the pods of the views in order
+func getViewPodNames(views map[string][]ClusterNode) []string {
+	podNames := make([]string, 0, len(views))
+	for podName := range views {
+		podNames = append(podNames, podName)
+	}
+	sort.Strings(podNames)
+	return podNames
+}
+
+// getSortedSlotKeys returns the keys of the slot map in order
+func getSortedSlotKeys(slots map[string][]int) []string {
+	keys := make([]string, 0, len(slots))
+	for key := range slots {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,93 @@
+package k8sutils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindClusterConflicts(t *testing.T) {
+	consistent := map[string][]ClusterNode{
+		"redis-leader-0": clusterNodesFromOutput(`
+a 10.0.0.1:6379@16379 myself,master - 0 0 1 connected 0-8191
+b 10.0.0.2:6379@16379 master - 0 0 2 connected 8192-16383`),
+		"redis-leader-1": clusterNodesFromOutput(`
+a 10.0.0.1:6379@16379 master - 0 0 1 connected 0-8191
+b 10.0.0.2:6379@16379 myself,master - 0 0 2 connected 8192-16383`),
+	}
+	if conflicts := findClusterConflicts(consistent); len(conflicts.Issues) != 0 || len(conflicts.Owners) != 0 {
+		t.Errorf("got conflicts %+v for consistent views", conflicts)
+	}
+
+	// a and b both claim 8000-8191 after a partition, c and b collided on epoch 2, the replica d sees a stale owner of 10000
+	views := map[string][]ClusterNode{
+		"redis-leader-0": clusterNodesFromOutput(`
+a 10.0.0.1:6379@16379 myself,master - 0 0 5 connected 0-8191
+b 10.0.0.2:6379@16379 master - 0 0 2 connected 8192-12287
+c 10.0.0.3:6379@16379 master - 0 0 2 connected 12288-16383`),
+		"redis-leader-1": clusterNodesFromOutput(`
+a 10.0.0.1:6379@16379 master - 0 0 1 connected 0-7999
+b 10.0.0.2:6379@16379 myself,master - 0 0 2 connected 8000-12287
+c 10.0.0.3:6379@16379 master - 0 0 2 connected 12288-16383`),
+		"redis-leader-2": clusterNodesFromOutput(`
+a 10.0.0.1:6379@16379 master - 0 0 5 connected 0-8191
+b 10.0.0.2:6379@16379 master - 0 0 2 connected 8192-12287
+c 10.0.0.3:6379@16379 myself,master - 0 0 2 connected 12288-16383`),
+		"redis-follower-0": clusterNodesFromOutput(`
+a 10.0.0.1:6379@16379 master - 0 0 5 connected 0-8191 10000
+b 10.0.0.2:6379@16379 master - 0 0 2 connected 8192-9999 10001-12287
+c 10.0.0.3:6379@16379 master - 0 0 2 connected 12288-16383
+d 10.0.0.4:6379@16379 myself,slave a 0 0 5 connected`),
+	}
+	conflicts := findClusterConflicts(views)
+	want := []string{
+		"slots 8000-8191 are claimed by a (epoch 5), b (epoch 2)",
+		"views disagree whether a, b own slots 10000",
+		"masters b, c share config epoch 2",
+	}
+	if !reflect.DeepEqual(conflicts.Issues, want) {
+		t.Errorf("got issues %q, want %q", conflicts.Issues, want)
+	}
+	if len(conflicts.Owners) != 193 || conflicts.Owners[8000] != "a" || conflicts.Owners[10000] != "b" {
+		t.Errorf("got %d owners, 8000 owned by %s, 10000 owned by %s", len(conflicts.Owners), conflicts.Owners[8000], conflicts.Owners[10000])
+	}
+	if !reflect.DeepEqual(conflicts.Bumps, []string{"b"}) {
+		t.Errorf("got bumps %v, want [b]", conflicts.Bumps)
+	}
+}
+
+func TestGetHighestEpochNode(t *testing.T) {
+	nodes := clusterNodesFromOutput(`
+a 10.0.0.1:6379@16379 master - 0 0 3 connected 0
+c 10.0.0.3:6379@16379 master - 0 0 3 connected 0
+b 10.0.0.2:6379@16379 master - 0 0 2 connected 0`)
+	if node := getHighestEpochNode(nodes); node.ID != "c" {
+		t.Errorf("got %s, want the larger ID on an epoch tie", node.ID)
+	}
+}
+
+func TestPlanSlotReassignments(t *testing.T) {
+	// b lost 8000-8191 to a, the replica d of b sees b as owner as well
+	views := map[string][]ClusterNode{
+		"redis-leader-0": clusterNodesFromOutput(`
+a 10.0.0.1:6379@16379 myself,master - 0 0 5 connected 0-8191
+b 10.0.0.2:6379@16379 master - 0 0 2 connected 8192-16383`),
+		"redis-leader-1": clusterNodesFromOutput(`
+a 10.0.0.1:6379@16379 master - 0 0 1 connected 0-7999
+b 10.0.0.2:6379@16379 myself,master - 0 0 2 connected 8000-16383`),
+		"redis-follower-0": clusterNodesFromOutput(`
+a 10.0.0.1:6379@16379 master - 0 0 1 connected 0-7999
+b 10.0.0.2:6379@16379 master - 0 0 2 connected 8000-16383
+d 10.0.0.4:6379@16379 myself,slave b 0 0 2 connected`),
+	}
+	owners := map[int]string{}
+	for slot := 8000; slot <= 8191; slot++ {
+		owners[slot] = "a"
+	}
+	got := planSlotReassignments(views, owners)
+	if len(got) != 1 || len(got["redis-leader-1"]["a"]) != 192 || got["redis-leader-1"]["a"][0] != 8000 {
+		t.Errorf("got reassignments %v, want 8000-8191 on redis-leader-1 only", got)
+	}
+	if _, ok := got["redis-follower-0"]; ok {
+		t.Errorf("SETSLOT planned on the replica redis-follower-0")
+	}
+}
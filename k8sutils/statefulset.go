@@ -31,8 +31,17 @@ type statefulSetParameters struct {
 	Tolerations           *[]corev1.Toleration
 	EnableMetrics         bool
 	PersistentVolumeClaim corev1.PersistentVolumeClaim
+	EphemeralStorage      *redisv1beta1.EphemeralStorage
 	ImagePullSecrets      *[]corev1.LocalObjectReference
 	ExternalConfig        *string
+	ClaimTemplateName     string
+	Partition             *int32
+	OnDeleteUpdates       bool
+	GeneratedConfig       *string
+	// AnnounceConfig mounts the announce config of every pod from the generated config
+	AnnounceConfig bool
+	// MigrationSourceTemplateName is the claim template copied onto the data claim of a pod before redis starts
+	MigrationSourceTemplateName string
 }
 
 // containerParameters will define container input params
@@ -80,6 +89,8 @@ func patchStatefulSet(storedStateful *appsv1.StatefulSet, newStateful *appsv1.St
 	newStateful.ResourceVersion = storedStateful.ResourceVersion
 	newStateful.CreationTimestamp = storedStateful.CreationTimestamp
 	newStateful.ManagedFields = storedStateful.ManagedFields
+	// The governing service is immutable, statefulsets created before it moved to the headless service keep theirs
+	newStateful.Spec.ServiceName = storedStateful.Spec.ServiceName
 
 	patchResult, err := patch.DefaultPatchMaker.Calculate(storedStateful, newStateful,
 		patch.IgnoreStatusFields(),
@@ -122,7 +133,7 @@ func generateStatefulSetsDef(stsMeta metav1.ObjectMeta, params statefulSetParame
 		ObjectMeta: stsMeta,
 		Spec: appsv1.StatefulSetSpec{
 			Selector:    LabelSelectors(stsMeta.GetLabels()),
-			ServiceName: stsMeta.Name,
+			ServiceName: stsMeta.Name + "-headless",
 			Replicas:    params.Replicas,
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
@@ -147,11 +158,49 @@ func generateStatefulSetsDef(stsMeta metav1.ObjectMeta, params statefulSetParame
 		statefulset.Spec.Template.Spec.ImagePullSecrets = *params.ImagePullSecrets
 	}
 	if containerParams.PersistenceEnabled != nil && *containerParams.PersistenceEnabled {
-		statefulset.Spec.VolumeClaimTemplates = append(statefulset.Spec.VolumeClaimTemplates, createPVCTemplate(stsMeta, params.PersistentVolumeClaim))
+		pvcTemplate := createPVCTemplate(stsMeta, params.PersistentVolumeClaim)
+		if params.ClaimTemplateName != "" {
+			// The data volume follows the template name after a StorageClass migration
+			pvcTemplate.Name = params.ClaimTemplateName
+			for i, mount := range statefulset.Spec.Template.Spec.Containers[0].VolumeMounts {
+				if mount.MountPath == "/data" {
+					statefulset.Spec.Template.Spec.Containers[0].VolumeMounts[i].Name = params.ClaimTemplateName
+				}
+			}
+		}
+		statefulset.Spec.VolumeClaimTemplates = append(statefulset.Spec.VolumeClaimTemplates, pvcTemplate)
+		if params.MigrationSourceTemplateName != "" {
+			sourceTemplate := *pvcTemplate.DeepCopy()
+			sourceTemplate.Name = params.MigrationSourceTemplateName
+			statefulset.Spec.VolumeClaimTemplates = append(statefulset.Spec.VolumeClaimTemplates, sourceTemplate)
+			statefulset.Spec.Template.Spec.InitContainers = append(statefulset.Spec.Template.Spec.InitContainers,
+				generateMigrationInitContainer(containerParams.Image, sourceTemplate.Name, pvcTemplate.Name))
+		}
+	}
+	if params.Partition != nil {
+		statefulset.Spec.UpdateStrategy = appsv1.StatefulSetUpdateStrategy{
+			Type: appsv1.RollingUpdateStatefulSetStrategyType,
+			RollingUpdate: &appsv1.RollingUpdateStatefulSetStrategy{
+				Partition: params.Partition,
+			},
+		}
+	} else if params.OnDeleteUpdates {
+		// The operator restarts the pods itself to fail masters over before their restart
+		statefulset.Spec.UpdateStrategy = appsv1.StatefulSetUpdateStrategy{Type: appsv1.OnDeleteStatefulSetStrategyType}
 	}
 	if params.ExternalConfig != nil {
 		statefulset.Spec.Template.Spec.Volumes = getExternalConfig(*params.ExternalConfig)
 	}
+	if params.GeneratedConfig != nil {
+		mountGeneratedConfig(&statefulset.Spec.Template.Spec, *params.GeneratedConfig, params.AnnounceConfig)
+	}
+	if params.EphemeralStorage != nil {
+		statefulset.Spec.Template.Spec.Volumes = append(statefulset.Spec.Template.Spec.Volumes, getEphemeralStorage(stsMeta.GetName(), params.EphemeralStorage))
+		statefulset.Spec.Template.Spec.Containers[0].VolumeMounts = append(statefulset.Spec.Template.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      stsMeta.GetName(),
+			MountPath: "/data",
+		})
+	}
 
 	if containerParams.TLSConfig != nil {
 		statefulset.Spec.Template.Spec.Volumes = append(statefulset.Spec.Template.Spec.Volumes,
@@ -183,6 +232,37 @@ func getExternalConfig(configMapName string) []corev1.Volume {
 	}
 }
 
+// ValidateStorage will check that a memory backed data directory fits into the container memory
+func ValidateStorage(storage *redisv1beta1.Storage, resources *corev1.ResourceRequirements) error {
+	if !storage.IsEphemeral() || storage.Ephemeral.Medium != corev1.StorageMediumMemory {
+		return nil
+	}
+	if storage.Ephemeral.SizeLimit == nil {
+		return fmt.Errorf("storage.ephemeral.sizeLimit is required for the Memory medium")
+	}
+	if resources == nil {
+		return nil
+	}
+	// Pages written to a memory backed emptyDir are charged to the container memory limit
+	if limit, ok := resources.Limits[corev1.ResourceMemory]; ok && storage.Ephemeral.SizeLimit.Cmp(limit) >= 0 {
+		return fmt.Errorf("memory limit %s must be greater than storage.ephemeral.sizeLimit %s", limit.String(), storage.Ephemeral.SizeLimit.String())
+	}
+	return nil
+}
+
+// getEphemeralStorage will return the emptyDir volume mounted at /data
+func getEphemeralStorage(name string, ephemeral *redisv1beta1.EphemeralStorage) corev1.Volume {
+	return corev1.Volume{
+		Name: name,
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{
+				Medium:    ephemeral.Medium,
+				SizeLimit: ephemeral.SizeLimit,
+			},
+		},
+	}
+}
+
 // createPVCTemplate will create the persistent volume claim template
 func createPVCTemplate(stsMeta metav1.ObjectMeta, storageSpec corev1.PersistentVolumeClaim) corev1.PersistentVolumeClaim {
 	pvcTemplate := storageSpec
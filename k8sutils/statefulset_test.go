@@ -0,0 +1,35 @@
+package k8sutils
+
+import (
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestValidateStorage(t *testing.T) {
+	sizeLimit := resource.MustParse("64Mi")
+	memoryStorage := &redisv1beta1.Storage{Ephemeral: &redisv1beta1.EphemeralStorage{Medium: corev1.StorageMediumMemory, SizeLimit: &sizeLimit}}
+	var tests = []struct {
+		name      string
+		storage   *redisv1beta1.Storage
+		resources *corev1.ResourceRequirements
+		wantErr   bool
+	}{
+		{"unset", nil, nil, false},
+		{"disk backed", &redisv1beta1.Storage{Ephemeral: &redisv1beta1.EphemeralStorage{}}, nil, false},
+		{"memory without size limit", &redisv1beta1.Storage{Ephemeral: &redisv1beta1.EphemeralStorage{Medium: corev1.StorageMediumMemory}}, nil, true},
+		{"memory within limit", memoryStorage, &corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")}}, false},
+		{"memory above limit", memoryStorage, &corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("64Mi")}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateStorage(tt.storage, tt.resources)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package k8sutils
+
+import (
+	"context"
+	"fmt"
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"strconv"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// StorageMigrationConfirmAnnotation releases the retained claims once set to the new StorageClass name
+	StorageMigrationConfirmAnnotation = "redis.opstreelabs.in/confirm-storage-migration"
+
+	storageMigrationMigrating            = "Migrating"
+	storageMigrationAwaitingConfirmation = "AwaitingConfirmation"
+	storageMigrationCompleted            = "Completed"
+)
+
+// storageMigration moves the pods of a statefulset one by one onto claims of a new StorageClass
+type storageMigration struct {
+	namespace string
+	stsName   string
+	storage   *redisv1beta1.Storage
+	confirmed string
+	// prepare readies a pod to be stopped for the copy of its volume, it returns false while the pod is not ready yet
+	prepare func(podName string) (bool, error)
+}
+
+// ReconcileRedisStorageMigration will migrate standalone Redis when the StorageClass of the claim template changes
+func ReconcileRedisStorageMigration(cr *redisv1beta1.Redis) ([]redisv1beta1.StorageMigrationStatus, error) {
+	migration := storageMigration{
+		namespace: cr.Namespace,
+		stsName:   cr.ObjectMeta.Name,
+		storage:   cr.Spec.Storage,
+		confirmed: cr.GetAnnotations()[StorageMigrationConfirmAnnotation],
+		prepare: func(podName string) (bool, error) {
+			client := configureRedisStandaloneClient(cr)
+			defer client.Close()
+			return true, executeBGSave(client)
+		},
+	}
+	return migration.reconcileStatus(cr.Status.StorageMigrations)
+}
+
+// ReconcileRedisClusterStorageMigration will migrate the leader and then the follower statefulset of the cluster
+func ReconcileRedisClusterStorageMigration(cr *redisv1beta1.RedisCluster) ([]redisv1beta1.StorageMigrationStatus, error) {
+	migrations := cr.Status.StorageMigrations
+	for _, role := range []string{"leader", "follower"} {
+		migration := storageMigration{
+			namespace: cr.Namespace,
+			stsName:   cr.ObjectMeta.Name + "-" + role,
+			storage:   cr.Spec.Storage,
+			confirmed: cr.GetAnnotations()[StorageMigrationConfirmAnnotation],
+			prepare: func(podName string) (bool, error) {
+				return prepareClusterPodMigration(cr, podName)
+			},
+		}
+		var err error
+		migrations, err = migration.reconcileStatus(migrations)
+		if err != nil {
+			return migrations, err
+		}
+		// Only one statefulset of the cluster is rolled at a time
+		if status := findStorageMigration(migrations, migration.stsName); status != nil && status.Phase == storageMigrationMigrating {
+			break
+		}
+	}
+	return migrations, nil
+}
+
+// reconcileStatus will reconcile the migration entry of the statefulset and return the updated list
+func (m storageMigration) reconcileStatus(migrations []redisv1beta1.StorageMigrationStatus) ([]redisv1beta1.StorageMigrationStatus, error) {
+	var current *redisv1beta1.StorageMigrationStatus
+	if status := findStorageMigration(migrations, m.stsName); status != nil {
+		current = status.DeepCopy()
+	}
+	next, err := m.reconcile(current)
+	if next == nil {
+		return migrations, err
+	}
+	var updated []redisv1beta1.StorageMigrationStatus
+	for _, status := range migrations {
+		if status.StatefulSet != m.stsName {
+			updated = append(updated, status)
+		}
+	}
+	return append(updated, *next), err
+}
+
+// reconcile advances the migration of the statefulset by at most one step
+func (m storageMigration) reconcile(current *redisv1beta1.StorageMigrationStatus) (*redisv1beta1.StorageMigrationStatus, error) {
+	logger := storageMigrationLogger(m.namespace, m.stsName)
+	sts, err := GetStatefulSet(m.namespace, m.stsName)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return current, nil
+		}
+		return current, err
+	}
+	if current == nil || current.Phase == storageMigrationCompleted {
+		return m.start(current, sts)
+	}
+	if len(sts.Spec.VolumeClaimTemplates) == 0 || sts.Spec.VolumeClaimTemplates[0].Name != current.ClaimTemplateName {
+		logger.Info("Waiting for the statefulset to be recreated with the new claim template")
+		return current, nil
+	}
+
+	switch current.Phase {
+	case storageMigrationMigrating:
+		replicas := *sts.Spec.Replicas
+		if sts.Status.ObservedGeneration < sts.Generation || sts.Status.ReadyReplicas != replicas || sts.Status.UpdatedReplicas < replicas-current.Partition {
+			logger.Info("Waiting for the migrated pods to become ready", "Partition", current.Partition)
+			return current, nil
+		}
+		if current.Partition == 0 {
+			logger.Info("All pods run on the new StorageClass, previous claims are retained until confirmed", "RetainedClaims", current.RetainedClaims)
+			current.Phase = storageMigrationAwaitingConfirmation
+			return current, nil
+		}
+		ordinal := current.Partition - 1
+		podName := m.stsName + "-" + strconv.Itoa(int(ordinal))
+		ready, err := m.prepare(podName)
+		if err != nil || !ready {
+			return current, err
+		}
+		// The pod restarts onto the new claim, its init container copies the previous claim before redis starts
+		current.Partition = ordinal
+		logger.Info("Rolling pod onto the new claim", "Pod", podName)
+		return current, nil
+	case storageMigrationAwaitingConfirmation:
+		if m.confirmed != current.StorageClassName {
+			return current, nil
+		}
+		// The statefulset is recreated without the previous claim template, the pods release the claims on their restart
+		if err := m.orphanStatefulSet(); err != nil {
+			return current, err
+		}
+		for _, claim := range current.RetainedClaims {
+			if err := deletePVC(m.namespace, claim); err != nil {
+				return current, err
+			}
+		}
+		logger.Info("Storage migration confirmed, previous claims deleted", "Claims", current.RetainedClaims)
+		current.RetainedClaims = nil
+		current.Phase = storageMigrationCompleted
+	}
+	return current, nil
+}
+
+// start will begin a migration when the requested StorageClass differs from the claim template
+func (m storageMigration) start(current *redisv1beta1.StorageMigrationStatus, sts *appsv1.StatefulSet) (*redisv1beta1.StorageMigrationStatus, error) {
+	if m.storage == nil || m.storage.IsEphemeral() || m.storage.VolumeClaimTemplate.Spec.StorageClassName == nil || len(sts.Spec.VolumeClaimTemplates) == 0 {
+		return current, nil
+	}
+	storedTemplate := sts.Spec.VolumeClaimTemplates[0]
+	storageClassName := *m.storage.VolumeClaimTemplate.Spec.StorageClassName
+	if storedTemplate.Spec.StorageClassName != nil && *storedTemplate.Spec.StorageClassName == storageClassName {
+		return current, nil
+	}
+
+	logger := storageMigrationLogger(m.namespace, m.stsName)
+	next := &redisv1beta1.StorageMigrationStatus{
+		StatefulSet:       m.stsName,
+		StorageClassName:  storageClassName,
+		ClaimTemplateName: m.stsName + "-" + storageClassName,
+		Partition:         *sts.Spec.Replicas,
+		Phase:             storageMigrationMigrating,
+		// The previous claims stay mounted for the copy until the migration is confirmed
+		SourceClaimTemplateName: storedTemplate.Name,
+	}
+	for ordinal := 0; ordinal < int(*sts.Spec.Replicas); ordinal++ {
+		next.RetainedClaims = append(next.RetainedClaims, storedTemplate.Name+"-"+m.stsName+"-"+strconv.Itoa(ordinal))
+	}
+	if err := m.orphanStatefulSet(); err != nil {
+		return current, err
+	}
+	logger.Info("Storage migration started", "StorageClass", storageClassName, "ClaimTemplate", next.ClaimTemplateName)
+	return next, nil
+}
+
+// orphanStatefulSet will delete the statefulset while keeping its pods, the claim templates are immutable so the
+// statefulset is recreated with the claim templates of the migration
+func (m storageMigration) orphanStatefulSet() error {
+	orphan := metav1.DeletePropagationOrphan
+	err := generateK8sClient().AppsV1().StatefulSets(m.namespace).Delete(context.TODO(), m.stsName, metav1.DeleteOptions{PropagationPolicy: &orphan})
+	if err != nil && !errors.IsNotFound(err) {
+		storageMigrationLogger(m.namespace, m.stsName).Error(err, "Could not orphan the statefulset for storage migration")
+		return err
+	}
+	return nil
+}
+
+// prepareClusterPodMigration will fail a master over to a caught up replica before its pod is stopped for the copy
+// and persist the dataset of the pod, it returns false while the failover is pending
+func prepareClusterPodMigration(cr *redisv1beta1.RedisCluster, podName string) (bool, error) {
+	logger := storageMigrationLogger(cr.Namespace, cr.ObjectMeta.Name)
+	if meta.IsStatusConditionTrue(cr.Status.Conditions, DegradedCondition) {
+		logger.Info("Waiting for the cluster topology to be consistent before the volume copy", "Pod", podName)
+		return false, nil
+	}
+	topology := GetClusterTopology(cr)
+	node := findClusterNodeByIP(topology.Nodes, topology.PodIP(podName))
+	if node != nil && node.IsMaster() && len(node.Slots) > 0 {
+		replica := getHealthyReplicaPod(topology.Nodes, node.ID, topology.PodsByIP)
+		if replica != "" && isReplicaCaughtUp(cr, replica) {
+			client := configureRedisClient(cr, replica)
+			err := client.Do("cluster", "failover").Err()
+			client.Close()
+			if err != nil {
+				return false, err
+			}
+			logger.Info("Failing master over to its replica before the volume copy", "Pod", podName, "Replica", replica)
+			return false, nil
+		}
+		if replica != "" {
+			logger.Info("Waiting for the replica to catch up before the volume copy", "Pod", podName, "Replica", replica)
+			return false, nil
+		}
+		logger.Info("Master has no healthy replica, its slots are unavailable during the volume copy", "Pod", podName)
+	}
+	client := configureRedisClient(cr, podName)
+	defer client.Close()
+	return true, executeBGSave(client)
+}
+
+// generateMigrationInitContainer generates the init container copying the previous claim onto the new claim of a pod
+// before redis starts, the marker keeps a restarted pod from copying again
+func generateMigrationInitContainer(image, source, target string) corev1.Container {
+	marker := "/data/.storage-migrated-" + target
+	return corev1.Container{
+		Name:    "storage-migration",
+		Image:   image,
+		Command: []string{"sh", "-c", fmt.Sprintf("[ -e %s ] || { cp -a /migration-source/. /data/ && touch %s; }", marker, marker)},
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: source, MountPath: "/migration-source", ReadOnly: true},
+			{Name: target, MountPath: "/data"},
+		},
+	}
+}
+
+// applyStorageMigration will point the statefulset parameters at the claim template of a migration
+func applyStorageMigration(params *statefulSetParameters, migrations []redisv1beta1.StorageMigrationStatus, stsName string) {
+	status := findStorageMigration(migrations, stsName)
+	if status == nil {
+		return
+	}
+	params.ClaimTemplateName = status.ClaimTemplateName
+	if status.Phase != storageMigrationCompleted {
+		params.MigrationSourceTemplateName = status.SourceClaimTemplateName
+	}
+	if status.Phase == storageMigrationMigrating {
+		partition := status.Partition
+		params.Partition = &partition
+	}
+}
+
+// findStorageMigration returns the migration entry of a statefulset
+func findStorageMigration(migrations []redisv1beta1.StorageMigrationStatus, stsName string) *redisv1beta1.StorageMigrationStatus {
+	for i := range migrations {
+		if migrations[i].StatefulSet == stsName {
+			return &migrations[i]
+		}
+	}
+	return nil
+}
+
+// storageMigrationLogger will generate logging interface for storage migrations
+func storageMigrationLogger(namespace string, name string) logr.Logger {
+	reqLogger := log.WithValues("Request.StorageMigration.Namespace", namespace, "Request.StorageMigration.StatefulSet", name)
+	return reqLogger
+}
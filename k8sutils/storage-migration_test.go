@@ -0,0 +1,43 @@
+package k8sutils
+
+import (
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestApplyStorageMigration(t *testing.T) {
+	migrations := []redisv1beta1.StorageMigrationStatus{
+		{StatefulSet: "redis-leader", StorageClassName: "fast-ssd", ClaimTemplateName: "redis-leader-fast-ssd", SourceClaimTemplateName: "redis-leader", Partition: 2, Phase: storageMigrationMigrating},
+	}
+	params := statefulSetParameters{}
+	applyStorageMigration(&params, migrations, "redis-leader")
+	if params.ClaimTemplateName != "redis-leader-fast-ssd" || params.Partition == nil || *params.Partition != 2 {
+		t.Errorf("unexpected params %+v", params)
+	}
+
+	trueProperty := true
+	stsMeta := metav1.ObjectMeta{Name: "redis-leader", Namespace: "ot"}
+	sts := generateStatefulSetsDef(stsMeta, params, metav1.OwnerReference{}, containerParameters{PersistenceEnabled: &trueProperty, ReadinessProbe: &redisv1beta1.Probe{}, LivenessProbe: &redisv1beta1.Probe{}}, nil)
+	if sts.Spec.VolumeClaimTemplates[0].Name != "redis-leader-fast-ssd" {
+		t.Errorf("unexpected claim template %s", sts.Spec.VolumeClaimTemplates[0].Name)
+	}
+	if sts.Spec.Template.Spec.Containers[0].VolumeMounts[0].Name != "redis-leader-fast-ssd" {
+		t.Errorf("data volume is not mounted from the claim template")
+	}
+	if len(sts.Spec.VolumeClaimTemplates) != 2 || sts.Spec.VolumeClaimTemplates[1].Name != "redis-leader" {
+		t.Errorf("previous claim template is not kept for the copy: %v", sts.Spec.VolumeClaimTemplates)
+	}
+	initContainers := sts.Spec.Template.Spec.InitContainers
+	if len(initContainers) != 1 || initContainers[0].VolumeMounts[0].Name != "redis-leader" || initContainers[0].VolumeMounts[1].Name != "redis-leader-fast-ssd" {
+		t.Errorf("unexpected init containers %v", initContainers)
+	}
+
+	migrations[0].Phase = storageMigrationCompleted
+	params = statefulSetParameters{}
+	applyStorageMigration(&params, migrations, "redis-leader")
+	if params.ClaimTemplateName != "redis-leader-fast-ssd" || params.Partition != nil || params.MigrationSourceTemplateName != "" {
+		t.Errorf("unexpected params after completion %+v", params)
+	}
+}
@@ -0,0 +1,168 @@
+package k8sutils
+
+import (
+	"context"
+	"fmt"
+	redisv1beta1 "redis-operator/api/v1beta1"
+	"sort"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ReplicaPlacementCondition reports whether every replica runs outside the failure domain of its master
+	ReplicaPlacementCondition = "ReplicaPlacement"
+
+	defaultTopologyKey = "kubernetes.io/hostname"
+)
+
+// ReconcileRedisClusterPlacement will find the replicas sharing a failure domain with their master and swap their masters when auto repair is enabled
+func ReconcileRedisClusterPlacement(cr *redisv1beta1.RedisCluster, topology *ClusterTopology) (metav1.Condition, error) {
+	logger := generateRedisManagerLogger(cr.Namespace, cr.ObjectMeta.Name)
+	condition := metav1.Condition{Type: ReplicaPlacementCondition, Status: metav1.ConditionTrue, Reason: "SeparateFailureDomains", Message: "Every replica runs outside the failure domain of its master"}
+	if !topology.Formed() {
+		return condition, nil
+	}
+	domains, err := getPodFailureDomains(cr)
+	if err != nil {
+		return condition, err
+	}
+	misplaced, moves, conflicts := planReplicaPlacement(topology.Nodes, domains)
+	if cr.Spec.ReplicaPlacement == nil || !cr.Spec.ReplicaPlacement.AutoRepair {
+		conflicts = misplaced
+	} else {
+		for _, move := range moves {
+			podName := topology.PodNameByID(move.Replica)
+			if podName == "" {
+				continue
+			}
+			client := configureRedisClient(cr, podName)
+			err := client.ClusterReplicate(move.Master).Err()
+			client.Close()
+			if err != nil {
+				return condition, err
+			}
+			logger.Info("Replica moved out of the failure domain of its master", "Pod", podName, "Master", move.Master)
+		}
+	}
+	if len(conflicts) == 0 {
+		return condition, nil
+	}
+
+	var pods []string
+	for _, nodeID := range conflicts {
+		pods = append(pods, topology.PodNameByID(nodeID))
+	}
+	sort.Strings(pods)
+	condition.Status = metav1.ConditionFalse
+	condition.Reason = "SharedFailureDomain"
+	condition.Message = fmt.Sprintf("Replicas share the failure domain of their master: %s", strings.Join(pods, ","))
+	return condition, nil
+}
+
+// planReplicaPlacement will pair replicas sharing a failure domain with their master to swap masters,
+// it returns the misplaced replicas, the moves and the replicas no swap can place
+func planReplicaPlacement(nodes []ClusterNode, domains map[string]string) ([]string, []replicaMove, []string) {
+	domainOf := map[string]string{}
+	masters := map[string]bool{}
+	for _, node := range nodes {
+		domainOf[node.ID] = domains[node.IP]
+		if node.IsMaster() && !node.IsFailed() && len(node.Slots) > 0 {
+			masters[node.ID] = true
+		}
+	}
+	assigned := map[string]string{}
+	var replicas []string
+	for _, node := range nodes {
+		if node.IsReplica() && !node.IsFailed() && masters[node.MasterID] {
+			assigned[node.ID] = node.MasterID
+			replicas = append(replicas, node.ID)
+		}
+	}
+	sort.Strings(replicas)
+	sharesDomain := func(replica, master string) bool {
+		return domainOf[replica] != "" && domainOf[replica] == domainOf[master]
+	}
+
+	var misplaced []string
+	for _, replica := range replicas {
+		if sharesDomain(replica, assigned[replica]) {
+			misplaced = append(misplaced, replica)
+		}
+	}
+
+	var moves []replicaMove
+	var conflicts []string
+	for _, replica := range misplaced {
+		if !sharesDomain(replica, assigned[replica]) {
+			continue
+		}
+		swapped := false
+		for _, other := range replicas {
+			master, otherMaster := assigned[replica], assigned[other]
+			if master == otherMaster || sharesDomain(replica, otherMaster) || sharesDomain(other, master) {
+				continue
+			}
+			assigned[replica], assigned[other] = otherMaster, master
+			moves = append(moves, replicaMove{Replica: replica, Master: otherMaster}, replicaMove{Replica: other, Master: master})
+			swapped = true
+			break
+		}
+		if !swapped {
+			conflicts = append(conflicts, replica)
+		}
+	}
+	return misplaced, moves, conflicts
+}
+
+// getMasterForReplica returns the master with the fewest replicas outside the failure domain of the replica, or the master with the fewest replicas
+func getMasterForReplica(nodes []ClusterNode, domains map[string]string, replicaIP string) string {
+	domain := domains[replicaIP]
+	var candidates []ClusterNode
+	for _, node := range nodes {
+		if node.IsMaster() && domain != "" && domains[node.IP] == domain {
+			continue
+		}
+		candidates = append(candidates, node)
+	}
+	if master := getMasterWithFewestReplicas(candidates); master != "" {
+		return master
+	}
+	return getMasterWithFewestReplicas(nodes)
+}
+
+// getPodFailureDomains returns the failure domain of the cluster pods by pod IP, read from the topology label of their node
+func getPodFailureDomains(cr *redisv1beta1.RedisCluster) (map[string]string, error) {
+	topologyKey := defaultTopologyKey
+	if cr.Spec.ReplicaPlacement != nil && cr.Spec.ReplicaPlacement.TopologyKey != "" {
+		topologyKey = cr.Spec.ReplicaPlacement.TopologyKey
+	}
+	client := generateK8sClient()
+	nodeDomains := map[string]string{}
+	domains := map[string]string{}
+	for _, role := range []string{"leader", "follower"} {
+		for podCount := 0; podCount < int(cr.Spec.GetReplicaCounts(role)); podCount++ {
+			pod, err := client.CoreV1().Pods(cr.Namespace).Get(context.TODO(), cr.ObjectMeta.Name+"-"+role+"-"+strconv.Itoa(podCount), metav1.GetOptions{})
+			if err != nil || pod.Spec.NodeName == "" || pod.Status.PodIP == "" {
+				continue
+			}
+			domain, ok := nodeDomains[pod.Spec.NodeName]
+			if !ok {
+				if topologyKey == defaultTopologyKey {
+					domain = pod.Spec.NodeName
+				} else {
+					node, err := client.CoreV1().Nodes().Get(context.TODO(), pod.Spec.NodeName, metav1.GetOptions{})
+					if err != nil {
+						return nil, err
+					}
+					domain = node.Labels[topologyKey]
+				}
+				nodeDomains[pod.Spec.NodeName] = domain
+			}
+			domains[pod.Status.PodIP] = domain
+		}
+	}
+	return domains, nil
+}
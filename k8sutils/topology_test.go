@@ -0,0 +1,54 @@
+package k8sutils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlanReplicaPlacement(t *testing.T) {
+	nodes := clusterNodesFromOutput(`
+a 10.0.0.1:6379@16379 myself,master - 0 0 1 connected 0-5460
+b 10.0.0.2:6379@16379 master - 0 0 2 connected 5461-10922
+c 10.0.0.3:6379@16379 master - 0 0 3 connected 10923-16383
+d 10.0.0.4:6379@16379 slave a 0 0 1 connected
+e 10.0.0.5:6379@16379 slave b 0 0 2 connected
+f 10.0.0.6:6379@16379 slave c 0 0 3 connected`)
+	domains := map[string]string{
+		"10.0.0.1": "zone-a", "10.0.0.2": "zone-b", "10.0.0.3": "zone-c",
+		"10.0.0.4": "zone-a", "10.0.0.5": "zone-c", "10.0.0.6": "zone-b",
+	}
+	misplaced, moves, conflicts := planReplicaPlacement(nodes, domains)
+	if !reflect.DeepEqual(misplaced, []string{"d"}) {
+		t.Errorf("got misplaced %v, want [d]", misplaced)
+	}
+	want := []replicaMove{{Replica: "d", Master: "b"}, {Replica: "e", Master: "a"}}
+	if !reflect.DeepEqual(moves, want) {
+		t.Errorf("got moves %v, want %v", moves, want)
+	}
+	if len(conflicts) != 0 {
+		t.Errorf("got conflicts %v, want none", conflicts)
+	}
+
+	// Every pod on the same node leaves no valid swap
+	for ip := range domains {
+		domains[ip] = "node-1"
+	}
+	misplaced, moves, conflicts = planReplicaPlacement(nodes, domains)
+	if len(misplaced) != 3 || len(moves) != 0 || !reflect.DeepEqual(conflicts, misplaced) {
+		t.Errorf("got misplaced %v, moves %v, conflicts %v", misplaced, moves, conflicts)
+	}
+}
+
+func TestGetMasterForReplica(t *testing.T) {
+	nodes := clusterNodesFromOutput(`
+a 10.0.0.1:6379@16379 myself,master - 0 0 1 connected 0-8191
+b 10.0.0.2:6379@16379 master - 0 0 2 connected 8192-16383
+c 10.0.0.3:6379@16379 slave b 0 0 2 connected`)
+	domains := map[string]string{"10.0.0.1": "node-1", "10.0.0.2": "node-2", "10.0.0.4": "node-1"}
+	if master := getMasterForReplica(nodes, domains, "10.0.0.4"); master != "b" {
+		t.Errorf("got master %s, want b", master)
+	}
+	if master := getMasterForReplica(nodes, domains, "10.0.0.5"); master != "a" {
+		t.Errorf("got master %s, want a", master)
+	}
+}